@@ -0,0 +1,96 @@
+package mapstructure
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// BatchError is returned by DecodeBatch if any of its inputs failed to
+// decode.
+type BatchError struct {
+	// Errors holds the errors of the inputs that failed to decode, in the
+	// order of their indexes.
+	Errors []*StreamError
+}
+
+func (e *BatchError) Error() string {
+	points := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		points[i] = fmt.Sprintf("* %s", err)
+	}
+
+	return fmt.Sprintf(
+		"%d document(s) failed to decode:\n\n%s",
+		len(e.Errors), strings.Join(points, "\n"))
+}
+
+// Unwrap returns the errors of the inputs so that errors.Is and errors.As
+// can match any of them.
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+
+	return errs
+}
+
+// DecodeBatch decodes each of inputs into the element with the same index
+// of the slice that results points to. The slice is resized to the length
+// of inputs, reusing its backing array if it is large enough, and every
+// element is reset to its zero value before it is decoded. The Result of
+// the configuration is ignored.
+//
+// Decoding continues past the inputs that fail to decode. If any did, a
+// *BatchError listing the error of each of them is returned, and their
+// elements may be partially decoded.
+//
+// Elements that are structs of basic kinds are decoded by assignment
+// whenever the configuration allows it, which is much cheaper than
+// decoding each input on its own with Decode.
+func (d *Decoder) DecodeBatch(inputs []map[string]interface{}, results interface{}) error {
+	resultsVal := reflect.ValueOf(results)
+	if resultsVal.Kind() != reflect.Ptr || resultsVal.IsNil() || resultsVal.Elem().Kind() != reflect.Slice {
+		return errors.New("results must be a pointer to a slice")
+	}
+
+	sliceVal := resultsVal.Elem()
+	if sliceVal.Cap() < len(inputs) {
+		sliceVal.Set(reflect.MakeSlice(sliceVal.Type(), len(inputs), len(inputs)))
+	} else {
+		sliceVal.SetLen(len(inputs))
+	}
+
+	elemType := sliceVal.Type().Elem()
+	zero := reflect.Zero(elemType)
+
+	// The plan is looked up once for the whole batch.
+	var plan *flatPlan
+	if elemType.Kind() == reflect.Struct && d.canDecodeFlat() && d.config.FlatKeySeparator == "" {
+		if p := d.flatPlan(elemType); p.flat {
+			plan = p
+		}
+	}
+
+	var errs []*StreamError
+	for i, input := range inputs {
+		elem := sliceVal.Index(i)
+		elem.Set(zero)
+
+		if plan != nil && d.decodeFlat(input, plan, elem) {
+			continue
+		}
+
+		if err := d.decodeRoot(input, "", nil, elem); err != nil {
+			errs = append(errs, &StreamError{Index: i, Err: err})
+		}
+	}
+
+	if len(errs) > 0 {
+		return &BatchError{Errors: errs}
+	}
+
+	return nil
+}
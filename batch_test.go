@@ -0,0 +1,80 @@
+package mapstructure
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDecoder_DecodeBatch(t *testing.T) {
+	t.Parallel()
+
+	decoder, err := NewDecoder(&DecoderConfig{Result: &struct{}{}})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	inputs := []map[string]interface{}{
+		{"method": "get", "id": 1},
+		{"method": "put", "id": "two"},
+		{"method": "post", "id": int64(3)},
+		{"id": "four"},
+	}
+
+	// The elements left over from a previous batch must be reset.
+	results := make([]flatRequest, 1, 8)
+	results[0].Async = true
+
+	err = decoder.DecodeBatch(inputs, &results)
+	expected := []flatRequest{
+		{Method: "get", ID: 1},
+		{Method: "put"},
+		{Method: "post", ID: 3},
+		{},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Fatalf("bad: %#v", results)
+	}
+	if cap(results) != 8 {
+		t.Fatalf("expected the backing array to be reused, got cap %d", cap(results))
+	}
+
+	var berr *BatchError
+	if !errors.As(err, &berr) || len(berr.Errors) != 2 {
+		t.Fatalf("bad: %#v", err)
+	}
+	if berr.Errors[0].Index != 1 || berr.Errors[1].Index != 3 {
+		t.Fatalf("bad: %#v", berr.Errors)
+	}
+	var derr *DecodingError
+	if !errors.As(err, &derr) || derr.Name != "id" {
+		t.Fatalf("bad: %#v", err)
+	}
+}
+
+func TestDecoder_DecodeBatchPointers(t *testing.T) {
+	t.Parallel()
+
+	decoder, err := NewDecoder(&DecoderConfig{Result: &struct{}{}, WeaklyTypedInput: true})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var results []*streamEvent
+	inputs := []map[string]interface{}{
+		{"id": "1", "tags": "a"},
+		{"id": 2},
+	}
+	if err := decoder.DecodeBatch(inputs, &results); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []*streamEvent{{ID: 1, Tags: []string{"a"}}, {ID: 2}}
+	if !reflect.DeepEqual(results, expected) {
+		t.Fatalf("bad: %#v", results)
+	}
+
+	if err := decoder.DecodeBatch(inputs, results); err == nil {
+		t.Fatal("expected an error for results that isn't a pointer to a slice")
+	}
+}
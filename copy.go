@@ -0,0 +1,157 @@
+package mapstructure
+
+import "reflect"
+
+// CopyPolicy is the policy for the maps, slices and pointers in the input
+// that are assigned as they are to an interface{} in the result.
+type CopyPolicy int
+
+const (
+	// CopyShared, the default, assigns the values themselves, so that the
+	// result shares their memory with the input. This is fast, but later
+	// changes to the input show through in the result and vice versa.
+	CopyShared CopyPolicy = iota
+
+	// CopyDeep assigns deep copies of the values, like DeepCopy, so that
+	// the result is safe from later changes to the input.
+	CopyDeep
+)
+
+// DeepCopy copies src into dst, which must be a pointer. If src has the
+// same type as the value dst points to (or is a pointer to such a value)
+// and no DecodeHook is configured, it is copied directly, without going
+// through decoding. Otherwise src is decoded into dst using the given
+// config, so that hooks and type conversions apply as with Decode.
+//
+// Either way, maps, slices, pointers and interface values are copied
+// recursively so that dst shares no memory with src. Unexported struct
+// fields, functions and channels are copied as they are.
+func DeepCopy(src, dst interface{}, config ...*DecoderConfig) error {
+	c := copyConfig(config...)
+	c.Result = dst
+
+	decoder, err := NewDecoder(c)
+	if err != nil {
+		return err
+	}
+
+	out := reflect.ValueOf(dst).Elem()
+	in := reflect.ValueOf(src)
+	if in.Kind() == reflect.Ptr && in.Type().Elem() == out.Type() && !in.IsNil() {
+		in = in.Elem()
+	}
+	if c.DecodeHook == nil && in.IsValid() && in.Type() == out.Type() {
+		out.Set(deepCopyValue(in, map[copiedPointer]reflect.Value{}))
+		return nil
+	}
+
+	decoder.deepCopy = true
+	return decoder.Decode(src)
+}
+
+// copyValue returns the value the decoder assigns when the input already
+// has the type of the output: a deep copy of v when decoding for DeepCopy
+// or when policy is CopyDeep, and v itself otherwise.
+func (d *Decoder) copyValue(v reflect.Value, policy CopyPolicy) reflect.Value {
+	if !d.deepCopy && policy != CopyDeep {
+		return v
+	}
+
+	return deepCopyValue(v, map[copiedPointer]reflect.Value{})
+}
+
+// copiedPointer identifies a pointer, map or slice copied by
+// deepCopyValue. Slices are told apart from others sharing their first
+// element by their length.
+type copiedPointer struct {
+	addr uintptr
+	len  int
+	typ  reflect.Type
+}
+
+// deepCopyValue returns a copy of v that shares no maps, slices or
+// pointers with it. Pointers, maps and slices that were already copied
+// are looked up in seen, so that cycles and shared values are preserved.
+func deepCopyValue(v reflect.Value, seen map[copiedPointer]reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		key := copiedPointer{v.Pointer(), 0, v.Type()}
+		if copied, ok := seen[key]; ok {
+			return copied
+		}
+
+		copied := reflect.New(v.Type().Elem())
+		seen[key] = copied
+		copied.Elem().Set(deepCopyValue(v.Elem(), seen))
+		return copied
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+
+		copied := reflect.New(v.Type()).Elem()
+		copied.Set(deepCopyValue(v.Elem(), seen))
+		return copied
+
+	case reflect.Map:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+
+		key := copiedPointer{v.Pointer(), 0, v.Type()}
+		if copied, ok := seen[key]; ok {
+			return copied
+		}
+
+		copied := reflect.MakeMapWithSize(v.Type(), v.Len())
+		seen[key] = copied
+		iter := v.MapRange()
+		for iter.Next() {
+			copied.SetMapIndex(
+				deepCopyValue(iter.Key(), seen),
+				deepCopyValue(iter.Value(), seen))
+		}
+		return copied
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+
+		key := copiedPointer{v.Pointer(), v.Len(), v.Type()}
+		if copied, ok := seen[key]; ok {
+			return copied
+		}
+
+		copied := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		seen[key] = copied
+		for i := 0; i < v.Len(); i++ {
+			copied.Index(i).Set(deepCopyValue(v.Index(i), seen))
+		}
+		return copied
+
+	case reflect.Array:
+		copied := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			copied.Index(i).Set(deepCopyValue(v.Index(i), seen))
+		}
+		return copied
+
+	case reflect.Struct:
+		copied := reflect.New(v.Type()).Elem()
+		copied.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if field := copied.Field(i); field.CanSet() {
+				field.Set(deepCopyValue(v.Field(i), seen))
+			}
+		}
+		return copied
+
+	default:
+		return v
+	}
+}
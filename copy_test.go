@@ -0,0 +1,192 @@
+package mapstructure
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+type copyNode struct {
+	Name     string
+	Tags     map[string]string
+	Children []*copyNode
+	Parent   *copyNode
+	Extra    interface{}
+}
+
+func TestDeepCopy(t *testing.T) {
+	t.Parallel()
+
+	root := &copyNode{
+		Name:  "root",
+		Tags:  map[string]string{"env": "prod"},
+		Extra: map[string]interface{}{"list": []interface{}{1, 2}},
+	}
+	child := &copyNode{Name: "child", Parent: root}
+	root.Children = []*copyNode{child}
+
+	var result copyNode
+	if err := DeepCopy(root, &result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if result.Name != "root" || result.Tags["env"] != "prod" {
+		t.Fatalf("bad: %#v", result)
+	}
+	if result.Children[0] == child {
+		t.Fatal("children are shared")
+	}
+	if result.Children[0].Parent == root || result.Children[0].Parent.Name != "root" {
+		t.Fatalf("bad parent: %#v", result.Children[0].Parent)
+	}
+
+	result.Tags["env"] = "dev"
+	result.Extra.(map[string]interface{})["list"].([]interface{})[0] = 3
+	if root.Tags["env"] != "prod" {
+		t.Fatal("tags are shared")
+	}
+	if root.Extra.(map[string]interface{})["list"].([]interface{})[0] != 1 {
+		t.Fatal("extra is shared")
+	}
+}
+
+func TestDeepCopy_cycles(t *testing.T) {
+	t.Parallel()
+
+	m := map[string]interface{}{"name": "m"}
+	m["self"] = m
+
+	var result map[string]interface{}
+	if err := DeepCopy(m, &result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	self := result["self"].(map[string]interface{})
+	if reflect.ValueOf(self).Pointer() != reflect.ValueOf(result).Pointer() {
+		t.Fatal("expected the copy to refer to itself")
+	}
+	self["name"] = "copy"
+	if m["name"] != "m" {
+		t.Fatal("map is shared")
+	}
+
+	s := []interface{}{nil, "s"}
+	s[0] = s
+
+	var list []interface{}
+	if err := DeepCopy(s, &list); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if &list[0].([]interface{})[0] != &list[0] {
+		t.Fatal("expected the copy to refer to itself")
+	}
+	if &list[0] == &s[0] {
+		t.Fatal("slice is shared")
+	}
+}
+
+func TestDeepCopy_compatibleTypes(t *testing.T) {
+	t.Parallel()
+
+	type Source struct {
+		Name  string
+		Port  int
+		Hosts []string
+		Meta  map[string]interface{}
+	}
+	type Target struct {
+		Name  string
+		Port  string
+		Hosts []string
+		Meta  map[string]interface{}
+	}
+
+	src := Source{
+		Name:  "web",
+		Port:  80,
+		Hosts: []string{"a", "b"},
+		Meta:  map[string]interface{}{"labels": map[string]interface{}{"app": "web"}},
+	}
+
+	var result Target
+	err := DeepCopy(src, &result, &DecoderConfig{
+		DecodeHook: func(from, to reflect.Type, data interface{}) (interface{}, error) {
+			if from.Kind() == reflect.Int && to.Kind() == reflect.String {
+				return strconv.Itoa(data.(int)), nil
+			}
+			return data, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := Target{
+		Name:  "web",
+		Port:  "80",
+		Hosts: []string{"a", "b"},
+		Meta:  map[string]interface{}{"labels": map[string]interface{}{"app": "web"}},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	result.Hosts[0] = "c"
+	result.Meta["labels"].(map[string]interface{})["app"] = "api"
+	if src.Hosts[0] != "a" {
+		t.Fatal("hosts are shared")
+	}
+	if src.Meta["labels"].(map[string]interface{})["app"] != "web" {
+		t.Fatal("meta is shared")
+	}
+}
+
+func TestDeepCopy_nonPointer(t *testing.T) {
+	t.Parallel()
+
+	var result copyNode
+	if err := DeepCopy(copyNode{}, result); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestDecoder_InterfaceCopy(t *testing.T) {
+	t.Parallel()
+
+	type Config struct {
+		Extra interface{}
+	}
+
+	for _, policy := range []CopyPolicy{CopyShared, CopyDeep} {
+		extra := map[string]interface{}{"ports": []interface{}{80}}
+		input := map[string]interface{}{"extra": extra}
+
+		var result Config
+		config := &DecoderConfig{
+			InterfaceCopy: policy,
+			Result:        &result,
+		}
+
+		decoder, err := NewDecoder(config)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		if err := decoder.Decode(input); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		extra["name"] = "web"
+		extra["ports"].([]interface{})[0] = 8080
+
+		got := result.Extra.(map[string]interface{})
+		_, shared := got["name"]
+		if shared != (policy == CopyShared) {
+			t.Fatalf("policy %d: bad: %#v", policy, got)
+		}
+		if port := got["ports"].([]interface{})[0]; (port == 8080) != (policy == CopyShared) {
+			t.Fatalf("policy %d: bad port: %#v", policy, port)
+		}
+	}
+}
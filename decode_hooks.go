@@ -2,18 +2,27 @@ package mapstructure
 
 import (
 	"encoding"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"net"
+	"net/url"
 	"reflect"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 )
 
 // typedDecodeHook takes a raw DecodeHookFunc (an interface{}) and turns
 // it into the proper DecodeHookFunc type, such as DecodeHookFuncType.
 func typedDecodeHook(h DecodeHookFunc) DecodeHookFunc {
+	switch h.(type) {
+	case DecodeHookFuncType, DecodeHookFuncKind, DecodeHookFuncValue:
+		return h
+	}
+
 	// Create variables here so we can reference them with the reflect pkg
 	var f1 DecodeHookFuncType
 	var f2 DecodeHookFuncKind
@@ -138,6 +147,74 @@ func StringToTimeDurationHookFunc() DecodeHookFunc {
 	}
 }
 
+// durationUnits are the units of time.ParseDuration.
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"µs": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+}
+
+// MapToTimeDurationHookFunc returns a DecodeHookFunc that converts maps
+// with a "value" and a "unit" key, such as {"value": 30, "unit": "s"}, to
+// time.Duration. The unit is one of the units of time.ParseDuration and
+// the value is a number, which may have a fraction.
+func MapToTimeDurationHookFunc() DecodeHookFunc {
+	return func(f reflect.Value, t reflect.Value) (interface{}, error) {
+		if t.Type() != durationType || f.Kind() != reflect.Map {
+			return f.Interface(), nil
+		}
+		if kind := f.Type().Key().Kind(); kind != reflect.String && kind != reflect.Interface {
+			return f.Interface(), nil
+		}
+
+		var value, unit interface{}
+		for _, k := range f.MapKeys() {
+			key, _ := k.Interface().(string)
+			switch key {
+			case "value":
+				value = f.MapIndex(k).Interface()
+			case "unit":
+				unit = f.MapIndex(k).Interface()
+			default:
+				return nil, fmt.Errorf("unexpected duration key %q", k.Interface())
+			}
+		}
+
+		name, _ := unit.(string)
+		scale, ok := durationUnits[name]
+		if !ok {
+			return nil, fmt.Errorf("invalid duration unit %q", unit)
+		}
+
+		v := reflect.Indirect(reflect.ValueOf(value))
+		switch getKind(v) {
+		case reflect.Int:
+			return time.Duration(v.Int()) * scale, nil
+		case reflect.Uint:
+			return time.Duration(v.Uint()) * scale, nil
+		case reflect.Float32:
+			return time.Duration(v.Float() * float64(scale)), nil
+		}
+
+		if n, ok := value.(json.Number); ok {
+			if i, err := n.Int64(); err == nil {
+				return time.Duration(i) * scale, nil
+			}
+			f, err := n.Float64()
+			if err != nil {
+				return nil, err
+			}
+			return time.Duration(f * float64(scale)), nil
+		}
+
+		return nil, fmt.Errorf("invalid duration value %v", value)
+	}
+}
+
 // StringToIPHookFunc returns a DecodeHookFunc that converts
 // strings to net.IP
 func StringToIPHookFunc() DecodeHookFunc {
@@ -182,6 +259,91 @@ func StringToIPNetHookFunc() DecodeHookFunc {
 	}
 }
 
+// StringToURLHookFunc returns a DecodeHookFunc that converts
+// strings to url.URL and *url.URL
+func StringToURLHookFunc() DecodeHookFunc {
+	return func(
+		f reflect.Type,
+		t reflect.Type,
+		data interface{}) (interface{}, error) {
+		if f.Kind() != reflect.String {
+			return data, nil
+		}
+		if t != reflect.TypeOf(url.URL{}) && t != reflect.TypeOf(&url.URL{}) {
+			return data, nil
+		}
+
+		// Convert it by parsing
+		return url.Parse(data.(string))
+	}
+}
+
+// ByteSize is a number of bytes. StringToByteSizeHookFunc decodes it from
+// strings with a unit, such as "512KiB" or "1.5GB".
+type ByteSize uint64
+
+// byteSizeUnits maps the lowercase units of byte sizes to their number of
+// bytes. The SI units are powers of 1000, the IEC units powers of 1024.
+var byteSizeUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"k":   1e3,
+	"kb":  1e3,
+	"m":   1e6,
+	"mb":  1e6,
+	"g":   1e9,
+	"gb":  1e9,
+	"t":   1e12,
+	"tb":  1e12,
+	"p":   1e15,
+	"pb":  1e15,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+	"pib": 1 << 50,
+}
+
+// StringToByteSizeHookFunc returns a DecodeHookFunc that converts strings
+// to ByteSize. A string is a number, optionally with a fraction, followed
+// by a unit: B, KB, MB, GB, TB or PB for powers of 1000, or KiB, MiB, GiB,
+// TiB or PiB for powers of 1024. Units are case insensitive and a number
+// without a unit is a number of bytes.
+func StringToByteSizeHookFunc() DecodeHookFunc {
+	return func(
+		f reflect.Type,
+		t reflect.Type,
+		data interface{}) (interface{}, error) {
+		if f.Kind() != reflect.String {
+			return data, nil
+		}
+		if t != reflect.TypeOf(ByteSize(0)) {
+			return data, nil
+		}
+
+		raw := strings.TrimSpace(data.(string))
+		i := strings.IndexFunc(raw, unicode.IsLetter)
+		if i < 0 {
+			i = len(raw)
+		}
+		number, unit := strings.TrimSpace(raw[:i]), strings.ToLower(raw[i:])
+
+		mult, ok := byteSizeUnits[unit]
+		if !ok {
+			return nil, fmt.Errorf("invalid byte size %q: unknown unit %q", raw, raw[i:])
+		}
+		n, err := strconv.ParseFloat(number, 64)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid byte size %q", raw)
+		}
+		if n*mult >= math.MaxUint64 {
+			return nil, fmt.Errorf("byte size %q overflows %s", raw, t)
+		}
+
+		return ByteSize(n * mult), nil
+	}
+}
+
 // StringToTimeHookFunc returns a DecodeHookFunc that converts
 // strings to time.Time.
 func StringToTimeHookFunc(layout string) DecodeHookFunc {
@@ -201,11 +363,91 @@ func StringToTimeHookFunc(layout string) DecodeHookFunc {
 	}
 }
 
+// ExpandVariablesHookFunc returns a DecodeHookFunc that expands the
+// variables in string inputs with their values in vars before the strings
+// are converted, so that "${PORT}" can be decoded into an int. Variables
+// are written as ${name} or $name, where a name made of ASCII letters,
+// digits and underscores can't start with a digit. "$$" is a literal "$", as is
+// a "$" that isn't followed by a name. It is an error to use a variable
+// that isn't in vars.
+func ExpandVariablesHookFunc(vars map[string]string) DecodeHookFunc {
+	return func(
+		f reflect.Type,
+		t reflect.Type,
+		data interface{}) (interface{}, error) {
+		if f.Kind() != reflect.String {
+			return data, nil
+		}
+
+		str := reflect.ValueOf(data).String()
+		if !strings.Contains(str, "$") {
+			return data, nil
+		}
+
+		return expandVariables(str, vars)
+	}
+}
+
+func expandVariables(s string, vars map[string]string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		var name string
+		switch next := s[i+1]; {
+		case next == '$':
+			b.WriteByte('$')
+			i++
+			continue
+
+		case next == '{':
+			end := strings.IndexByte(s[i+2:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated variable in %q", s)
+			}
+			name = s[i+2 : i+2+end]
+			i += end + 2
+
+		case isNameStart(next):
+			end := i + 1
+			for end < len(s) && (isNameStart(s[end]) || '0' <= s[end] && s[end] <= '9') {
+				end++
+			}
+			name = s[i+1 : end]
+			i = end - 1
+
+		default:
+			b.WriteByte('$')
+			continue
+		}
+
+		value, ok := vars[name]
+		if !ok {
+			return "", fmt.Errorf("undefined variable %q in %q", name, s)
+		}
+		b.WriteString(value)
+	}
+
+	return b.String(), nil
+}
+
+// isNameStart reports whether c can start a $name variable.
+func isNameStart(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
 // WeaklyTypedHook is a DecodeHookFunc which adds support for weak typing to
 // the decoder.
 //
 // Note that this is significantly different from the WeaklyTypedInput option
-// of the DecoderConfig.
+// of the DecoderConfig. The conversions of WeaklyTypedInput are available
+// one by one as BoolToStringHook, NumberToStringHook, BytesToStringHook,
+// StringToNumberHook, BoolToNumberHook, NumberToBoolHook,
+// StringToBoolHook, SingleValueToSliceHook, SliceOfMapsMergeHook and
+// EmptyMapSliceHook, which can be combined with ComposeDecodeHookFunc.
 func WeaklyTypedHook(
 	f reflect.Kind,
 	t reflect.Kind,
@@ -237,6 +479,201 @@ func WeaklyTypedHook(
 	return data, nil
 }
 
+// BoolToStringHook is a DecodeHookFunc that converts bools to "1" for
+// true and "0" for false when the target is a string.
+func BoolToStringHook(from, to reflect.Value) (interface{}, error) {
+	if from.Kind() != reflect.Bool || to.Kind() != reflect.String {
+		return from.Interface(), nil
+	}
+
+	if from.Bool() {
+		return "1", nil
+	}
+	return "0", nil
+}
+
+// NumberToStringHook is a DecodeHookFunc that converts numbers to their
+// base 10 representation when the target is a string.
+func NumberToStringHook(from, to reflect.Value) (interface{}, error) {
+	if to.Kind() != reflect.String {
+		return from.Interface(), nil
+	}
+
+	switch getKind(from) {
+	case reflect.Int:
+		return strconv.FormatInt(from.Int(), 10), nil
+	case reflect.Uint:
+		return strconv.FormatUint(from.Uint(), 10), nil
+	case reflect.Float32:
+		return strconv.FormatFloat(from.Float(), 'f', -1, 64), nil
+	default:
+		return from.Interface(), nil
+	}
+}
+
+// BytesToStringHook is a DecodeHookFunc that converts byte slices to
+// strings when the target is a string.
+func BytesToStringHook(from, to reflect.Value) (interface{}, error) {
+	if from.Kind() != reflect.Slice || from.Type().Elem().Kind() != reflect.Uint8 || to.Kind() != reflect.String {
+		return from.Interface(), nil
+	}
+
+	return string(from.Bytes()), nil
+}
+
+// StringToNumberHook is a DecodeHookFunc that parses strings when the
+// target is a number. Integers can have a base prefix such as "0x", and
+// the empty string is 0. A time.Duration can also have a unit, as in "5s".
+func StringToNumberHook(from, to reflect.Value) (interface{}, error) {
+	if from.Kind() != reflect.String {
+		return from.Interface(), nil
+	}
+
+	str := from.String()
+	if str == "" {
+		str = "0"
+	}
+
+	var parsed interface{}
+	var err error
+	switch getKind(to) {
+	case reflect.Int:
+		parsed, err = strconv.ParseInt(str, 0, to.Type().Bits())
+		if err != nil && to.Type() == durationType {
+			parsed, err = time.ParseDuration(str)
+		}
+	case reflect.Uint:
+		parsed, err = strconv.ParseUint(str, 0, to.Type().Bits())
+	case reflect.Float32:
+		parsed, err = strconv.ParseFloat(str, to.Type().Bits())
+	default:
+		return from.Interface(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return reflect.ValueOf(parsed).Convert(to.Type()).Interface(), nil
+}
+
+// BoolToNumberHook is a DecodeHookFunc that converts bools to 1 for true
+// and 0 for false when the target is a number.
+func BoolToNumberHook(from, to reflect.Value) (interface{}, error) {
+	if from.Kind() != reflect.Bool {
+		return from.Interface(), nil
+	}
+
+	switch getKind(to) {
+	case reflect.Int, reflect.Uint, reflect.Float32:
+	default:
+		return from.Interface(), nil
+	}
+
+	n := 0
+	if from.Bool() {
+		n = 1
+	}
+	return reflect.ValueOf(n).Convert(to.Type()).Interface(), nil
+}
+
+// NumberToBoolHook is a DecodeHookFunc that converts numbers to true if
+// they aren't 0 when the target is a bool.
+func NumberToBoolHook(from, to reflect.Value) (interface{}, error) {
+	if to.Kind() != reflect.Bool {
+		return from.Interface(), nil
+	}
+
+	switch getKind(from) {
+	case reflect.Int:
+		return from.Int() != 0, nil
+	case reflect.Uint:
+		return from.Uint() != 0, nil
+	case reflect.Float32:
+		return from.Float() != 0, nil
+	default:
+		return from.Interface(), nil
+	}
+}
+
+// StringToBoolHook is a DecodeHookFunc that parses strings with
+// strconv.ParseBool when the target is a bool. The empty string is false.
+func StringToBoolHook(from, to reflect.Value) (interface{}, error) {
+	if from.Kind() != reflect.String || to.Kind() != reflect.Bool {
+		return from.Interface(), nil
+	}
+
+	if from.String() == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(from.String())
+}
+
+// SingleValueToSliceHook is a DecodeHookFunc that wraps a value that isn't
+// a slice or array in a slice when the target is a slice or array, so
+// that "4" can become []int{4} if the element is decoded weakly as well.
+func SingleValueToSliceHook(from, to reflect.Value) (interface{}, error) {
+	switch to.Kind() {
+	case reflect.Slice, reflect.Array:
+	default:
+		return from.Interface(), nil
+	}
+
+	switch from.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Invalid:
+		return from.Interface(), nil
+	}
+
+	return []interface{}{from.Interface()}, nil
+}
+
+// SliceOfMapsMergeHook is a DecodeHookFunc that merges a non-empty slice
+// of maps into a single map when the target is a map. Keys of later maps
+// override those of earlier ones.
+func SliceOfMapsMergeHook(from, to reflect.Value) (interface{}, error) {
+	if to.Kind() != reflect.Map {
+		return from.Interface(), nil
+	}
+	if (from.Kind() != reflect.Slice && from.Kind() != reflect.Array) || from.Len() == 0 {
+		return from.Interface(), nil
+	}
+
+	merged := make(map[interface{}]interface{})
+	for i := 0; i < from.Len(); i++ {
+		elem := reflect.Indirect(from.Index(i))
+		if elem.Kind() == reflect.Interface {
+			elem = reflect.Indirect(elem.Elem())
+		}
+		if elem.Kind() != reflect.Map {
+			return from.Interface(), nil
+		}
+
+		iter := elem.MapRange()
+		for iter.Next() {
+			merged[iter.Key().Interface()] = iter.Value().Interface()
+		}
+	}
+
+	return merged, nil
+}
+
+// EmptyMapSliceHook is a DecodeHookFunc that converts empty slices and
+// arrays to empty maps when the target is a map, and empty maps to empty
+// slices when the target is a slice.
+func EmptyMapSliceHook(from, to reflect.Value) (interface{}, error) {
+	switch {
+	case to.Kind() == reflect.Map &&
+		(from.Kind() == reflect.Slice || from.Kind() == reflect.Array) &&
+		from.Len() == 0:
+		return reflect.MakeMap(to.Type()).Interface(), nil
+
+	case to.Kind() == reflect.Slice && from.Kind() == reflect.Map && from.Len() == 0:
+		return reflect.MakeSlice(to.Type(), 0, 0).Interface(), nil
+
+	default:
+		return from.Interface(), nil
+	}
+}
+
 func RecursiveStructToMapHookFunc() DecodeHookFunc {
 	return func(f reflect.Value, t reflect.Value) (interface{}, error) {
 		if f.Kind() != reflect.Struct {
@@ -255,6 +692,40 @@ func RecursiveStructToMapHookFunc() DecodeHookFunc {
 	}
 }
 
+// DefaultHooks returns a DecodeHookFunc that composes the conversions most
+// configurations need, so that they can be enabled with one line:
+//
+//   - strings to time.Duration, as with StringToTimeDurationHookFunc
+//   - RFC 3339 strings to time.Time
+//   - strings to net.IP and net.IPNet
+//   - strings to url.URL and *url.URL
+//   - strings to ByteSize
+//   - strings to the types that implement encoding.TextUnmarshaler, such
+//     as netip.Addr and netip.Prefix
+//   - comma separated strings to slices, except []byte
+//
+// Configurations that need other conversions can compose these hooks
+// with their own using ComposeDecodeHookFunc.
+func DefaultHooks() DecodeHookFunc {
+	return ComposeDecodeHookFunc(
+		StringToTimeDurationHookFunc(),
+		StringToTimeHookFunc(time.RFC3339),
+		StringToIPHookFunc(),
+		StringToIPNetHookFunc(),
+		StringToURLHookFunc(),
+		StringToByteSizeHookFunc(),
+		TextUnmarshallerHookFunc(),
+		func(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+			// A string decodes into a []byte as is.
+			if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+				return data, nil
+			}
+
+			return DecodeHookExec(StringToSliceHookFunc(","), reflect.ValueOf(data), reflect.New(t).Elem())
+		},
+	)
+}
+
 // TextUnmarshallerHookFunc returns a DecodeHookFunc that applies
 // strings to the UnmarshalText function, when the target type
 // implements the encoding.TextUnmarshaler interface
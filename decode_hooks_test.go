@@ -5,6 +5,8 @@ import (
 	"errors"
 	"math/big"
 	"net"
+	"net/netip"
+	"net/url"
 	"reflect"
 	"testing"
 	"time"
@@ -272,6 +274,63 @@ func TestStringToTimeDurationHookFunc(t *testing.T) {
 	}
 }
 
+func TestMapToTimeDurationHookFunc(t *testing.T) {
+	f := MapToTimeDurationHookFunc()
+
+	timeValue := reflect.ValueOf(time.Duration(5))
+	strValue := reflect.ValueOf("")
+	cases := []struct {
+		f, t   reflect.Value
+		result interface{}
+		err    bool
+	}{
+		{reflect.ValueOf(map[string]interface{}{"value": 30, "unit": "s"}), timeValue, 30 * time.Second, false},
+		{reflect.ValueOf(map[string]interface{}{"value": 1.5, "unit": "h"}), timeValue, 90 * time.Minute, false},
+		{reflect.ValueOf(map[string]interface{}{"value": json.Number("250"), "unit": "ms"}), timeValue, 250 * time.Millisecond, false},
+		{reflect.ValueOf(map[interface{}]interface{}{"value": uint8(2), "unit": "m"}), timeValue, 2 * time.Minute, false},
+		{reflect.ValueOf(map[string]interface{}{"value": 30, "unit": "fortnights"}), timeValue, nil, true},
+		{reflect.ValueOf(map[string]interface{}{"value": "30", "unit": "s"}), timeValue, nil, true},
+		{reflect.ValueOf(map[string]interface{}{"value": 30, "unit": "s", "scale": 2}), timeValue, nil, true},
+		{reflect.ValueOf(map[string]interface{}{"value": 30, "unit": "s"}), strValue, map[string]interface{}{"value": 30, "unit": "s"}, false},
+	}
+
+	for i, tc := range cases {
+		actual, err := DecodeHookExec(f, tc.f, tc.t)
+		if tc.err != (err != nil) {
+			t.Fatalf("case %d: expected err %#v", i, tc.err)
+		}
+		if !reflect.DeepEqual(actual, tc.result) {
+			t.Fatalf(
+				"case %d: expected %#v, got %#v",
+				i, tc.result, actual)
+		}
+	}
+
+	type Job struct {
+		Timeout time.Duration
+	}
+
+	var result Job
+	config := &DecoderConfig{
+		DecodeHook: MapToTimeDurationHookFunc(),
+		Result:     &result,
+	}
+	decoder, err := NewDecoder(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	input := map[string]interface{}{
+		"timeout": map[string]interface{}{"value": 5, "unit": "m"},
+	}
+	if err := decoder.Decode(input); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if result.Timeout != 5*time.Minute {
+		t.Fatalf("bad: %s", result.Timeout)
+	}
+}
+
 func TestStringToTimeHookFunc(t *testing.T) {
 	strValue := reflect.ValueOf("5")
 	timeValue := reflect.ValueOf(time.Time{})
@@ -569,3 +628,303 @@ func TestTextUnmarshallerHookFunc(t *testing.T) {
 		}
 	}
 }
+
+func TestStringToURLHookFunc(t *testing.T) {
+	strValue := reflect.ValueOf("5")
+	u := &url.URL{Scheme: "https", Host: "example.com", Path: "/api"}
+
+	cases := []struct {
+		f, t   reflect.Value
+		result interface{}
+		err    bool
+	}{
+		{reflect.ValueOf("https://example.com/api"), reflect.ValueOf(url.URL{}), u, false},
+		{reflect.ValueOf("https://example.com/api"), reflect.ValueOf(&url.URL{}), u, false},
+		{reflect.ValueOf("%zz"), reflect.ValueOf(url.URL{}), (*url.URL)(nil), true},
+		{strValue, strValue, "5", false},
+	}
+
+	for i, tc := range cases {
+		f := StringToURLHookFunc()
+		actual, err := DecodeHookExec(f, tc.f, tc.t)
+		if tc.err != (err != nil) {
+			t.Fatalf("case %d: expected err %#v", i, tc.err)
+		}
+		if !reflect.DeepEqual(actual, tc.result) {
+			t.Fatalf(
+				"case %d: expected %#v, got %#v",
+				i, tc.result, actual)
+		}
+	}
+}
+
+func TestStringToByteSizeHookFunc(t *testing.T) {
+	sizeValue := reflect.ValueOf(ByteSize(0))
+
+	cases := []struct {
+		f      string
+		result interface{}
+		err    bool
+	}{
+		{"512", ByteSize(512), false},
+		{"10B", ByteSize(10), false},
+		{"1.5GB", ByteSize(1500000000), false},
+		{"512 KiB", ByteSize(512 * 1024), false},
+		{"2mib", ByteSize(2 << 20), false},
+		{"1k", ByteSize(1000), false},
+		{"5XB", nil, true},
+		{"-1MB", nil, true},
+		{"MB", nil, true},
+		{"20000PB", nil, true},
+	}
+
+	for i, tc := range cases {
+		f := StringToByteSizeHookFunc()
+		actual, err := DecodeHookExec(f, reflect.ValueOf(tc.f), sizeValue)
+		if tc.err != (err != nil) {
+			t.Fatalf("case %d: expected err %#v, got %v", i, tc.err, err)
+		}
+		if !reflect.DeepEqual(actual, tc.result) {
+			t.Fatalf(
+				"case %d: expected %#v, got %#v",
+				i, tc.result, actual)
+		}
+	}
+
+	strValue := reflect.ValueOf("5MB")
+	if actual, err := DecodeHookExec(StringToByteSizeHookFunc(), strValue, reflect.ValueOf(0)); err != nil || actual != "5MB" {
+		t.Fatalf("expected other types to be left alone, got %#v, %v", actual, err)
+	}
+}
+
+func TestDefaultHooks(t *testing.T) {
+	type Config struct {
+		Timeout  time.Duration
+		Started  time.Time
+		IP       net.IP
+		Network  net.IPNet
+		Addr     netip.Addr
+		Endpoint *url.URL
+		MaxSize  ByteSize
+		Level    *big.Int
+		Tags     []string
+		Raw      []byte
+	}
+
+	input := map[string]interface{}{
+		"timeout":  "5s",
+		"started":  "2024-01-02T03:04:05Z",
+		"ip":       "10.0.0.1",
+		"network":  "10.0.0.0/8",
+		"addr":     "::1",
+		"endpoint": "https://example.com/api",
+		"maxsize":  "64MiB",
+		"level":    "42",
+		"tags":     "a,b,c",
+		"raw":      "bytes",
+	}
+
+	// WeaklyTypedInput decodes the string into the []byte, which the
+	// hooks must leave alone.
+	var result Config
+	decoder, err := NewDecoder(&DecoderConfig{
+		DecodeHook:       DefaultHooks(),
+		WeaklyTypedInput: true,
+		Result:           &result,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := decoder.Decode(input); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := Config{
+		Timeout: 5 * time.Second,
+		Started: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		IP:      net.ParseIP("10.0.0.1"),
+		Network: net.IPNet{
+			IP:   net.IP{10, 0, 0, 0},
+			Mask: net.IPv4Mask(0xff, 0, 0, 0),
+		},
+		Addr:     netip.MustParseAddr("::1"),
+		Endpoint: &url.URL{Scheme: "https", Host: "example.com", Path: "/api"},
+		MaxSize:  64 << 20,
+		Level:    big.NewInt(42),
+		Tags:     []string{"a", "b", "c"},
+		Raw:      []byte("bytes"),
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, result)
+	}
+}
+
+func TestWeakConverterHooks(t *testing.T) {
+	strValue := reflect.ValueOf("")
+	int8Value := reflect.ValueOf(int8(0))
+	uintValue := reflect.ValueOf(uint(0))
+	floatValue := reflect.ValueOf(float32(0))
+	boolValue := reflect.ValueOf(false)
+	durationValue := reflect.ValueOf(time.Duration(0))
+	sliceValue := reflect.ValueOf([]int{})
+	mapValue := reflect.ValueOf(map[string]int{})
+
+	cases := []struct {
+		hook   DecodeHookFunc
+		f, t   reflect.Value
+		result interface{}
+		err    bool
+	}{
+		{BoolToStringHook, reflect.ValueOf(true), strValue, "1", false},
+		{BoolToStringHook, reflect.ValueOf(false), strValue, "0", false},
+		{BoolToStringHook, reflect.ValueOf(true), int8Value, true, false},
+		{NumberToStringHook, reflect.ValueOf(int64(-7)), strValue, "-7", false},
+		{NumberToStringHook, reflect.ValueOf(uint16(7)), strValue, "7", false},
+		{NumberToStringHook, reflect.ValueOf(1.5), strValue, "1.5", false},
+		{NumberToStringHook, reflect.ValueOf(true), strValue, true, false},
+		{BytesToStringHook, reflect.ValueOf([]byte("foo")), strValue, "foo", false},
+		{StringToNumberHook, reflect.ValueOf("0x10"), int8Value, int8(16), false},
+		{StringToNumberHook, reflect.ValueOf("300"), int8Value, nil, true},
+		{StringToNumberHook, reflect.ValueOf(""), uintValue, uint(0), false},
+		{StringToNumberHook, reflect.ValueOf("1.5"), floatValue, float32(1.5), false},
+		{StringToNumberHook, reflect.ValueOf("5s"), durationValue, 5 * time.Second, false},
+		{StringToNumberHook, reflect.ValueOf("5"), durationValue, time.Duration(5), false},
+		{StringToNumberHook, reflect.ValueOf("5x"), durationValue, nil, true},
+		{StringToNumberHook, reflect.ValueOf("1"), boolValue, "1", false},
+		{BoolToNumberHook, reflect.ValueOf(true), floatValue, float32(1), false},
+		{BoolToNumberHook, reflect.ValueOf(false), uintValue, uint(0), false},
+		{NumberToBoolHook, reflect.ValueOf(int16(2)), boolValue, true, false},
+		{NumberToBoolHook, reflect.ValueOf(0.0), boolValue, false, false},
+		{StringToBoolHook, reflect.ValueOf("T"), boolValue, true, false},
+		{StringToBoolHook, reflect.ValueOf(""), boolValue, false, false},
+		{StringToBoolHook, reflect.ValueOf("yes"), boolValue, nil, true},
+		{SingleValueToSliceHook, reflect.ValueOf("a"), sliceValue, []interface{}{"a"}, false},
+		{SingleValueToSliceHook, reflect.ValueOf([]string{"a"}), sliceValue, []string{"a"}, false},
+		{SingleValueToSliceHook, reflect.ValueOf("a"), strValue, "a", false},
+		{
+			SliceOfMapsMergeHook,
+			reflect.ValueOf([]interface{}{
+				map[string]interface{}{"a": 1, "b": 1},
+				map[string]interface{}{"b": 2},
+			}),
+			mapValue,
+			map[interface{}]interface{}{"a": 1, "b": 2},
+			false,
+		},
+		{SliceOfMapsMergeHook, reflect.ValueOf([]int{1}), mapValue, []int{1}, false},
+		{EmptyMapSliceHook, reflect.ValueOf([]string{}), mapValue, map[string]int{}, false},
+		{EmptyMapSliceHook, reflect.ValueOf(map[string]string{}), sliceValue, []int{}, false},
+		{EmptyMapSliceHook, reflect.ValueOf([]string{"a"}), mapValue, []string{"a"}, false},
+	}
+
+	for i, tc := range cases {
+		actual, err := DecodeHookExec(tc.hook, tc.f, tc.t)
+		if tc.err != (err != nil) {
+			t.Fatalf("case %d: expected err %#v, got %v", i, tc.err, err)
+		}
+		if !tc.err && !reflect.DeepEqual(actual, tc.result) {
+			t.Fatalf(
+				"case %d: expected %#v, got %#v",
+				i, tc.result, actual)
+		}
+	}
+}
+
+func TestWeakConverterHooks_compose(t *testing.T) {
+	type Config struct {
+		Port    int
+		Verbose bool
+		Name    string
+		Hosts   []string
+	}
+
+	input := map[string]interface{}{
+		"port":    "8080",
+		"verbose": 1,
+		"name":    42,
+		"hosts":   "a",
+	}
+
+	var result Config
+	decoder, err := NewDecoder(&DecoderConfig{
+		DecodeHook: ComposeDecodeHookFunc(
+			StringToNumberHook,
+			NumberToBoolHook,
+			SingleValueToSliceHook,
+		),
+		Result: &result,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Numbers aren't converted to strings without NumberToStringHook.
+	if err := decoder.Decode(input); err == nil {
+		t.Fatal("expected error")
+	}
+
+	delete(input, "name")
+	if err := decoder.Decode(input); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := Config{Port: 8080, Verbose: true, Hosts: []string{"a"}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+}
+
+func TestExpandVariablesHookFunc(t *testing.T) {
+	f := ExpandVariablesHookFunc(map[string]string{
+		"HOST":  "localhost",
+		"PORT":  "8080",
+		"_name": "web",
+	})
+
+	strValue := reflect.ValueOf("")
+	cases := []struct {
+		f, t   reflect.Value
+		result interface{}
+		err    bool
+	}{
+		{reflect.ValueOf("${HOST}:$PORT"), strValue, "localhost:8080", false},
+		{reflect.ValueOf("${PORT}"), reflect.ValueOf(0), "8080", false},
+		{reflect.ValueOf("$_name-1"), strValue, "web-1", false},
+		{reflect.ValueOf("$$HOST costs $5$"), strValue, "$HOST costs $5$", false},
+		{reflect.ValueOf("$HOSTé costs $é"), strValue, "localhosté costs $é", false},
+		{reflect.ValueOf("no variables"), strValue, "no variables", false},
+		{reflect.ValueOf("$MISSING"), strValue, "", true},
+		{reflect.ValueOf("${HOST"), strValue, "", true},
+		{reflect.ValueOf(5), strValue, 5, false},
+	}
+
+	for i, tc := range cases {
+		actual, err := DecodeHookExec(f, tc.f, tc.t)
+		if tc.err != (err != nil) {
+			t.Fatalf("case %d: expected err %#v, got %v", i, tc.err, err)
+		}
+		if !tc.err && !reflect.DeepEqual(actual, tc.result) {
+			t.Fatalf(
+				"case %d: expected %#v, got %#v",
+				i, tc.result, actual)
+		}
+	}
+
+	var result struct {
+		Port int
+	}
+	decoder, err := NewDecoder(&DecoderConfig{
+		DecodeHook:       f,
+		WeaklyTypedInput: true,
+		Result:           &result,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := decoder.Decode(map[string]interface{}{"port": "${PORT}"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if result.Port != 8080 {
+		t.Fatalf("bad: %#v", result)
+	}
+}
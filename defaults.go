@@ -0,0 +1,41 @@
+package mapstructure
+
+import "reflect"
+
+// DefaultSetter is implemented by types that set their own default values.
+// When a struct is decoded from a map, SetDefaults is called on a pointer
+// to it before any field is decoded, so the input overrides the defaults.
+// If SetDefaultsAfterDecode is set in the DecoderConfig, it is called
+// once the struct has been decoded instead, and the fields that were set
+// from the input are restored afterwards, so the input still wins.
+type DefaultSetter interface {
+	SetDefaults()
+}
+
+// setDefaults calls SetDefaults on the struct val if it is addressable
+// and implements DefaultSetter.
+func setDefaults(val reflect.Value) {
+	if !val.CanAddr() {
+		return
+	}
+
+	if setter, ok := val.Addr().Interface().(DefaultSetter); ok {
+		setter.SetDefaults()
+	}
+}
+
+// setDefaultsKeeping is setDefaults, but restores the values of the fields
+// in decoded afterwards.
+func setDefaultsKeeping(val reflect.Value, decoded []reflect.Value) {
+	saved := make([]reflect.Value, len(decoded))
+	for i, field := range decoded {
+		saved[i] = reflect.New(field.Type()).Elem()
+		saved[i].Set(field)
+	}
+
+	setDefaults(val)
+
+	for i, field := range decoded {
+		field.Set(saved[i])
+	}
+}
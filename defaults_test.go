@@ -0,0 +1,84 @@
+package mapstructure
+
+import (
+	"reflect"
+	"testing"
+)
+
+type defaultsServer struct {
+	Host    string
+	Port    int
+	Backend defaultsBackend
+}
+
+func (s *defaultsServer) SetDefaults() {
+	if s.Host == "" {
+		s.Host = "localhost"
+	}
+	if s.Port == 0 {
+		s.Port = 8080
+	}
+}
+
+type defaultsBackend struct {
+	Retries int
+}
+
+func (b *defaultsBackend) SetDefaults() {
+	b.Retries = 3
+}
+
+func TestDecode_DefaultSetter(t *testing.T) {
+	t.Parallel()
+
+	input := map[string]interface{}{
+		"port":    9090,
+		"backend": map[string]interface{}{},
+	}
+
+	var result defaultsServer
+	if err := Decode(input, &result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := defaultsServer{
+		Host:    "localhost",
+		Port:    9090,
+		Backend: defaultsBackend{Retries: 3},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+}
+
+func TestDecode_DefaultSetterAfterDecode(t *testing.T) {
+	t.Parallel()
+
+	input := map[string]interface{}{
+		"port":    9090,
+		"backend": map[string]interface{}{"retries": 5},
+	}
+
+	var result defaultsServer
+	decoder, err := NewDecoder(&DecoderConfig{
+		SetDefaultsAfterDecode: true,
+		Result:                 &result,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := decoder.Decode(input); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The backend's SetDefaults doesn't check for unset fields, but the
+	// input still wins.
+	expected := defaultsServer{
+		Host:    "localhost",
+		Port:    9090,
+		Backend: defaultsBackend{Retries: 5},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+}
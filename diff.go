@@ -0,0 +1,133 @@
+package mapstructure
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+)
+
+// Change is a value that decoding an input would change, as reported by
+// Diff.
+type Change struct {
+	// Path is the flattened path of the value, such as "server.ports[0]",
+	// with the segments joined by the FlatKeySeparator of the config or
+	// "." if it has none.
+	Path string
+
+	// Old is the current value, or nil if there is none.
+	Old interface{}
+
+	// New is the value after decoding, or nil if it was removed.
+	New interface{}
+}
+
+// secretValue wraps the value of a field with the "secret" tag option, or
+// a value inside it, when Diff encodes a struct, so that it is reported as
+// redacted.
+type secretValue struct {
+	value interface{}
+}
+
+// reported returns the value that a Change reports for v.
+func reported(v interface{}) interface{} {
+	if _, ok := v.(secretValue); ok {
+		return redacted
+	}
+
+	return v
+}
+
+// unwrapSecret returns the value wrapped in v if it is a secretValue.
+func unwrapSecret(v interface{}) interface{} {
+	if secret, ok := v.(secretValue); ok {
+		return secret.value
+	}
+
+	return v
+}
+
+// Diff reports the changes that decoding input into current would make,
+// without modifying current. current is a struct, or a pointer to one,
+// and is decoded using config, so the same matching and conversion rules
+// apply as with Decode. The Result of config is ignored.
+//
+// The values before and after decoding are encoded and flattened the same
+// way as when decoding a struct into a map with FlatKeySeparator set, and
+// a Change is returned, sorted by path, for every path whose value
+// differs. The values of fields with the "secret" tag option, and the
+// values inside them, are compared but reported as "<redacted>", like in
+// the errors of decoding them.
+func Diff(current interface{}, input map[string]interface{}, config *DecoderConfig) ([]Change, error) {
+	c := copyConfig(config)
+
+	currentVal := reflect.Indirect(reflect.ValueOf(current))
+	if currentVal.Kind() != reflect.Struct {
+		return nil, errors.New("current must be a struct or a pointer to one")
+	}
+
+	updated := reflect.New(currentVal.Type())
+	if err := DeepCopy(currentVal.Interface(), updated.Interface()); err != nil {
+		return nil, err
+	}
+
+	c.Result = updated.Interface()
+	decoder, err := NewDecoder(c)
+	if err != nil {
+		return nil, err
+	}
+	if err := decoder.Decode(input); err != nil {
+		return nil, err
+	}
+
+	// The values are only encoded from here on, which mustn't show up in
+	// the metadata of the decoding above.
+	decoder.config.Metadata = nil
+	decoder.markSecrets = true
+	sep := c.FlatKeySeparator
+	if sep == "" {
+		sep = "."
+	}
+
+	before, err := decoder.flatten(currentVal.Interface(), sep)
+	if err != nil {
+		return nil, err
+	}
+	after, err := decoder.flatten(updated.Elem().Interface(), sep)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	for path, old := range before {
+		if value, ok := after[path]; !ok || !reflect.DeepEqual(unwrapSecret(old), unwrapSecret(value)) {
+			changes = append(changes, Change{Path: path, Old: reported(old), New: reported(value)})
+		}
+	}
+	for path, value := range after {
+		if _, ok := before[path]; !ok {
+			changes = append(changes, Change{Path: path, New: reported(value)})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+
+	return changes, nil
+}
+
+// flatten encodes v and flattens it into paths joined with sep.
+func (d *Decoder) flatten(v interface{}, sep string) (map[string]interface{}, error) {
+	encode := func(v interface{}) (interface{}, error) {
+		var m map[string]interface{}
+		err := d.decode("", v, reflect.ValueOf(&m).Elem())
+		return m, err
+	}
+
+	flat := make(map[string]interface{})
+	if err := flattenValue(flat, "", v, sep, encode); err != nil {
+		return nil, err
+	}
+
+	return flat, nil
+}
@@ -0,0 +1,121 @@
+package mapstructure
+
+import (
+	"reflect"
+	"testing"
+)
+
+type diffConfig struct {
+	Name    string            `mapstructure:"name"`
+	Port    int               `mapstructure:"port"`
+	Hosts   []string          `mapstructure:"hosts"`
+	Labels  map[string]string `mapstructure:"labels"`
+	Backend diffBackend       `mapstructure:"backend"`
+}
+
+type diffBackend struct {
+	Timeout int `mapstructure:"timeout"`
+}
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	current := &diffConfig{
+		Name:    "web",
+		Port:    80,
+		Hosts:   []string{"a", "b"},
+		Labels:  map[string]string{"app": "web"},
+		Backend: diffBackend{Timeout: 10},
+	}
+
+	input := map[string]interface{}{
+		"name":    "web",
+		"port":    "8080",
+		"hosts":   []string{"a"},
+		"labels":  map[string]string{"tier": "front"},
+		"backend": map[string]interface{}{"timeout": 30},
+	}
+
+	changes, err := Diff(current, input, &DecoderConfig{WeaklyTypedInput: true})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []Change{
+		{Path: "backend.timeout", Old: 10, New: 30},
+		{Path: "hosts[1]", Old: "b"},
+		{Path: "labels.tier", New: "front"},
+		{Path: "port", Old: 80, New: 8080},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("bad: %#v", changes)
+	}
+
+	// current must not be modified
+	if current.Port != 80 || len(current.Hosts) != 2 || current.Labels["tier"] != "" {
+		t.Fatalf("current changed: %#v", current)
+	}
+}
+
+func TestDiff_secret(t *testing.T) {
+	t.Parallel()
+
+	type Credentials struct {
+		User     string `mapstructure:"user"`
+		Password string `mapstructure:"password"`
+	}
+	type Config struct {
+		Token       string      `mapstructure:"token,secret"`
+		Key         string      `mapstructure:"key,secret"`
+		Credentials Credentials `mapstructure:"credentials,secret"`
+		Port        int         `mapstructure:"port"`
+	}
+
+	current := Config{Token: "old", Key: "same", Credentials: Credentials{User: "admin", Password: "hunter2"}}
+	input := map[string]interface{}{
+		"token":       "new",
+		"key":         "same",
+		"credentials": map[string]interface{}{"password": "hunter3"},
+		"port":        80,
+	}
+
+	changes, err := Diff(current, input, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []Change{
+		{Path: "credentials.password", Old: "<redacted>", New: "<redacted>"},
+		{Path: "port", Old: 0, New: 80},
+		{Path: "token", Old: "<redacted>", New: "<redacted>"},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("bad: %#v", changes)
+	}
+}
+
+func TestDiff_noChanges(t *testing.T) {
+	t.Parallel()
+
+	current := diffConfig{Name: "web", Port: 80}
+	changes, err := Diff(current, map[string]interface{}{"port": 80}, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(changes) != 0 {
+		t.Fatalf("bad: %#v", changes)
+	}
+}
+
+func TestDiff_error(t *testing.T) {
+	t.Parallel()
+
+	_, err := Diff(&diffConfig{}, map[string]interface{}{"port": "http"}, nil)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	if _, err := Diff(map[string]interface{}{}, nil, nil); err == nil {
+		t.Fatal("expected error")
+	}
+}
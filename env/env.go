@@ -0,0 +1,148 @@
+// Package env decodes environment variables into Go structures using
+// mapstructure.
+//
+// Variable names are split on a separator to build a nested map, which is
+// then decoded like any other input. For example, with the prefix "APP"
+// and the default separator "_", the environment
+//
+//	APP_NAME=demo
+//	APP_DB_HOST=localhost
+//	APP_DB_PORT=5432
+//
+// becomes the input
+//
+//	map[string]interface{}{
+//	    "name": "demo",
+//	    "db": map[string]interface{}{
+//	        "host": "localhost",
+//	        "port": "5432",
+//	    },
+//	}
+//
+// Since every value is a string, Decode enables WeaklyTypedInput so that
+// values can be decoded into numbers, booleans and slices.
+package env
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// Config is the configuration used to decode environment variables.
+type Config struct {
+	// Prefix selects the variables to decode. Only variables named
+	// prefix + Separator + key are decoded, with the prefix removed. If
+	// Prefix is empty, all variables are decoded.
+	Prefix string
+
+	// Separator separates the levels of nesting in variable names. It
+	// defaults to "_".
+	Separator string
+
+	// Environ holds the variables as "key=value" strings. It defaults to
+	// os.Environ().
+	Environ []string
+
+	// Decoder is the configuration of the decoder, including its Result
+	// and DecodeHook.
+	Decoder *mapstructure.DecoderConfig
+}
+
+// Decode decodes the environment variables starting with prefix into
+// output, which must be a pointer to a struct or map. Weakly typed input
+// is enabled. Use DecodeConfig for more control.
+func Decode(prefix string, output interface{}) error {
+	return DecodeConfig(&Config{
+		Prefix: prefix,
+		Decoder: &mapstructure.DecoderConfig{
+			Result:           output,
+			WeaklyTypedInput: true,
+		},
+	})
+}
+
+// DecodeConfig decodes the environment variables selected by config into
+// config.Decoder.Result.
+func DecodeConfig(config *Config) error {
+	if config.Decoder == nil {
+		return fmt.Errorf("env: Decoder must be set")
+	}
+
+	input, err := Map(config)
+	if err != nil {
+		return err
+	}
+
+	decoder, err := mapstructure.NewDecoder(config.Decoder)
+	if err != nil {
+		return err
+	}
+
+	return decoder.Decode(input)
+}
+
+// Map builds the nested map of the environment variables selected by
+// config. Keys are lowercased. It is an error for a variable to be both a
+// value and a parent of other values, such as APP_DB and APP_DB_HOST.
+func Map(config *Config) (map[string]interface{}, error) {
+	sep := config.Separator
+	if sep == "" {
+		sep = "_"
+	}
+
+	environ := config.Environ
+	if environ == nil {
+		environ = os.Environ()
+	}
+
+	prefix := ""
+	if config.Prefix != "" {
+		prefix = strings.ToLower(config.Prefix + sep)
+	}
+
+	result := make(map[string]interface{})
+	for _, kv := range environ {
+		idx := strings.Index(kv, "=")
+		if idx <= 0 {
+			continue
+		}
+
+		name, value := strings.ToLower(kv[:idx]), kv[idx+1:]
+		if !strings.HasPrefix(name, prefix) || len(name) == len(prefix) {
+			continue
+		}
+
+		if err := insert(result, strings.Split(name[len(prefix):], sep), value); err != nil {
+			return nil, fmt.Errorf("env: %s: %s", kv[:idx], err)
+		}
+	}
+
+	return result, nil
+}
+
+func insert(m map[string]interface{}, keys []string, value string) error {
+	for i, key := range keys[:len(keys)-1] {
+		switch existing := m[key].(type) {
+		case nil:
+			child := make(map[string]interface{})
+			m[key] = child
+			m = child
+		case map[string]interface{}:
+			m = existing
+		default:
+			return fmt.Errorf("conflicts with the value of %s",
+				strings.Join(keys[:i+1], "."))
+		}
+	}
+
+	key := keys[len(keys)-1]
+	if _, ok := m[key].(map[string]interface{}); ok {
+		return fmt.Errorf("conflicts with nested values")
+	}
+	m[key] = value
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package env
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+func TestMap(t *testing.T) {
+	t.Parallel()
+
+	config := &Config{
+		Prefix: "APP",
+		Environ: []string{
+			"APP_NAME=demo",
+			"APP_DB_HOST=localhost",
+			"APP_DB_PORT=5432",
+			"APP_EMPTY=",
+			"APP_=ignored",
+			"OTHER_NAME=ignored",
+			"APPNAME=ignored",
+		},
+	}
+
+	actual, err := Map(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[string]interface{}{
+		"name":  "demo",
+		"empty": "",
+		"db": map[string]interface{}{
+			"host": "localhost",
+			"port": "5432",
+		},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestMap_Conflict(t *testing.T) {
+	t.Parallel()
+
+	environs := [][]string{
+		{"APP_DB=x", "APP_DB_HOST=y"},
+		{"APP_DB_HOST=y", "APP_DB=x"},
+	}
+
+	for _, environ := range environs {
+		_, err := Map(&Config{Prefix: "APP", Environ: environ})
+		if err == nil {
+			t.Fatalf("expected error for %v", environ)
+		}
+	}
+}
+
+func TestDecodeConfig(t *testing.T) {
+	t.Parallel()
+
+	type DB struct {
+		Host    string
+		Port    int
+		Timeout time.Duration
+	}
+
+	type AppConfig struct {
+		Name  string
+		Debug bool
+		Tags  []string
+		DB    DB `mapstructure:"database"`
+	}
+
+	var result AppConfig
+	err := DecodeConfig(&Config{
+		Prefix:    "APP",
+		Separator: "__",
+		Environ: []string{
+			"APP__NAME=demo",
+			"APP__DEBUG=true",
+			"APP__TAGS=a,b",
+			"APP__DATABASE__HOST=localhost",
+			"APP__DATABASE__PORT=5432",
+			"APP__DATABASE__TIMEOUT=5s",
+		},
+		Decoder: &mapstructure.DecoderConfig{
+			Result:           &result,
+			WeaklyTypedInput: true,
+			DecodeHook: mapstructure.ComposeDecodeHookFunc(
+				mapstructure.StringToTimeDurationHookFunc(),
+				mapstructure.StringToSliceHookFunc(","),
+			),
+		},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := AppConfig{
+		Name:  "demo",
+		Debug: true,
+		Tags:  []string{"a", "b"},
+		DB: DB{
+			Host:    "localhost",
+			Port:    5432,
+			Timeout: 5 * time.Second,
+		},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	key := "MAPSTRUCTURE_ENV_TEST_PORT"
+	os.Setenv(key, "8080")
+	defer os.Unsetenv(key)
+
+	var result struct {
+		Port int
+	}
+	if err := Decode(strings.TrimSuffix(key, "_PORT"), &result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if result.Port != 8080 {
+		t.Fatalf("bad: %#v", result)
+	}
+}
@@ -3,26 +3,114 @@ package mapstructure
 import (
 	"errors"
 	"fmt"
+	"reflect"
 	"sort"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // Error implements the error interface and can represents multiple
 // errors that occur in the course of a single decode.
+//
+// Errors are listed in the order they were encountered: struct fields in
+// declaration order, slice elements by index and map entries by key.
 type Error struct {
 	Errors []string
+
+	// errs holds the original errors behind Errors, in the same order,
+	// so that they can be inspected with errors.Is and errors.As.
+	errs []error
+
+	formatter DecodingErrorsFormatter
+}
+
+func newError(errs []error) *Error {
+	points := make([]string, len(errs))
+	for i, err := range errs {
+		points[i] = err.Error()
+	}
+
+	return &Error{Errors: points, errs: errs}
 }
 
 func (e *Error) Error() string {
-	points := make([]string, len(e.Errors))
-	for i, err := range e.Errors {
+	formatter := e.formatter
+	if formatter == nil {
+		formatter = DefaultDecodingErrorsFormatter
+	}
+
+	return formatter(e.Errors)
+}
+
+// DecodingErrorsFormatter renders the messages of the errors that occurred
+// during a single decode into the message of an Error.
+type DecodingErrorsFormatter func(errs []string) string
+
+// DefaultDecodingErrorsFormatter lists the errors in the order they were
+// encountered.
+func DefaultDecodingErrorsFormatter(errs []string) string {
+	points := make([]string, len(errs))
+	for i, err := range errs {
 		points[i] = fmt.Sprintf("* %s", err)
 	}
 
-	sort.Strings(points)
 	return fmt.Sprintf(
 		"%d error(s) decoding:\n\n%s",
-		len(e.Errors), strings.Join(points, "\n"))
+		len(errs), strings.Join(points, "\n"))
+}
+
+// SortedDecodingErrorsFormatter is like DefaultDecodingErrorsFormatter,
+// but sorts the errors lexically.
+func SortedDecodingErrorsFormatter(errs []string) string {
+	sorted := make([]string, len(errs))
+	copy(sorted, errs)
+	sort.Strings(sorted)
+
+	return DefaultDecodingErrorsFormatter(sorted)
+}
+
+// ValueFormatter renders the input values that error messages include in
+// place of the {value} placeholder. Values that the underlying error quotes
+// in place of {err}, as the strconv and time parsers do, are rendered with
+// it too.
+type ValueFormatter func(v interface{}) string
+
+// DefaultValueFormatter renders values in full with fmt's %v verb.
+func DefaultValueFormatter(v interface{}) string {
+	return fmt.Sprintf("%v", v)
+}
+
+// TruncatingValueFormatter returns a ValueFormatter that renders values
+// like DefaultValueFormatter, but cuts them after max bytes, marking the
+// cut with "...", so that a huge map or string doesn't flood the logs. A
+// negative max is treated as zero.
+func TruncatingValueFormatter(max int) ValueFormatter {
+	if max < 0 {
+		max = 0
+	}
+
+	return func(v interface{}) string {
+		s := DefaultValueFormatter(v)
+		if len(s) <= max {
+			return s
+		}
+
+		// Never cut a rune in half.
+		cut := max
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+
+		return s[:cut] + "..."
+	}
+}
+
+// TypeValueFormatter renders only the type of values, such as
+// "<map[string]interface {}>", for logs that must not include the input
+// at all.
+func TypeValueFormatter(v interface{}) string {
+	return fmt.Sprintf("<%T>", v)
 }
 
 // WrappedErrors implements the errwrap.Wrapper interface to make this
@@ -32,6 +120,10 @@ func (e *Error) WrappedErrors() []error {
 		return nil
 	}
 
+	if len(e.errs) == len(e.Errors) {
+		return e.errs
+	}
+
 	result := make([]error, len(e.Errors))
 	for i, e := range e.Errors {
 		result[i] = errors.New(e)
@@ -40,11 +132,689 @@ func (e *Error) WrappedErrors() []error {
 	return result
 }
 
-func appendErrors(errors []string, err error) []string {
+// Unwrap returns the individual errors so that errors.Is and errors.As
+// can match any of them.
+func (e *Error) Unwrap() []error {
+	return e.WrappedErrors()
+}
+
+// First returns the first DecodingError, or nil if there is none.
+func (e *Error) First() *DecodingError {
+	for _, err := range e.WrappedErrors() {
+		if decodingErr, ok := err.(*DecodingError); ok {
+			return decodingErr
+		}
+	}
+
+	return nil
+}
+
+// FilterByKind returns an Error with the DecodingErrors of the given
+// kinds, or nil if there are none. For example, the unused keys can be
+// reported as warnings while the other errors fail:
+//
+//	warnings := err.FilterByKind(DecodingErrorUnusedKeys)
+//
+// The result can be filtered further, since nil is an empty Error.
+func (e *Error) FilterByKind(kinds ...DecodingErrorKind) *Error {
+	return e.filter(func(err *DecodingError) bool {
+		for _, kind := range kinds {
+			if err.Kind == kind {
+				return true
+			}
+		}
+
+		return false
+	})
+}
+
+// ForNamespacePrefix returns an Error with the DecodingErrors whose
+// Namespace has the prefix ns, such as the errors of the fields below
+// "server", or nil if there are none.
+func (e *Error) ForNamespacePrefix(ns *Namespace) *Error {
+	return e.filter(func(err *DecodingError) bool {
+		return err.Namespace().HasPrefix(ns)
+	})
+}
+
+// filter returns an Error with the DecodingErrors for which keep returns
+// true, or nil if there are none.
+func (e *Error) filter(keep func(*DecodingError) bool) *Error {
+	var errs []error
+	for _, err := range e.WrappedErrors() {
+		if decodingErr, ok := err.(*DecodingError); ok && keep(decodingErr) {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+
+	filtered := newError(errs)
+	filtered.formatter = e.formatter
+	return filtered
+}
+
+// DecodingErrorKind is the category of a DecodingError.
+type DecodingErrorKind int
+
+const (
+	// DecodingErrorGeneric is the kind of errors that don't have a more
+	// specific kind.
+	DecodingErrorGeneric DecodingErrorKind = iota
+
+	// DecodingErrorHookFailure is the kind of errors returned by the
+	// DecodeHook. These are usually validation or conversion failures
+	// rather than structural mismatches between the input and the result.
+	DecodingErrorHookFailure
+
+	// DecodingErrorUnconvertibleType is the kind of errors where the
+	// input value can't be converted to the type of the result.
+	DecodingErrorUnconvertibleType
+
+	// DecodingErrorParseFailure is the kind of errors where a string or
+	// json.Number input couldn't be parsed into the result type.
+	DecodingErrorParseFailure
+
+	// DecodingErrorOverflow is the kind of errors where a numeric input
+	// doesn't fit in the result type.
+	DecodingErrorOverflow
+
+	// DecodingErrorUnsupportedType is the kind of errors where the result
+	// type can't be decoded into at all.
+	DecodingErrorUnsupportedType
+
+	// DecodingErrorInvalidSquash is the kind of errors where the squash
+	// option is used on a field that can't be squashed.
+	DecodingErrorInvalidSquash
+
+	// DecodingErrorInvalidLength is the kind of errors where the input
+	// has more elements than the result array can hold, or fewer when
+	// ArrayFill is ArrayFillError.
+	DecodingErrorInvalidLength
+
+	// DecodingErrorUnusedKeys is the kind of errors reported for keys
+	// in the input that weren't used (see ErrorUnused).
+	DecodingErrorUnusedKeys
+
+	// DecodingErrorUnsetFields is the kind of errors reported for fields
+	// in the result that weren't set (see ErrorUnset).
+	DecodingErrorUnsetFields
+
+	// DecodingErrorPathNotFound is the kind of errors where there is no
+	// value at the path passed to Extract or DecodeAt.
+	DecodingErrorPathNotFound
+
+	// DecodingErrorUntaggedField is the kind of errors reported for fields
+	// without a tag by VerifyStruct and RequireTags.
+	DecodingErrorUntaggedField
+
+	// DecodingErrorDuplicateKey is the kind of errors where a slice of
+	// key/value pairs that is decoded like a map has a key twice.
+	DecodingErrorDuplicateKey
+
+	// DecodingErrorMissingRequired is the kind of errors reported for
+	// fields with the "required" tag option that have no key in the input
+	// (see ErrorRequired).
+	DecodingErrorMissingRequired
+
+	// DecodingErrorUnknownType is the kind of errors where the TypeRegistry
+	// has no type for a map that is decoded into an interface, because
+	// its discriminator key is missing or names no registered type.
+	DecodingErrorUnknownType
+)
+
+var decodingErrorKindNames = map[DecodingErrorKind]string{
+	DecodingErrorGeneric:           "generic",
+	DecodingErrorHookFailure:       "hook failure",
+	DecodingErrorUnconvertibleType: "unconvertible type",
+	DecodingErrorParseFailure:      "parse failure",
+	DecodingErrorOverflow:          "overflow",
+	DecodingErrorUnsupportedType:   "unsupported type",
+	DecodingErrorInvalidSquash:     "invalid squash",
+	DecodingErrorInvalidLength:     "invalid length",
+	DecodingErrorUnusedKeys:        "unused keys",
+	DecodingErrorUnsetFields:       "unset fields",
+	DecodingErrorPathNotFound:      "path not found",
+	DecodingErrorUntaggedField:     "untagged field",
+	DecodingErrorDuplicateKey:      "duplicate key",
+	DecodingErrorMissingRequired:   "missing required",
+	DecodingErrorUnknownType:       "unknown type",
+}
+
+func (k DecodingErrorKind) String() string {
+	if name, ok := decodingErrorKindNames[k]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("DecodingErrorKind(%d)", int(k))
+}
+
+// MessageID identifies a message of the decoder. Unlike the default
+// (English) templates of the messages, the IDs don't change, so
+// ErrorMessages is keyed by them. The Message of a DecodingError is the
+// ID of its message.
+type MessageID string
+
+const (
+	// MsgHookFailure is the message of errors returned by a DecodeHook.
+	MsgHookFailure MessageID = "hook_failure"
+
+	// MsgUnconvertibleType is the message of values that can't be
+	// converted to the type of the result.
+	MsgUnconvertibleType MessageID = "unconvertible_type"
+
+	// MsgUnassignableType is the message of values that can't be
+	// assigned to the result.
+	MsgUnassignableType MessageID = "unassignable_type"
+
+	// MsgExpectedMap is the message of non-map values decoded into a map
+	// or struct.
+	MsgExpectedMap MessageID = "expected_map"
+
+	// MsgExpectedSlice is the message of non-slice values decoded into a
+	// slice or array.
+	MsgExpectedSlice MessageID = "expected_slice"
+
+	// MsgExpectedStringKeys is the message of maps without string keys
+	// decoded into a struct.
+	MsgExpectedStringKeys MessageID = "expected_string_keys"
+
+	// MsgUnassignableField is the message of struct fields that can't be
+	// assigned to the value type of a map.
+	MsgUnassignableField MessageID = "unassignable_field"
+
+	// MsgParseFailure is the message of strings that fail to parse as
+	// the type of the result.
+	MsgParseFailure MessageID = "parse_failure"
+
+	// MsgNumber is the message of json.Number values that fail to parse.
+	MsgNumber MessageID = "number"
+
+	// MsgOverflow is the message of numbers that overflow the type of
+	// the result.
+	MsgOverflow MessageID = "overflow"
+
+	// MsgUnsupportedType is the message of results of a type the decoder
+	// doesn't support.
+	MsgUnsupportedType MessageID = "unsupported_type"
+
+	// MsgSquashNonStruct is the message of fields with the "squash" tag
+	// option that aren't structs.
+	MsgSquashNonStruct MessageID = "squash_non_struct"
+
+	// MsgSquashUnsupported is the message of fields with the "squash"
+	// tag option of an unsupported type.
+	MsgSquashUnsupported MessageID = "squash_unsupported"
+
+	// MsgArrayLength is the message of inputs too long for the result
+	// array.
+	MsgArrayLength MessageID = "array_length"
+
+	// MsgArrayShort is the message of inputs too short for the result
+	// array.
+	MsgArrayShort MessageID = "array_short"
+
+	// MsgUnusedKeys is the message of keys of the input that weren't
+	// used (see ErrorUnused).
+	MsgUnusedKeys MessageID = "unused_keys"
+
+	// MsgUnsetFields is the message of fields of the result that weren't
+	// set (see ErrorUnset).
+	MsgUnsetFields MessageID = "unset_fields"
+
+	// MsgInvalidKey is the message of flat keys that can't be parsed.
+	MsgInvalidKey MessageID = "invalid_key"
+
+	// MsgInvalidPath is the message of paths that can't be parsed.
+	MsgInvalidPath MessageID = "invalid_path"
+
+	// MsgPathNotFound is the message of paths with no value in the
+	// input.
+	MsgPathNotFound MessageID = "path_not_found"
+
+	// MsgNodeFailure is the message of values that a source fails to
+	// read.
+	MsgNodeFailure MessageID = "node_failure"
+
+	// MsgUntaggedField is the message of fields without a tag (see
+	// VerifyStruct and RequireTags).
+	MsgUntaggedField MessageID = "untagged_field"
+
+	// MsgInclude is the message of includes that fail to load.
+	MsgInclude MessageID = "include"
+
+	// MsgInvalidPattern is the message of invalid patterns in tag
+	// options.
+	MsgInvalidPattern MessageID = "invalid_pattern"
+
+	// MsgDuplicateKey is the message of key/value pairs with a key seen
+	// before.
+	MsgDuplicateKey MessageID = "duplicate_key"
+
+	// MsgMarshalFailure is the message of values that fail to marshal.
+	MsgMarshalFailure MessageID = "marshal_failure"
+
+	// MsgMissingRequired is the message of fields with the "required"
+	// tag option missing from the input.
+	MsgMissingRequired MessageID = "missing_required"
+
+	// MsgMissingTypeKey is the message of maps without the key of a
+	// TypeRegistry.
+	MsgMissingTypeKey MessageID = "missing_type_key"
+
+	// MsgUnknownType is the message of maps whose TypeRegistry key names
+	// no registered type.
+	MsgUnknownType MessageID = "unknown_type"
+
+	// MsgUncomparableKey is the message of merge keys whose values can't
+	// be compared.
+	MsgUncomparableKey MessageID = "uncomparable_key"
+)
+
+// defaultMessages are the default templates of the messages.
+var defaultMessages = map[MessageID]string{
+	MsgHookFailure:        "error decoding '{name}': {err}",
+	MsgUnconvertibleType:  "'{name}' expected type '{expected}', got unconvertible type '{got}', value: '{value}'",
+	MsgUnassignableType:   "'{name}' expected type '{expected}', got '{got}'",
+	MsgExpectedMap:        "'{name}' expected a map, got '{got}'",
+	MsgExpectedSlice:      "'{name}': source data must be an array or slice, got {got}",
+	MsgExpectedStringKeys: "'{name}' needs a map with string keys, has '{got}' keys",
+	MsgUnassignableField:  "cannot assign type '{got}' to map value field of type '{expected}'",
+	MsgParseFailure:       "cannot parse '{name}' as {expected}: {err}",
+	MsgNumber:             "error decoding {got} into {name}: {err}",
+	MsgOverflow:           "cannot parse '{name}', {value} overflows {expected}",
+	MsgUnsupportedType:    "{name}: unsupported type: {expected}",
+	MsgSquashNonStruct:    "cannot squash non-struct type '{got}'",
+	MsgSquashUnsupported:  "{name}: unsupported type for squash: {got}",
+	MsgArrayLength:        "'{name}': expected source data to have length less or equal to {expected}, got {got}",
+	MsgArrayShort:         "'{name}': expected source data to have length {expected}, got {got}",
+	MsgUnusedKeys:         "'{name}' has invalid keys: {value}",
+	MsgUnsetFields:        "'{name}' has unset fields: {value}",
+	MsgInvalidKey:         "invalid flat key: {err}",
+	MsgInvalidPath:        "invalid path: {err}",
+	MsgPathNotFound:       "'{name}' not found",
+	MsgNodeFailure:        "error reading '{name}': {err}",
+	MsgUntaggedField:      "'{name}' has no '{expected}' tag",
+	MsgInclude:            "'{name}': cannot include '{value}': {err}",
+	MsgInvalidPattern:     "'{name}': invalid pattern '{value}': {err}",
+	MsgDuplicateKey:       "'{name}' has duplicate key '{value}'",
+	MsgMarshalFailure:     "error marshaling '{name}': {err}",
+	MsgMissingRequired:    "'{name}' is required",
+	MsgMissingTypeKey:     "'{name}' needs a '{expected}' key to choose its type",
+	MsgUnknownType:        "'{name}' has unknown type '{value}', expected one of: {expected}",
+	MsgUncomparableKey:    "'{name}' has merge key '{value}' of type '{got}', which can't be compared",
+}
+
+// DecodingError is a single error that occurred while decoding the value
+// at Name. The underlying error, if any, can be retrieved with
+// errors.Unwrap, errors.Is and errors.As.
+//
+// The message is rendered from Template by replacing the placeholders
+// {name}, {expected}, {got}, {value} and {err} with the corresponding
+// fields, and {source} with the SourceNamespace. The templates can be
+// replaced or translated with the ErrorMessages and TranslateErrorMessage
+// options of DecoderConfig. The ErrorNames option of DecoderConfig
+// chooses whether {name} is the Name, the SourceNamespace or both.
+type DecodingError struct {
+	Kind DecodingErrorKind
+
+	// Name is the name of the value that failed to decode, such as
+	// "Emails[0]" or "Vbar.Vstring".
+	Name string
+
+	// Expected and Got describe the expected and the actual type (or
+	// length, for DecodingErrorInvalidLength) of the value, if known.
+	Expected string
+	Got      string
+
+	// Value is the offending input value, if any.
+	Value interface{}
+
+	// Err is the underlying error, if any.
+	Err error
+
+	// Message identifies the message of the error, and Template is the
+	// message template used to render it.
+	Message  MessageID
+	Template string
+
+	// Position is the position of the value in the source document. It
+	// is only known if the Positions option of DecoderConfig is set.
+	Position Position
+
+	// Root is the RootLabel of the DecoderConfig, such as the name of the
+	// file being decoded, which prefixes the message if set.
+	Root string
+
+	// sourceKeys are the keys and indexes leading to the value in the
+	// input, innermost first.
+	sourceKeys []string
+
+	// formatValue renders Value in the message. Defaults to
+	// DefaultValueFormatter.
+	formatValue ValueFormatter
+
+	// names chooses the path that {name} is rendered as.
+	names ErrorNames
+}
+
+func (e *DecodingError) Error() string {
+	msg := e.message()
+	if e.Position.IsValid() {
+		msg = e.Position.String() + ": " + msg
+	}
+	if e.Root != "" {
+		msg = e.Root + ": " + msg
+	}
+
+	return msg
+}
+
+func (e *DecodingError) message() string {
+	template := e.Template
+	if template == "" {
+		if e.Kind != DecodingErrorHookFailure && e.Err != nil {
+			return e.Err.Error()
+		}
+
+		template = defaultMessages[MsgHookFailure]
+	}
+
+	formatValue := e.formatValue
+	if formatValue == nil {
+		formatValue = DefaultValueFormatter
+	}
+
+	var errMsg string
+	if e.Err != nil {
+		errMsg = e.Err.Error()
+		if e.formatValue != nil && e.Value != nil {
+			// The parsers quote the value they fail on in their errors.
+			quoted := strconv.Quote(DefaultValueFormatter(e.Value))
+			errMsg = strings.ReplaceAll(errMsg, quoted, strconv.Quote(formatValue(e.Value)))
+		}
+	}
+
+	return strings.NewReplacer(
+		"{name}", e.displayName(),
+		"{source}", e.SourceNamespace().String(),
+		"{expected}", e.Expected,
+		"{got}", e.Got,
+		"{value}", formatValue(e.Value),
+		"{err}", errMsg,
+	).Replace(template)
+}
+
+func (e *DecodingError) Unwrap() error {
+	return e.Err
+}
+
+// Namespace returns Name parsed as a Namespace. A Name that can't be
+// parsed, such as one with an empty key, is returned as a Namespace with
+// a single key, so that the result is never nil.
+func (e *DecodingError) Namespace() *Namespace {
+	return namespaceOf(e.Name)
+}
+
+// SourceNamespace returns the namespace of the value in the input, made
+// of the map keys and slice indexes leading to it, such as
+// "servers.0.ttl". It can differ from the Namespace of Name, which is the
+// path of the field the value is decoded into, because of tags, squashed
+// structs and keys matched case insensitively. It is the root namespace
+// if the value isn't inside a map or slice of the input.
+func (e *DecodingError) SourceNamespace() *Namespace {
+	ns := &Namespace{}
+	for _, key := range e.sourcePath() {
+		ns = ns.Append(key)
+	}
+
+	return ns
+}
+
+// displayName returns the name of the value that the message shows in
+// place of {name}, as chosen by ErrorNames. The source namespace is only
+// shown if it is known.
+func (e *DecodingError) displayName() string {
+	if e.names == ErrorNamesField || len(e.sourceKeys) == 0 {
+		return e.Name
+	}
+
+	source := e.SourceNamespace().String()
+	switch {
+	case e.names == ErrorNamesSource:
+		return source
+	case source == e.Name:
+		return e.Name
+	default:
+		return e.Name + " (" + source + ")"
+	}
+}
+
+// ErrorNames chooses how error messages name the value that failed to
+// decode.
+type ErrorNames int
+
+const (
+	// ErrorNamesField, the default, names values by the path of the
+	// field they are decoded into, such as "Server.Port".
+	ErrorNamesField ErrorNames = iota
+
+	// ErrorNamesSource names values by the keys and indexes leading to
+	// them in the input, such as "server.port", so that they can be
+	// found in the source document.
+	ErrorNamesSource
+
+	// ErrorNamesBoth names values by the path of their field, followed
+	// by the path in the input in parentheses if it differs, as in
+	// "Server.Port (server.port)".
+	ErrorNamesBoth
+)
+
+// sourcePath returns the keys and indexes leading to the value in the
+// input, outermost first.
+func (e *DecodingError) sourcePath() []string {
+	path := make([]string, len(e.sourceKeys))
+	for i, key := range e.sourceKeys {
+		path[len(path)-1-i] = key
+	}
+
+	return path
+}
+
+// withSourceKey records that err occurred while decoding the value at key
+// (a map key or slice index) of the input.
+func withSourceKey(err error, key string) error {
+	switch e := err.(type) {
+	case *Error:
+		for _, err := range e.errs {
+			withSourceKey(err, key)
+		}
+	case *DecodingError:
+		e.sourceKeys = append(e.sourceKeys, key)
+	}
+
+	return err
+}
+
+// Position is a location in a source document.
+type Position struct {
+	Filename string
+	Line     int
+	Column   int
+}
+
+// IsValid reports whether the position is known.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+func (p Position) String() string {
+	s := p.Filename
+	if p.IsValid() {
+		if s != "" {
+			s += ":"
+		}
+		s += strconv.Itoa(p.Line)
+		if p.Column > 0 {
+			s += ":" + strconv.Itoa(p.Column)
+		}
+	}
+
+	return s
+}
+
+// PositionProvider maps values of the input to their position in the
+// source document the input was read from, such as the line and column
+// information kept by many YAML and JSON parsers.
+type PositionProvider interface {
+	// Position returns the position of the value found by following
+	// path from the root of the input. Each element of path is a map
+	// key or a slice index, e.g. []string{"servers", "0", "ttl"}.
+	Position(path []string) (Position, bool)
+}
+
+// withPosition sets the position of the DecodingErrors in err that don't
+// have one yet.
+func withPosition(err error, pos Position) error {
+	switch e := err.(type) {
+	case *Error:
+		for _, err := range e.errs {
+			withPosition(err, pos)
+		}
+	case *DecodingError:
+		if !e.Position.IsValid() {
+			e.Position = pos
+		}
+	}
+
+	return err
+}
+
+// finishError prepares an error returned by a top-level decode for the
+// caller.
+func (d *Decoder) finishError(err error) error {
+	aggregate, ok := err.(*Error)
+	if !ok {
+		if e, ok := err.(*DecodingError); ok && d.config.Positions != nil && !e.Position.IsValid() {
+			e.Position = lookupPosition(d.config.Positions, e.sourcePath())
+		}
+
+		return err
+	}
+
+	if len(aggregate.errs) == len(aggregate.Errors) {
+		if d.config.Positions != nil {
+			for _, err := range aggregate.errs {
+				if e, ok := err.(*DecodingError); ok && !e.Position.IsValid() {
+					e.Position = lookupPosition(d.config.Positions, e.sourcePath())
+				}
+			}
+		}
+
+		// The messages now include the positions, including those of Node
+		// inputs that were set after the messages were first rendered.
+		aggregate.Errors = newError(aggregate.errs).Errors
+	}
+
+	aggregate.formatter = d.config.ErrorsFormatter
+	return aggregate
+}
+
+// lookupPosition returns the position of the value at path, falling back to
+// the position of the closest parent that has a known position.
+func lookupPosition(positions PositionProvider, path []string) Position {
+	for i := len(path); i >= 0; i-- {
+		if pos, ok := positions.Position(path[:i]); ok {
+			return pos
+		}
+	}
+
+	return Position{}
+}
+
+// decodingError sets the message of e to id, giving the configured
+// message catalog and translation function a chance to replace its
+// default template.
+func (d *Decoder) decodingError(id MessageID, e *DecodingError) *DecodingError {
+	template := defaultMessages[id]
+	if t, ok := d.config.ErrorMessages[id]; ok {
+		template = t
+	} else if d.config.TranslateErrorMessage != nil {
+		template = d.config.TranslateErrorMessage(e.Kind, template)
+	}
+
+	e.Message = id
+	e.Template = template
+	e.Root = d.config.RootLabel
+	e.names = d.config.ErrorNames
+	e.formatValue = d.config.ValueFormatter
+	if d.secret {
+		// The placeholder is never formatted.
+		e.formatValue = DefaultValueFormatter
+		e.Value = redacted
+		if e.Err != nil {
+			e.Err = &redactedError{e.Err}
+		}
+	}
+
+	return e
+}
+
+// redacted replaces the values of fields with the "secret" tag option in
+// errors.
+const redacted = "<redacted>"
+
+// redactedError hides the message of err, which may quote the value of a
+// secret field, while keeping it available to errors.Is and errors.As.
+type redactedError struct {
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return redacted
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+func (d *Decoder) unconvertibleTypeError(name string, val, dataVal reflect.Value, data interface{}) error {
+	return d.decodingError(MsgUnconvertibleType, &DecodingError{
+		Kind:     DecodingErrorUnconvertibleType,
+		Name:     name,
+		Expected: val.Type().String(),
+		Got:      dataVal.Type().String(),
+		Value:    data,
+	})
+}
+
+func (d *Decoder) parseError(name, expected string, data interface{}, err error) error {
+	return d.decodingError(MsgParseFailure, &DecodingError{
+		Kind:     DecodingErrorParseFailure,
+		Name:     name,
+		Expected: expected,
+		Value:    data,
+		Err:      err,
+	})
+}
+
+func (d *Decoder) numberError(name string, val, dataVal reflect.Value, data interface{}, err error) error {
+	return d.decodingError(MsgNumber, &DecodingError{
+		Kind:     DecodingErrorParseFailure,
+		Name:     name,
+		Expected: val.Type().String(),
+		Got:      dataVal.Type().String(),
+		Value:    data,
+		Err:      err,
+	})
+}
+
+func appendErrors(errors []error, err error) []error {
 	switch e := err.(type) {
 	case *Error:
-		return append(errors, e.Errors...)
+		return append(errors, e.WrappedErrors()...)
 	default:
-		return append(errors, e.Error())
+		return append(errors, e)
 	}
 }
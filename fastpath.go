@@ -0,0 +1,261 @@
+package mapstructure
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// flatField is a field of a flat struct and the key it is decoded from.
+type flatField struct {
+	index int
+	name  string
+	kind  reflect.Kind
+}
+
+// flatPlan holds the fields of a struct type for decodeFlat, as computed
+// for a tag name and JSONTags setting. flat is false if the type can't be
+// decoded by decodeFlat.
+type flatPlan struct {
+	tagName  string
+	jsonTags bool
+	flat     bool
+	fields   []flatField
+}
+
+// flatPlans caches the *flatPlan of struct types.
+var flatPlans sync.Map
+
+var defaultSetterType = reflect.TypeOf((*DefaultSetter)(nil)).Elem()
+
+// canDecodeFlat reports whether the configuration leaves the decoding of
+// structs of basic kinds from a map[string]interface{} to plain
+// assignments, so that decodeFlat can be used: no hooks, metadata, error
+// checks for unused keys or unset fields, or options that change how
+// keys are matched to fields or how strings are decoded.
+func (d *Decoder) canDecodeFlat() bool {
+	c := d.config
+	return c.DecodeHook == nil &&
+		c.OutputHook == nil &&
+		c.Metadata == nil &&
+		!c.ErrorUnused &&
+		!c.ErrorUnset &&
+		!c.WeaklyTypedInput &&
+		c.KeyTransform == nil &&
+		c.MatchField == nil &&
+		c.IncludeKey == "" &&
+		c.PathTagName == "" &&
+		!c.XMLConventions &&
+		!c.UseTextUnmarshaler
+}
+
+// flatPlan returns the plan of the struct type typ.
+func (d *Decoder) flatPlan(typ reflect.Type) *flatPlan {
+	if cached, ok := flatPlans.Load(typ); ok && !d.config.DisableCache {
+		plan := cached.(*flatPlan)
+		if plan.tagName == d.config.TagName && plan.jsonTags == d.config.JSONTags {
+			return plan
+		}
+	}
+
+	plan := &flatPlan{
+		tagName:  d.config.TagName,
+		jsonTags: d.config.JSONTags,
+		flat:     !reflect.PtrTo(typ).Implements(defaultSetterType) && !reflect.PtrTo(typ).Implements(unmarshalerType),
+	}
+	for i := 0; i < typ.NumField() && plan.flat; i++ {
+		f := typ.Field(i)
+		if f.Type == optionsType || f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+
+		// Only plain fields with a name and at most the omitempty option,
+		// which only applies to encoding, are flat.
+		tagParts := strings.Split(d.fieldTag(f), ",")
+		for _, opt := range tagParts[1:] {
+			plan.flat = plan.flat && opt == "omitempty"
+		}
+
+		kind := getKind(reflect.Zero(f.Type))
+		switch kind {
+		case reflect.Bool, reflect.String, reflect.Int, reflect.Uint, reflect.Float32:
+		default:
+			plan.flat = false
+		}
+		if f.Anonymous || tagParts[0] == "-" || reflect.PtrTo(f.Type).Implements(unmarshalerType) {
+			plan.flat = false
+		}
+
+		name := f.Name
+		if tagParts[0] != "" {
+			name = tagParts[0]
+		}
+		plan.fields = append(plan.fields, flatField{index: i, name: name, kind: kind})
+	}
+
+	if !d.config.DisableCache {
+		flatPlans.Store(typ, plan)
+	}
+	return plan
+}
+
+// decodeFlat decodes m into the struct val, whose fields are given by
+// plan, by assigning the values directly. It returns false as soon as a
+// value needs more than an assignment, such as a conversion or an error,
+// in which case the struct must be decoded as usual, which decodes the
+// fields that were already assigned to the same values again.
+func (d *Decoder) decodeFlat(m map[string]interface{}, plan *flatPlan, val reflect.Value) bool {
+	for _, f := range plan.fields {
+		raw, ok := m[f.name]
+		if !ok {
+			for key, v := range m {
+				if d.config.MatchName(key, f.name) {
+					raw, ok = v, true
+					break
+				}
+			}
+			if !ok {
+				continue
+			}
+		}
+
+		if !setFlat(val.Field(f.index), f.kind, raw) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// setFlat assigns raw to field, whose kind as returned by getKind is kind,
+// the same way the decoder does without WeaklyTypedInput. It returns false
+// if raw has another type than the basic types, or can't be assigned.
+func setFlat(field reflect.Value, kind reflect.Kind, raw interface{}) bool {
+	switch v := raw.(type) {
+	case string:
+		if kind != reflect.String {
+			return false
+		}
+		field.SetString(v)
+		return true
+	case bool:
+		if kind != reflect.Bool {
+			return false
+		}
+		field.SetBool(v)
+		return true
+	case int:
+		return setFlatInt(field, kind, int64(v))
+	case int8:
+		return setFlatInt(field, kind, int64(v))
+	case int16:
+		return setFlatInt(field, kind, int64(v))
+	case int32:
+		return setFlatInt(field, kind, int64(v))
+	case int64:
+		return setFlatInt(field, kind, v)
+	case uint:
+		return setFlatUint(field, kind, uint64(v))
+	case uint8:
+		return setFlatUint(field, kind, uint64(v))
+	case uint16:
+		return setFlatUint(field, kind, uint64(v))
+	case uint32:
+		return setFlatUint(field, kind, uint64(v))
+	case uint64:
+		return setFlatUint(field, kind, v)
+	case float32:
+		return setFlatFloat(field, kind, float64(v))
+	case float64:
+		return setFlatFloat(field, kind, v)
+	default:
+		return false
+	}
+}
+
+func setFlatInt(field reflect.Value, kind reflect.Kind, i int64) bool {
+	switch kind {
+	case reflect.Int:
+		field.SetInt(i)
+	case reflect.Uint:
+		if i < 0 {
+			return false
+		}
+		field.SetUint(uint64(i))
+	case reflect.Float32:
+		field.SetFloat(float64(i))
+	default:
+		return false
+	}
+
+	return true
+}
+
+func setFlatUint(field reflect.Value, kind reflect.Kind, u uint64) bool {
+	switch kind {
+	case reflect.Int:
+		field.SetInt(int64(u))
+	case reflect.Uint:
+		field.SetUint(u)
+	case reflect.Float32:
+		field.SetFloat(float64(u))
+	default:
+		return false
+	}
+
+	return true
+}
+
+func setFlatFloat(field reflect.Value, kind reflect.Kind, f float64) bool {
+	switch kind {
+	case reflect.Int:
+		field.SetInt(int64(f))
+	case reflect.Uint:
+		if f < 0 {
+			return false
+		}
+		field.SetUint(uint64(f))
+	case reflect.Float32:
+		field.SetFloat(f)
+	default:
+		return false
+	}
+
+	return true
+}
+
+// Prepare builds the plans the decoder uses for the struct types among
+// types and the types they contain ahead of time, so that the first
+// decode into them doesn't pay for it. Without types, the type of the
+// Result is prepared. Preparing is optional; plans are built on first use
+// otherwise.
+func (d *Decoder) Prepare(types ...reflect.Type) {
+	if len(types) == 0 {
+		types = []reflect.Type{reflect.TypeOf(d.config.Result)}
+	}
+
+	seen := make(map[reflect.Type]bool)
+	for _, typ := range types {
+		d.prepare(typ, seen)
+	}
+}
+
+func (d *Decoder) prepare(typ reflect.Type, seen map[reflect.Type]bool) {
+	if typ == nil || seen[typ] {
+		return
+	}
+	seen[typ] = true
+
+	switch typ.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		d.prepare(typ.Elem(), seen)
+	case reflect.Map:
+		d.prepare(typ.Key(), seen)
+		d.prepare(typ.Elem(), seen)
+	case reflect.Struct:
+		d.flatPlan(typ)
+		for i := 0; i < typ.NumField(); i++ {
+			d.prepare(typ.Field(i).Type, seen)
+		}
+	}
+}
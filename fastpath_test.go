@@ -0,0 +1,109 @@
+package mapstructure
+
+import (
+	"reflect"
+	"testing"
+)
+
+type flatRequest struct {
+	Method  string `mapstructure:"method,omitempty"`
+	ID      int64  `mapstructure:"id"`
+	Retries uint8
+	Timeout float32
+	Async   bool
+	private int
+}
+
+func TestDecode_flat(t *testing.T) {
+	// Not parallel, since it counts allocations.
+	input := map[string]interface{}{
+		"method":  "get",
+		"id":      uint(42),
+		"RETRIES": 3,
+		"timeout": 1.5,
+		"async":   true,
+	}
+
+	var result flatRequest
+	decoder, err := NewDecoder(&DecoderConfig{Result: &result})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	allocs := testing.AllocsPerRun(10, func() {
+		if err := decoder.Decode(input); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	})
+
+	expected := flatRequest{Method: "get", ID: 42, Retries: 3, Timeout: 1.5, Async: true}
+	if result != expected {
+		t.Fatalf("bad: %#v", result)
+	}
+	if allocs > 0 {
+		t.Fatalf("expected no allocations, got %v", allocs)
+	}
+
+	// Values that need more than an assignment are decoded as usual, so
+	// the result is the same as without the fast path, which Metadata
+	// turns off.
+	cases := []map[string]interface{}{
+		{"method": "get", "retries": -1},
+		{"method": "get", "id": "42"},
+		{"method": "get", "async": nil},
+		{"method": "get", "timeout": 2},
+	}
+	for _, input := range cases {
+		var flat, slow flatRequest
+		flatErr := Decode(input, &flat)
+
+		decoder, err := NewDecoder(&DecoderConfig{Metadata: &Metadata{}, Result: &slow})
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		slowErr := decoder.Decode(input)
+
+		if (flatErr == nil) != (slowErr == nil) || flat != slow {
+			t.Fatalf("%#v: %#v (%v), %#v (%v)", input, flat, flatErr, slow, slowErr)
+		}
+	}
+}
+
+func TestDecoder_Prepare(t *testing.T) {
+	t.Parallel()
+
+	type preparedRequest struct {
+		Method string
+	}
+	type preparedBatch struct {
+		Requests []*preparedRequest
+		ByName   map[string]preparedRequest
+	}
+
+	var result preparedBatch
+	decoder, err := NewDecoder(&DecoderConfig{Result: &result})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	decoder.Prepare()
+
+	for _, v := range []interface{}{preparedBatch{}, preparedRequest{}} {
+		cached, ok := flatPlans.Load(reflect.TypeOf(v))
+		if !ok {
+			t.Fatalf("%T wasn't prepared", v)
+		}
+		if flat := cached.(*flatPlan).flat; flat != (reflect.TypeOf(v) == reflect.TypeOf(preparedRequest{})) {
+			t.Fatalf("bad plan for %T: %#v", v, cached)
+		}
+	}
+
+	input := map[string]interface{}{
+		"requests": []map[string]interface{}{{"method": "get"}},
+	}
+	if err := decoder.Decode(input); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(result.Requests) != 1 || result.Requests[0].Method != "get" {
+		t.Fatalf("bad: %#v", result)
+	}
+}
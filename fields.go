@@ -0,0 +1,96 @@
+package mapstructure
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// structField is a field of a struct type with its tag parsed, as
+// decodeStructFromMap needs it.
+type structField struct {
+	reflect.StructField
+
+	// tag is the tag of the field as returned by fieldTag, name is the
+	// key that the field is decoded from and opts are the options of the
+	// tag.
+	tag  string
+	name string
+	opts []string
+
+	// squash and remain are set if the tag has the "squash" or the
+	// "remain" option, whichever comes first, and required if it has the
+	// "required" option.
+	squash   bool
+	remain   bool
+	required bool
+
+	// path is the path that the field is decoded from if hasPath is set,
+	// as returned by fieldPath.
+	path    string
+	hasPath bool
+}
+
+// structFieldsKey identifies the fields of a struct type as read with a
+// configuration: the settings that change how tags are read are part of
+// the key.
+type structFieldsKey struct {
+	typ         reflect.Type
+	tagName     string
+	pathTagName string
+	jsonTags    bool
+}
+
+// structFieldsCache caches the []structField of struct types by
+// structFieldsKey.
+var structFieldsCache sync.Map
+
+// structFields returns the fields of the struct type typ, except for the
+// Options field, with their tags parsed. The fields are cached unless
+// DisableCache is set, so that decoding into the same type again skips
+// parsing the tags.
+func (d *Decoder) structFields(typ reflect.Type) []structField {
+	key := structFieldsKey{
+		typ:         typ,
+		tagName:     d.config.TagName,
+		pathTagName: d.config.PathTagName,
+		jsonTags:    d.config.JSONTags,
+	}
+	if !d.config.DisableCache {
+		if cached, ok := structFieldsCache.Load(key); ok {
+			return cached.([]structField)
+		}
+	}
+
+	fields := make([]structField, 0, typ.NumField())
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.Type == optionsType {
+			continue
+		}
+
+		sf := structField{StructField: f, tag: d.fieldTag(f), name: f.Name}
+		tagParts := strings.Split(sf.tag, ",")
+		if tagParts[0] != "" {
+			sf.name = tagParts[0]
+		}
+		sf.opts = tagParts[1:]
+		sf.required = hasTagOption(sf.tag, "required")
+		for _, opt := range sf.opts {
+			if opt == "squash" || opt == "remain" {
+				sf.squash = opt == "squash"
+				sf.remain = opt == "remain"
+				break
+			}
+		}
+		sf.path, sf.hasPath = d.fieldPath(f)
+
+		fields = append(fields, sf)
+	}
+
+	if !d.config.DisableCache {
+		structFieldsCache.Store(key, fields)
+	}
+
+	return fields
+}
@@ -0,0 +1,70 @@
+package mapstructure
+
+import (
+	"reflect"
+	"testing"
+)
+
+type cachedFields struct {
+	Name    string `mapstructure:"name"`
+	Port    int    `mapstructure:"port,omitempty"`
+	Address string `json:"addr"`
+	Embedded
+	Rest map[string]interface{} `mapstructure:",remain"`
+}
+
+func TestDecoder_structFieldsCache(t *testing.T) {
+	t.Parallel()
+
+	typ := reflect.TypeOf(cachedFields{})
+	input := map[string]interface{}{
+		"name":    "web",
+		"port":    8080,
+		"addr":    "localhost",
+		"vstring": "embedded",
+		"extra":   true,
+	}
+
+	decode := func(config *DecoderConfig) cachedFields {
+		var result cachedFields
+		config.Result = &result
+		decoder, err := NewDecoder(config)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := decoder.Decode(input); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		return result
+	}
+
+	decode(&DecoderConfig{TagName: "uncached", DisableCache: true})
+	if _, ok := structFieldsCache.Load(structFieldsKey{typ: typ, tagName: "uncached"}); ok {
+		t.Fatal("expected DisableCache to leave the fields uncached")
+	}
+
+	// The fields are cached separately per tag settings, so that decoding
+	// with other settings doesn't use the fields of the first.
+	for i := 0; i < 2; i++ {
+		cached := decode(&DecoderConfig{})
+		if !reflect.DeepEqual(cached.Rest, map[string]interface{}{"addr": "localhost", "vstring": "embedded", "extra": true}) {
+			t.Fatalf("bad: %#v", cached)
+		}
+		if cached.Name != "web" || cached.Port != 8080 || cached.Address != "" {
+			t.Fatalf("bad: %#v", cached)
+		}
+
+		jsonTags := decode(&DecoderConfig{JSONTags: true})
+		if jsonTags.Address != "localhost" || jsonTags.Vstring != "embedded" {
+			t.Fatalf("bad: %#v", jsonTags)
+		}
+	}
+	if _, ok := structFieldsCache.Load(structFieldsKey{typ: typ, tagName: "mapstructure"}); !ok {
+		t.Fatal("expected the fields to be cached")
+	}
+
+	uncached := decode(&DecoderConfig{DisableCache: true})
+	if cached := decode(&DecoderConfig{}); !reflect.DeepEqual(uncached, cached) {
+		t.Fatalf("expected %#v, got %#v", cached, uncached)
+	}
+}
@@ -0,0 +1,119 @@
+package mapstructure
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// applyIncludes returns the map dataVal with the maps named by its
+// IncludeKey entry merged in, or dataVal itself if it has no such entry.
+func (d *Decoder) applyIncludes(name string, dataVal reflect.Value) (reflect.Value, error) {
+	if d.config.IncludeKey == "" {
+		return dataVal, nil
+	}
+
+	return d.mergeIncludes(name, dataVal, nil)
+}
+
+// mergeIncludes implements applyIncludes. stack holds the names of the
+// maps being included, to detect cycles.
+func (d *Decoder) mergeIncludes(name string, dataVal reflect.Value, stack []string) (reflect.Value, error) {
+	keyType := dataVal.Type().Key()
+	if keyType.Kind() != reflect.String && keyType.Kind() != reflect.Interface {
+		return dataVal, nil
+	}
+
+	includeKey := reflect.ValueOf(d.config.IncludeKey)
+	if keyType.Kind() == reflect.String {
+		includeKey = includeKey.Convert(keyType)
+	}
+
+	raw := dataVal.MapIndex(includeKey)
+	if !raw.IsValid() {
+		return dataVal, nil
+	}
+
+	includeError := func(include string, err error) error {
+		return d.decodingError(MsgInclude, &DecodingError{
+			Kind:  DecodingErrorGeneric,
+			Name:  name,
+			Value: include,
+			Err:   err,
+		})
+	}
+
+	names, err := includeNames(raw.Interface())
+	if err != nil {
+		return dataVal, includeError(fmt.Sprint(raw.Interface()), err)
+	}
+
+	result := reflect.MakeMap(dataVal.Type())
+	for _, include := range names {
+		for _, s := range stack {
+			if s == include {
+				return dataVal, includeError(include, fmt.Errorf(
+					"include cycle: %s", strings.Join(append(stack, include), " -> ")))
+			}
+		}
+
+		if d.config.IncludeResolver == nil {
+			return dataVal, includeError(include, errors.New("no IncludeResolver is set"))
+		}
+
+		included, err := d.config.IncludeResolver(include)
+		if err != nil {
+			return dataVal, includeError(include, err)
+		}
+
+		includedVal, err := d.mergeIncludes(name, reflect.ValueOf(included), append(stack, include))
+		if err != nil {
+			return dataVal, err
+		}
+
+		for _, k := range includedVal.MapKeys() {
+			v := includedVal.MapIndex(k)
+			if keyType.Kind() == reflect.String {
+				k = reflect.ValueOf(k.String()).Convert(keyType)
+			}
+			if !v.Type().AssignableTo(dataVal.Type().Elem()) {
+				return dataVal, includeError(include, fmt.Errorf(
+					"value of '%s' can't be stored in a %s", k, dataVal.Type()))
+			}
+
+			result.SetMapIndex(k, v)
+		}
+	}
+
+	for _, k := range dataVal.MapKeys() {
+		if k.Interface() != includeKey.Interface() {
+			result.SetMapIndex(k, dataVal.MapIndex(k))
+		}
+	}
+
+	return result, nil
+}
+
+// includeNames returns the names of the maps to include from the value of
+// an IncludeKey entry, which is a string or a slice of strings.
+func includeNames(v interface{}) ([]string, error) {
+	switch v := v.(type) {
+	case string:
+		return []string{v}, nil
+	case []string:
+		return v, nil
+	case []interface{}:
+		names := make([]string, len(v))
+		for i, elem := range v {
+			name, ok := elem.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected a string, got %T", elem)
+			}
+			names[i] = name
+		}
+		return names, nil
+	default:
+		return nil, fmt.Errorf("expected a string or a list of strings, got %T", v)
+	}
+}
@@ -0,0 +1,123 @@
+package mapstructure
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecode_IncludeKey(t *testing.T) {
+	t.Parallel()
+
+	fragments := map[string]map[string]interface{}{
+		"defaults": {"port": 80, "timeout": 30, "tags": []string{"default"}},
+		"tls":      {"port": 443, "tls": true},
+		"prod":     {"$include": "defaults", "replicas": 3},
+	}
+	resolver := func(name string) (map[string]interface{}, error) {
+		m, ok := fragments[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown fragment")
+		}
+		return m, nil
+	}
+
+	type Server struct {
+		Port     int
+		Timeout  int
+		TLS      bool
+		Replicas int
+		Tags     []string
+	}
+	type Config struct {
+		Web   Server
+		Extra map[string]interface{}
+	}
+
+	input := map[string]interface{}{
+		"web": map[string]interface{}{
+			"$include": []interface{}{"prod", "tls"},
+			"timeout":  10,
+		},
+		"extra": map[string]interface{}{
+			"$include": "tls",
+		},
+	}
+
+	var result Config
+	decoder, err := NewDecoder(&DecoderConfig{
+		IncludeKey:      "$include",
+		IncludeResolver: resolver,
+		ErrorUnused:     true,
+		Result:          &result,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := decoder.Decode(input); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := Config{
+		Web: Server{
+			Port:     443,
+			Timeout:  10,
+			TLS:      true,
+			Replicas: 3,
+			Tags:     []string{"default"},
+		},
+		Extra: map[string]interface{}{"port": 443, "tls": true},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+}
+
+func TestDecode_IncludeKeyErrors(t *testing.T) {
+	t.Parallel()
+
+	fragments := map[string]map[string]interface{}{
+		"a": {"$include": "b"},
+		"b": {"$include": "a"},
+	}
+	resolver := func(name string) (map[string]interface{}, error) {
+		m, ok := fragments[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown fragment")
+		}
+		return m, nil
+	}
+
+	cases := []struct {
+		input    interface{}
+		expected string
+	}{
+		{"missing", "'Web': cannot include 'missing': unknown fragment"},
+		{"a", "'Web': cannot include 'a': include cycle: a -> b -> a"},
+		{42, "'Web': cannot include '42': expected a string or a list of strings, got int"},
+	}
+
+	for _, tc := range cases {
+		var result struct {
+			Web struct {
+				Port int
+			}
+		}
+		decoder, err := NewDecoder(&DecoderConfig{
+			IncludeKey:      "$include",
+			IncludeResolver: resolver,
+			Result:          &result,
+		})
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		err = decoder.Decode(map[string]interface{}{
+			"web": map[string]interface{}{"$include": tc.input},
+		})
+		if err == nil || !strings.Contains(err.Error(), tc.expected) {
+			t.Fatalf("%v: bad: %v", tc.input, err)
+		}
+	}
+}
@@ -86,6 +86,13 @@
 // DecoderConfig has a field that changes the behavior of mapstructure
 // to always squash embedded structs.
 //
+// An embedded pointer to a struct is squashed the same way. If it is nil
+// when decoding, the struct is allocated first.
+//
+// An embedded interface can be squashed too while it holds a struct, or a
+// pointer to one: its fields are decoded into and encoded from the struct
+// it holds. A nil interface can't be squashed.
+//
 // Remainder Values
 //
 // If there are any unmapped keys in the source value, mapstructure by
@@ -94,8 +101,10 @@
 // of the unused keys.
 //
 // You can also use the ",remain" suffix on your tag to collect all unused
-// values in a map. The field with this tag MUST be a map type and should
-// probably be a "map[string]interface{}" or "map[interface{}]interface{}".
+// values in a map. The field with this tag MUST be a map type, or a pointer
+// to one. Each value is converted to the element type of the map like the
+// value of any other field, so typed maps such as "map[string]string"
+// work, and a value that can't be converted is reported under its key.
 // See example below:
 //
 //     type Friend struct {
@@ -111,6 +120,45 @@
 //         "address": "123 Maple St.",
 //     }
 //
+// Keys that shouldn't be collected can be dropped with one or more
+// "except=" options, whose patterns use the syntax of path.Match. With the
+// tag `mapstructure:",remain,except=_*"` the keys starting with an
+// underscore are neither collected nor reported as unused.
+//
+// When a struct is encoded into a map, the entries of its ",remain" map are
+// put back at the top level next to the other fields, so that decoding,
+// changing and encoding a value again doesn't lose the keys it doesn't
+// know about. Entries never replace the keys of the other fields.
+//
+// A map field can be squashed too, which flattens its entries into the map
+// its struct is encoded into, like the ",remain" map, and collects the
+// unused keys when decoding. With the "prefix=" option, the prefix is added
+// to its keys when encoding, and only the keys with the prefix are
+// collected, without it, when decoding. This suits label style outputs:
+//
+//     type Pod struct {
+//         Name   string            `mapstructure:"name"`
+//         Labels map[string]string `mapstructure:",squash,prefix=label_"`
+//     }
+//
+// Pod{Name: "web", Labels: map[string]string{"app": "web"}} is encoded into
+// a map with the keys "name" and "label_app", and decoded from it again.
+// Squashed maps collect their keys before the ",remain" map.
+//
+// Nested Values
+//
+// A field can be decoded from a value deep inside the input instead of
+// from a key of its own by appending ",path" to a tag that holds the path
+// to the value:
+//
+//     type Config struct {
+//         Port int `mapstructure:"server.listeners[0].port,path"`
+//     }
+//
+// The path uses the syntax described for PathTagName in DecoderConfig.
+// Hooks, metadata and errors treat the value like any other field, with
+// the path as its name.
+//
 // Omit Empty Values
 //
 // When decoding from a struct to any other value, you may use the
@@ -126,6 +174,37 @@
 //         Age int `mapstructure:",omitempty"`
 //     }
 //
+// Secret Values
+//
+// Fields holding credentials can be marked with the ",secret" suffix on
+// their tag. The errors of decoding such a field, or any value inside it,
+// never include the input: its value and the message of the underlying
+// error are replaced by "<redacted>", so that a password that fails to
+// parse doesn't end up in the logs. The underlying error can still be
+// matched with errors.Is and errors.As.
+//
+//     type Database struct {
+//         User     string `mapstructure:"user"`
+//         Password string `mapstructure:"password,secret"`
+//     }
+//
+// Metadata only records the names and types of values, never the values
+// themselves, so it needs no redaction.
+//
+// Required Fields
+//
+// Fields that must be present in the input can be marked with the
+// ",required" suffix on their tag. If ErrorRequired is set in the
+// DecoderConfig, decoding a struct from a map that has no key for such a
+// field fails with an error naming it, such as "'server.port' is
+// required". A key with a nil value counts as present. The fields of a
+// nested struct are only checked if the struct itself is decoded.
+//
+//     type Server struct {
+//         Host string `mapstructure:"host"`
+//         Port int    `mapstructure:"port,required"`
+//     }
+//
 // Unexported fields
 //
 // Since unexported (private) struct fields cannot be set outside the package
@@ -152,6 +231,18 @@
 //         Public: "I made it through!"
 //     }
 //
+// Pointers
+//
+// Pointer fields, including pointers to pointers such as **T and pointers
+// to interfaces such as *interface{}, are decoded level by level: a nil
+// pointer is allocated, and a pointer that is already set is decoded into
+// in place, keeping the values the input doesn't set, unless ZeroFields is
+// set, in which case every level is allocated anew. A nil input leaves the
+// pointer as it is, or sets it to nil with ZeroFields, while a nil map,
+// slice or other nil value of a type sets it to nil. Inputs that are
+// pointer chains are followed down to the value they point to, so a **T
+// decodes like a T, and a nil at any level is a nil input.
+//
 // Other Configuration
 //
 // mapstructure is highly configurable. See the DecoderConfig struct
@@ -159,13 +250,19 @@
 package mapstructure
 
 import (
+	"context"
+	"encoding"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net/textproto"
+	"path"
 	"reflect"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // DecodeHookFunc is the callback function that can be used for
@@ -196,6 +293,28 @@ type DecodeHookFuncKind func(reflect.Kind, reflect.Kind, interface{}) (interface
 // values.
 type DecodeHookFuncValue func(from reflect.Value, to reflect.Value) (interface{}, error)
 
+// ArrayFillPolicy controls what happens to the elements of an array that
+// the input doesn't have, such as the last two elements of a [4]int that
+// a 2-element slice is decoded into.
+type ArrayFillPolicy int
+
+const (
+	// ArrayFillDefault zeroes the remaining elements if the array is
+	// zero or ZeroFields is set, and keeps them otherwise.
+	ArrayFillDefault ArrayFillPolicy = iota
+
+	// ArrayFillZero always zeroes the remaining elements.
+	ArrayFillZero
+
+	// ArrayFillKeep always keeps the remaining elements as they are in
+	// the array being decoded into.
+	ArrayFillKeep
+
+	// ArrayFillError reports an error of kind DecodingErrorInvalidLength
+	// if the input has fewer elements than the array.
+	ArrayFillError
+)
+
 // DecoderConfig is the configuration that is used to create a new decoder
 // and allows customization of various aspects of decoding.
 type DecoderConfig struct {
@@ -207,9 +326,36 @@ type DecoderConfig struct {
 	// is called only once with all of the input data, not once for each
 	// embedded struct.
 	//
-	// If an error is returned, the entire decode will fail with that error.
+	// If an error is returned, the entire decode will fail with that error,
+	// wrapped in a DecodingError of kind DecodingErrorHookFailure.
 	DecodeHook DecodeHookFunc
 
+	// SkipRootDecodeHook, if set to true, doesn't call the DecodeHook on
+	// the whole input that Decode is called with, only on the values
+	// inside it. Hooks written to convert leaf values then never see the
+	// top-level map, so that they can be combined predictably with hooks
+	// that convert whole structs, such as RecursiveStructToMapHookFunc,
+	// which are still called for the nested structs.
+	SkipRootDecodeHook bool
+
+	// UseTextUnmarshaler, if set to true, decodes string inputs into the
+	// values whose type implements encoding.TextUnmarshaler on a pointer
+	// to it, such as net.IP or big.Int, by calling UnmarshalText, at any
+	// depth and through pointers, as if TextUnmarshallerHookFunc were
+	// composed into the DecodeHook. Types that implement Unmarshaler are
+	// decoded by it instead.
+	UseTextUnmarshaler bool
+
+	// OutputHook, if set, is called after a value has been decoded into
+	// the result, with its namespace and the value in the result, which
+	// it can change in place. It is called for every value that is
+	// decoded, the values inside maps, slices and structs before the
+	// value that holds them, so that values can be normalized, such as
+	// hostnames lowercased or numbers clamped to a range, without
+	// walking the result again. An error fails the decode like an error
+	// of the DecodeHook.
+	OutputHook func(ns *Namespace, v reflect.Value) error
+
 	// If ErrorUnused is true, then it is an error for there to exist
 	// keys in the original map that were unused in the decoding process
 	// (extra keys).
@@ -221,30 +367,162 @@ type DecoderConfig struct {
 	// will affect all nested structs as well.
 	ErrorUnset bool
 
+	// ErrorRequired, if set to true, makes it an error for a field with
+	// the "required" tag option to have no key in the input it is decoded
+	// from. Each missing field is reported by its full name in a
+	// DecodingError of kind DecodingErrorMissingRequired. Unlike
+	// ErrorUnset, only the fields that are tagged are checked.
+	ErrorRequired bool
+
 	// ZeroFields, if set to true, will zero fields before writing them.
 	// For example, a map will be emptied before decoded values are put in
-	// it. If this is false, a map will be merged.
+	// it. If this is false, a map will be merged, and the input for an
+	// existing key of a map with pointer values, such as a
+	// map[string]*Config, is decoded into the value it points to.
 	ZeroFields bool
 
+	// Atomic, if set to true, makes Decode and DecodeAt decode into a deep
+	// copy of the value that Result points to, which replaces it only if
+	// decoding succeeds, so that a live object is never left half-decoded.
+	// Since the value is replaced by its copy, pointers into the old value
+	// no longer see later changes. Metadata is collected either way.
+	Atomic bool
+
+	// ZeroValueProviders, if set, provide the fresh values of the given
+	// types that are used instead of their zero values where ZeroFields
+	// resets a value, and where a new map, slice or pointed-to value is
+	// created. The provided value must have the type it is registered
+	// for, and is used as is, so it shouldn't be shared. For example,
+	//
+	//   ZeroValueProviders: map[reflect.Type]func() interface{}{
+	//       reflect.TypeOf(map[string]int{}): func() interface{} {
+	//           return make(map[string]int, 64)
+	//       },
+	//   }
+	//
+	// preallocates the maps of that type.
+	ZeroValueProviders map[reflect.Type]func() interface{}
+
+	// SliceMergeKey, if set, merges slices of structs (or pointers to
+	// structs) element by element when decoding into a non-empty slice.
+	// An input element is decoded into the element whose field with the
+	// key SliceMergeKey has the same value, and appended if there is none,
+	// so that an overlay can change a single element of a list:
+	//
+	//   type Config struct {
+	//       Backends []Backend `mapstructure:"backends,mergekey=name"`
+	//   }
+	//
+	// As the example shows, a field can set the key for its value alone
+	// with the "mergekey=" tag option. Without a merge key the input
+	// replaces the elements of the slice by position.
+	SliceMergeKey string
+
+	// ArrayFill is the policy for the elements of an array that the
+	// input has no elements for. See ArrayFillPolicy. A single field can
+	// require the input to have exactly as many elements as its array
+	// with the "exactlength" tag option, as in `mapstructure:"mac,exactlength"`.
+	ArrayFill ArrayFillPolicy
+
+	// SetDefaultsAfterDecode, if set to true, makes the decoder call
+	// SetDefaults on structs that implement DefaultSetter after they
+	// have been decoded rather than before. The fields that were set
+	// from the input keep their decoded values, so that SetDefaults can
+	// depend on them without overriding them.
+	SetDefaultsAfterDecode bool
+
 	// If WeaklyTypedInput is true, the decoder will make the following
 	// "weak" conversions:
 	//
 	//   - bools to string (true = "1", false = "0")
 	//   - numbers to string (base 10)
 	//   - bools to int/uint (true = 1, false = 0)
-	//   - strings to int/uint (base implied by prefix)
+	//   - strings to int/uint (base implied by prefix, see WeakIntBase)
+	//   - strings to time.Duration, either with a unit as accepted by
+	//     time.ParseDuration, such as "5s", or as a number of nanoseconds
 	//   - int to bool (true if value != 0)
 	//   - string to bool (accepts: 1, t, T, TRUE, true, True, 0, f, F,
 	//     FALSE, false, False. Anything else is an error)
-	//   - empty array = empty map and vice versa
+	//   - empty array = empty map and vice versa (unless
+	//     DisableWeakEmptyMapSlice is set)
 	//   - negative numbers to overflowed uint values (base 10)
 	//   - slice of maps to a merged map
 	//   - single values are converted to slices if required. Each
 	//     element is weakly decoded. For example: "4" can become []int{4}
 	//     if the target type is an int slice.
+	//   - strings of separated numbers to slices and arrays of numbers,
+	//     for example "1,2,3" to []int{1, 2, 3} (see WeakSliceSeparator)
+	//   - []byte to string, and to numbers and bools the same way as the
+	//     string it holds, for example []byte("42") to 42
 	//
 	WeaklyTypedInput bool
 
+	// WeakSliceSeparator is the separator that WeaklyTypedInput splits
+	// strings on when they are decoded into a slice or array of numbers.
+	// Spaces around the elements are trimmed. Defaults to ",".
+	WeakSliceSeparator string
+
+	// WeakIntBase is the base that WeaklyTypedInput parses strings in when
+	// they are decoded into ints and uints, as for strconv.ParseInt. The
+	// default, 0, implies the base from the prefix of the string, so that
+	// "0x1f" is hexadecimal and "0755" is octal. Set it to 10 to decode
+	// zero-padded numbers such as IDs as decimals.
+	WeakIntBase int
+
+	// DisableWeakEmptyMapSlice, if set to true, turns off the weak
+	// conversion of empty arrays and slices to empty maps and vice versa,
+	// so that those are errors like without WeaklyTypedInput.
+	DisableWeakEmptyMapSlice bool
+
+	// EmptyStringAsZero, if set to true, decodes the empty string into
+	// the zero value of time.Time and of pointers, which is nil, without
+	// calling the DecodeHook, since forms and CSV files often represent
+	// a missing value with it. Otherwise "" is a parse error for the
+	// hook that converts strings to time.Time, and is decoded into the
+	// value a pointer points to.
+	EmptyStringAsZero bool
+
+	// WeakStringInput, if set to true, turns on WeaklyTypedInput for the
+	// maps and slices of the input that hold nothing but strings, such as
+	// the map[string]string of an env file or of flags, or a nested map
+	// whose values are all strings. Those are decoded weakly, so that
+	// "8080" decodes into an int, while the values of inputs with real
+	// types, such as a map that holds both strings and numbers, are
+	// checked strictly.
+	WeakStringInput bool
+
+	// ExtendedBools, if set to true, decodes the strings "yes", "y",
+	// "on", "enable" and "enabled" to true and "no", "n", "off",
+	// "disable" and "disabled" to false, ignoring case, as well as the
+	// strings accepted by strconv.ParseBool. This is independent of
+	// WeaklyTypedInput, so that configs written for YAML 1.1 can be
+	// decoded without enabling any other conversion.
+	ExtendedBools bool
+
+	// StringBytes, if set to true, decodes strings into []byte and []byte
+	// (or byte arrays) into strings, which WeaklyTypedInput does too.
+	// Since these conversions are lossless, they can be enabled on their
+	// own without any of the other weak conversions.
+	StringBytes bool
+
+	// IncludeKey, if set, is a key of input maps whose value names other
+	// maps to merge into the map before it is decoded, such as "$include"
+	// or "<<". The value is a name or a list of names, which
+	// IncludeResolver looks up. Included maps are merged in order, so
+	// later ones override earlier ones, and the keys of the map itself
+	// override them all. Included maps can include others in turn.
+	IncludeKey string
+
+	// IncludeResolver returns the map with the given name for IncludeKey.
+	IncludeResolver func(name string) (map[string]interface{}, error)
+
+	// SingleValueToSlice, if set to true, decodes a single value into a
+	// slice or array as if it were a slice with that one element, so that
+	// "host: a" can be decoded into a []string. This is one of the
+	// conversions of WeaklyTypedInput, without enabling the others: the
+	// element itself is decoded as usual.
+	SingleValueToSlice bool
+
 	// Squash will squash embedded structs.  A squash tag may also be
 	// added to an individual struct field using a tag.  For example:
 	//
@@ -265,14 +543,257 @@ type DecoderConfig struct {
 	// defaults to "mapstructure"
 	TagName string
 
+	// SourceTagName, if set, is the tag name that is read for the keys of
+	// the fields of a struct that is decoded into another struct, instead
+	// of TagName. Fields of both structs are matched by their keys, not
+	// their Go names, so that a struct tagged for JSON can be decoded into
+	// a differently named one tagged for mapstructure with a SourceTagName
+	// of "json".
+	SourceTagName string
+
+	// RequireTags, if true, makes NewDecoder return an error if an
+	// exported field of the Result type has no tag. See VerifyStruct.
+	RequireTags bool
+
 	// IgnoreUntaggedFields ignores all struct fields without explicit
 	// TagName, comparable to `mapstructure:"-"` as default behaviour.
+	// Struct types can override this with an embedded Options field.
 	IgnoreUntaggedFields bool
 
+	// MatchField, if set, is used instead of MatchName to match the map
+	// keys to the struct fields. Unlike MatchName, it is given the whole
+	// StructField, so that it can consult other tags or the type of the
+	// field. All keys, including those that are equal to the field's
+	// name, are matched with MatchField. The keys of the paths of fields
+	// with a path (see PathTagName) are matched with MatchName instead.
+	MatchField func(mapKey string, field reflect.StructField) bool
+
+	// KeyTransform, if set, is applied to every key of the maps that are
+	// decoded into structs before the keys are matched with the fields,
+	// for example to strip a prefix or replace dashes with underscores.
+	// Unlike MatchName, which only compares keys, the transformed keys are
+	// also the ones reported in Metadata.Unused, in errors about unused
+	// keys and in the map of a ",remain" field.
+	KeyTransform func(key string) string
+
+	// XMLConventions, if true, decodes maps that were converted from XML
+	// with the conventions of libraries such as mxj and xml2json, where
+	// the attributes of an element are keys with an "@" prefix and its
+	// character data is the "#text" key. Fields with the "attr" tag
+	// option are decoded from the attribute of their name, and a field
+	// with the "chardata" option from the character data:
+	//
+	//   type Price struct {
+	//       Currency string  `mapstructure:"currency,attr"`
+	//       Amount   float64 `mapstructure:",chardata"`
+	//   }
+	//
+	// decodes {"@currency": "EUR", "#text": 9.5}. An element with only
+	// character data, which is converted to a scalar instead of a map,
+	// is decoded into the "chardata" field.
+	XMLConventions bool
+
+	// JSONTags, if true, uses the json tag of the fields that have no
+	// TagName tag, with the semantics of encoding/json: `json:"-"` skips
+	// the field, the name in the tag is the key of the field, omitempty
+	// leaves empty values out when encoding to a map, and embedded structs
+	// without a tag are squashed. This lets types that are maintained with
+	// json tags be decoded without duplicating them.
+	JSONTags bool
+
+	// DisableCache, if true, parses the tags of the fields of structs
+	// every time they are decoded into instead of using the plans that
+	// are cached per struct type and tag settings on first use. The cache
+	// never needs to be disabled for correctness, since types can't
+	// change at runtime; it is an escape hatch for debugging and for
+	// measuring its effect.
+	DisableCache bool
+
 	// MatchName is the function used to match the map key to the struct
 	// field name or tag. Defaults to `strings.EqualFold`. This can be used
 	// to implement case-sensitive tag values, support snake casing, etc.
+	// CaseSensitive, SnakeCaseFold and KebabCaseFold implement common
+	// strategies, and Chain combines them.
 	MatchName func(mapKey, fieldName string) bool
+
+	// StringifyKeys, if true, converts the keys of the interface-keyed
+	// maps in the input to strings with fmt.Sprint before decoding. YAML
+	// decoders such as gopkg.in/yaml.v2 produce map[interface{}]interface{}
+	// at every level, and keys that aren't strings, such as the 80 in
+	// "ports: {80: http}", can't otherwise be decoded into structs or
+	// maps with string keys. The keys of maps with other key types, such
+	// as a map[int]interface{}, are converted the same way when the map is
+	// decoded into a struct, so that its fields can be matched by name.
+	StringifyKeys bool
+
+	// Int64AsString, if true, encodes int64 and uint64 fields as decimal
+	// strings when a struct is decoded into a map, so that they keep
+	// their precision when the map is encoded as JSON for JavaScript,
+	// whose numbers are float64. Fields of named types such as
+	// time.Duration are left as they are. Single fields of any number or
+	// bool type can be encoded as strings with the "string" tag option, as
+	// in `mapstructure:"id,string"`. Use WeaklyTypedInput to decode the
+	// strings back into numbers.
+	Int64AsString bool
+
+	// EncodeStringers, if true, encodes fields whose type implements
+	// fmt.Stringer, such as enums and ID types, as the result of their
+	// String method when a struct is decoded into a map whose values can
+	// hold a string.
+	EncodeStringers bool
+
+	// EncodeAsStrings, if true, encodes every scalar field as a string when
+	// a struct is decoded into a map, so that it can be written to sinks
+	// that only hold strings, such as env files. Fields are formatted with
+	// their String or MarshalText method if they have one, and by the
+	// rules of WeaklyTypedInput otherwise, so that bools become "1" or "0".
+	// Nil pointers become empty strings and slices of scalars become
+	// []string. Nested structs are still encoded into nested maps; set
+	// FlatKeySeparator too to encode a nested struct into a
+	// map[string]string.
+	EncodeAsStrings bool
+
+	// EncodeKeyTransform, if set, returns the map key that a field is
+	// written under when a struct is decoded into a map, given the name
+	// of the field and the name in its tag, if any. SnakeCase, KebabCase
+	// and CamelCase write keys in the respective naming convention.
+	EncodeKeyTransform func(fieldName, tagName string) string
+
+	// KeyOrder, if set, orders the keys of the OrderedMaps that are built
+	// while decoding into an OrderedMap. It is called with the keys of
+	// each map in their default order (field declaration order for
+	// structs, source order otherwise) and reorders them in place, so
+	// that sort.Strings sorts the keys of every level lexically.
+	KeyOrder func(keys []string)
+
+	// InterfaceCopy is the policy for the maps, slices and pointers that
+	// are decoded into an interface{}, which are assigned as they are:
+	// by default they are shared with the input, and CopyDeep copies
+	// them. See CopyPolicy.
+	InterfaceCopy CopyPolicy
+
+	// KeepInterfaceValues, if set to true, decodes the entries of maps
+	// with interface elements into the concrete values that the map
+	// already holds under the same keys, such as default implementations,
+	// instead of replacing them with the input. For example, an entry
+	// holding a *Plugin decodes the map of the input into that *Plugin.
+	// Fields of interface type always decode into the concrete value they
+	// hold. It has no effect with ZeroFields.
+	KeepInterfaceValues bool
+
+	// TypeRegistry, if set, decodes the maps in the input that are decoded
+	// into an interface with methods into the concrete type registered
+	// under the value of their discriminator key. See TypeRegistry.
+	TypeRegistry *TypeRegistry
+
+	// PreserveNumbers is the policy for numbers that are decoded into an
+	// interface{}, including those inside maps and slices that are copied
+	// into one. See NumberPolicy.
+	PreserveNumbers NumberPolicy
+
+	// NumberTypes holds the types besides Go's numeric types that hold
+	// numbers, such as the types of decimal libraries, and how to read
+	// them, so that they can be decoded into ints, uints and floats like
+	// json.Number, which is always known. An entry for json.Number
+	// replaces the built-in handling. See NumberType.
+	NumberTypes map[reflect.Type]NumberType
+
+	// FlatKeySeparator, if set, expands flat keys in the input into nested
+	// maps before decoding. Keys are split on the separator, and indexes
+	// written in brackets address slice elements, so that with a separator
+	// of "." the input
+	//
+	//   map[string]interface{}{
+	//       "server.http.port": 8080,
+	//       "hosts[0]":         "a",
+	//   }
+	//
+	// is decoded as if it were
+	//
+	//   map[string]interface{}{
+	//       "server": map[string]interface{}{
+	//           "http": map[string]interface{}{"port": 8080},
+	//       },
+	//       "hosts": []interface{}{"a"},
+	//   }
+	//
+	// A backslash escapes the character that follows it, so that keys can
+	// contain the separator or brackets: `a\.b` is the single key "a.b".
+	// Indexes above 65535 are an error, so that a key such as
+	// "hosts[999999999]" can't make the decoder allocate a huge slice.
+	//
+	// When a struct is encoded into a map with string keys, the separator
+	// works the other way around: the result is flattened, so that nested
+	// structs and slices become keys like "server.http.port" and
+	// "hosts[0]", with the separator and brackets in keys escaped.
+	FlatKeySeparator string
+
+	// PathTagName, if set, is the tag that holds a path to decode a field
+	// from, such as `jpath:"server.listeners[0].port"`. The value is looked
+	// up below the map that the field's struct is decoded from, instead
+	// of under the field's name. Keys in the path are separated by dots and
+	// slice elements are addressed either by an index in brackets or by a
+	// numeric key, so "items[0].name" and "items.0.name" are the same path.
+	// A "*" segment is a wildcard that matches every element of a map or
+	// slice, and the matching values are collected into a slice, so that
+	// `jpath:"clusters.*.endpoint"` decodes the endpoint of every cluster
+	// into a []string field. A backslash escapes the character that follows
+	// it. Fields without the tag are decoded as usual. A path can also be
+	// given in the main tag with the "path" option, without setting
+	// PathTagName, as in `mapstructure:"server.listeners[0].port,path"`.
+	PathTagName string
+
+	// MultiValueSeparator, if set, splits each value of a multi-value map
+	// source such as url.Values or http.Header on the separator when it
+	// is decoded into a slice or array, trimming the spaces around each
+	// element. For example, with a separator of "," the header
+	// "Accept: text/html, text/plain" decodes into a []string with two
+	// elements.
+	MultiValueSeparator string
+
+	// ErrorMessages overrides message templates, mapping the ID of each
+	// message, such as MsgUnassignableType, to the template used instead
+	// of its default. See DecodingError for the placeholders that can be
+	// used in a template.
+	ErrorMessages map[MessageID]string
+
+	// TranslateErrorMessage, if set, is called with the message template
+	// of every error that has no entry in ErrorMessages and returns the
+	// template to use instead. This can be used to look up translations
+	// of the default (English) templates in a message catalog.
+	TranslateErrorMessage func(kind DecodingErrorKind, template string) string
+
+	// ErrorNames chooses whether error messages name the values that
+	// failed to decode by the path of their field in the result, the
+	// default, by their path in the input, or by both. See ErrorNames.
+	ErrorNames ErrorNames
+
+	// RootLabel, if set, prefixes the message of every error, so that a
+	// label such as the name of the file or section being decoded needn't
+	// be added by the caller, as in "app.yaml: cannot parse 'server.port'
+	// as int: ...". It is also stored in the Root field of each
+	// DecodingError; the Name is left unchanged.
+	RootLabel string
+
+	// Positions, if set, is used to look up the position in the source
+	// document of the values that failed to decode. The position is
+	// stored in the Position field of each DecodingError and included in
+	// its message.
+	Positions PositionProvider
+
+	// ErrorsFormatter renders the message of the Error returned when
+	// decoding fails with multiple errors. Defaults to
+	// DefaultDecodingErrorsFormatter, which lists the errors in the order
+	// they were encountered. Use SortedDecodingErrorsFormatter to sort them
+	// lexically instead.
+	ErrorsFormatter DecodingErrorsFormatter
+
+	// ValueFormatter renders the input values that error messages
+	// include, such as the value that couldn't be converted. Defaults to
+	// DefaultValueFormatter, which renders them in full. Use
+	// TruncatingValueFormatter to bound their length, or
+	// TypeValueFormatter to only render their type.
+	ValueFormatter ValueFormatter
 }
 
 // A Decoder takes a raw interface value and turns it into structured
@@ -283,6 +804,43 @@ type DecoderConfig struct {
 // up the most basic Decoder.
 type Decoder struct {
 	config *DecoderConfig
+
+	// deepCopy is set by DeepCopy so that values that are assigned
+	// as-is are copied rather than shared with the input.
+	deepCopy bool
+
+	// secret is set while decoding a field with the "secret" tag option
+	// so that its errors are redacted.
+	secret bool
+
+	// ctx is set by DecodeContext so that decoding stops once it is done.
+	ctx context.Context
+
+	// markSecrets is set by Diff so that the values of fields with the
+	// "secret" tag option are wrapped in a secretValue when they are
+	// encoded into a map[string]interface{}.
+	markSecrets bool
+
+	// exactLength is set while decoding the value of a field with the
+	// "exactlength" tag option. It applies to the array held by the
+	// field, possibly through pointers, and not to the values nested in
+	// it.
+	exactLength bool
+
+	// mergeKey is set like exactLength for a field with the "mergekey="
+	// tag option, and overrides SliceMergeKey for the slice it holds.
+	mergeKey string
+
+	// stringContainers caches the results of isStringContainer for the
+	// maps and slices of the input during a single decode.
+	stringContainers map[containerID]bool
+}
+
+// containerID identifies a map or slice of the input.
+type containerID struct {
+	typ reflect.Type
+	ptr uintptr
+	len int
 }
 
 // Metadata contains information about decoding a structure that
@@ -299,6 +857,39 @@ type Metadata struct {
 	// but weren't set in the decoding process since there was no matching value
 	// in the input
 	Unset []string
+
+	// Weak lists the values that were only decoded because of
+	// WeaklyTypedInput, such as the string "42" decoded into an int, in
+	// the order they were decoded. It can be used to find the inputs that
+	// rely on weak conversions before turning WeaklyTypedInput off.
+	Weak []WeakConversion
+}
+
+// WeakConversion is a value that was converted from a type that it can
+// only be decoded from with WeaklyTypedInput.
+type WeakConversion struct {
+	// Name is the name of the value, in the same form as the names in
+	// Metadata.Keys.
+	Name string
+
+	// From is the type of the input value.
+	From string
+
+	// To is the type the value was decoded into.
+	To string
+}
+
+// recordWeak adds the weak conversion of dataVal into val to the metadata.
+func (d *Decoder) recordWeak(name string, dataVal, val reflect.Value) {
+	if d.config.Metadata == nil {
+		return
+	}
+
+	d.config.Metadata.Weak = append(d.config.Metadata.Weak, WeakConversion{
+		Name: name,
+		From: dataVal.Type().String(),
+		To:   val.Type().String(),
+	})
 }
 
 // Decode takes an input structure and uses reflection to translate it to
@@ -350,6 +941,62 @@ func DecodeMetadata(input interface{}, output interface{}, metadata *Metadata) e
 	return decoder.Decode(input)
 }
 
+// DecodePath is the same as Decode, but fields tagged with "jpath" are
+// decoded from the value at the path in the tag, so that
+//
+//   type User struct {
+//       ID   int    `jpath:"userContext.cobrandId"`
+//       Item string `jpath:"items[0].name"`
+//   }
+//
+// pulls its values from deep inside the input. See PathTagName in
+// DecoderConfig for the path syntax.
+func DecodePath(input interface{}, output interface{}) error {
+	config := &DecoderConfig{
+		Metadata:    nil,
+		Result:      output,
+		PathTagName: "jpath",
+	}
+
+	decoder, err := NewDecoder(config)
+	if err != nil {
+		return err
+	}
+
+	return decoder.Decode(input)
+}
+
+// DecodeJSON reads a JSON value from r and decodes it into output. Numbers
+// are read as json.Number, so that large integers don't lose precision on
+// their way through float64. Like json.Unmarshal, anything but white
+// space after the value is an error. If a config is given, it is used for
+// the decoding; its Result is ignored.
+func DecodeJSON(r io.Reader, output interface{}, config ...*DecoderConfig) error {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	var input interface{}
+	if err := dec.Decode(&input); err != nil {
+		return err
+	}
+	if tok, err := dec.Token(); err != io.EOF {
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("unexpected %v after the JSON value", tok)
+	}
+
+	c := copyConfig(config...)
+	c.Result = output
+
+	decoder, err := NewDecoder(c)
+	if err != nil {
+		return err
+	}
+
+	return decoder.Decode(input)
+}
+
 // WeakDecodeMetadata is the same as Decode, but is shorthand to
 // enable both WeaklyTypedInput and metadata collection. See
 // DecoderConfig for more info.
@@ -368,6 +1015,53 @@ func WeakDecodeMetadata(input interface{}, output interface{}, metadata *Metadat
 	return decoder.Decode(input)
 }
 
+// DecoderOption changes the configuration that DecodeTo decodes with.
+type DecoderOption func(*DecoderConfig)
+
+// DecodeTo decodes input into a new value of type T and returns it, so
+// that the result needn't be declared and passed by pointer:
+//
+//   config, err := mapstructure.DecodeTo[Config](input, func(c *mapstructure.DecoderConfig) {
+//       c.WeaklyTypedInput = true
+//   })
+//
+// The options are applied in order to an empty DecoderConfig; its Result
+// is ignored. If decoding fails, the zero value of T is returned with the
+// error.
+func DecodeTo[T any](input interface{}, opts ...DecoderOption) (T, error) {
+	var result T
+
+	config := &DecoderConfig{}
+	for _, opt := range opts {
+		opt(config)
+	}
+	config.Result = &result
+
+	decoder, err := NewDecoder(config)
+	if err != nil {
+		return result, err
+	}
+
+	if err := decoder.Decode(input); err != nil {
+		var zero T
+		return zero, err
+	}
+
+	return result, nil
+}
+
+// copyConfig returns a copy of the first config, if any, or a new
+// DecoderConfig, so that the helpers taking an optional config can set its
+// Result and defaults without changing the caller's config.
+func copyConfig(config ...*DecoderConfig) *DecoderConfig {
+	if len(config) == 0 || config[0] == nil {
+		return &DecoderConfig{}
+	}
+
+	copied := *config[0]
+	return &copied
+}
+
 // NewDecoder returns a new decoder for the given configuration. Once
 // a decoder has been returned, the same configuration must not be used
 // again.
@@ -404,6 +1098,38 @@ func NewDecoder(config *DecoderConfig) (*Decoder, error) {
 		config.MatchName = strings.EqualFold
 	}
 
+	if config.WeakSliceSeparator == "" {
+		config.WeakSliceSeparator = ","
+	}
+
+	if config.WeakIntBase == 1 || config.WeakIntBase < 0 || config.WeakIntBase > 36 {
+		return nil, fmt.Errorf("invalid WeakIntBase %d", config.WeakIntBase)
+	}
+
+	if config.ErrorsFormatter == nil {
+		config.ErrorsFormatter = DefaultDecodingErrorsFormatter
+	}
+
+	// The hook is converted to its typed form once here rather than on
+	// every call.
+	if config.DecodeHook != nil {
+		if typed := typedDecodeHook(config.DecodeHook); typed != nil {
+			config.DecodeHook = typed
+		}
+	}
+
+	for typ, provide := range config.ZeroValueProviders {
+		if v := reflect.ValueOf(provide()); v.IsValid() && v.Type() != typ {
+			return nil, fmt.Errorf("zero value provider for %s returns a %s", typ, v.Type())
+		}
+	}
+
+	if config.RequireTags {
+		if err := VerifyStruct(config.Result, config); err != nil {
+			return nil, err
+		}
+	}
+
 	result := &Decoder{
 		config: config,
 	}
@@ -414,15 +1140,279 @@ func NewDecoder(config *DecoderConfig) (*Decoder, error) {
 // Decode decodes the given raw interface to the target pointer specified
 // by the configuration.
 func (d *Decoder) Decode(input interface{}) error {
-	return d.decode("", input, reflect.ValueOf(d.config.Result).Elem())
+	return d.decodeAt(input, "", nil)
+}
+
+// DecodeContext is the same as Decode, but it stops decoding once ctx is
+// done and returns ctx.Err(). ctx is checked before each element of the
+// slices, arrays and maps and each field of the structs that are decoded,
+// so that decoding a large input can be bounded by a timeout. The result
+// may be partially decoded when decoding is stopped.
+func (d *Decoder) DecodeContext(ctx context.Context, input interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	decoder := *d
+	decoder.ctx = ctx
+	if err := decoder.Decode(input); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return err
+	}
+
+	return nil
+}
+
+// ctxErr returns the error of the context of DecodeContext if it is done.
+func (d *Decoder) ctxErr() error {
+	if d.ctx == nil {
+		return nil
+	}
+
+	return d.ctx.Err()
+}
+
+// DecodeAt is the same as Decode, but it decodes only the value at path in
+// the input, such as "services.web", into the result. The path uses the
+// syntax described for PathTagName. Errors and metadata still name the
+// keys by their full path in the input, and an error of kind
+// DecodingErrorPathNotFound is returned if there is no value at path.
+func (d *Decoder) DecodeAt(input interface{}, path string) error {
+	segments, err := parsePath(path, ".")
+	if err != nil {
+		return d.decodingError(MsgInvalidPath, &DecodingError{
+			Kind: DecodingErrorGeneric,
+			Name: path,
+			Err:  err,
+		})
+	}
+
+	return d.decodeAt(input, path, segments)
+}
+
+func (d *Decoder) decodeAt(input interface{}, path string, segments []pathSegment) error {
+	result := reflect.ValueOf(d.config.Result).Elem()
+	if !d.config.Atomic {
+		return d.decodeRoot(input, path, segments, result)
+	}
+
+	copied := reflect.New(result.Type()).Elem()
+	copied.Set(deepCopyValue(result, map[copiedPointer]reflect.Value{}))
+	if err := d.decodeRoot(input, path, segments, copied); err != nil {
+		return err
+	}
+
+	result.Set(copied)
+	return nil
+}
+
+// decodeRoot is decodeAt, but decodes into outVal instead of the Result.
+func (d *Decoder) decodeRoot(input interface{}, path string, segments []pathSegment, outVal reflect.Value) error {
+	if d.config.WeakStringInput {
+		// The containers nested in the input are checked for strings
+		// once, not again at every level they are decoded at.
+		copied := *d
+		copied.stringContainers = make(map[containerID]bool)
+		d = &copied
+	}
+
+	if d.config.StringifyKeys {
+		input = stringifyKeys(input)
+	}
+
+	if d.config.FlatKeySeparator != "" {
+		expanded, err := expandFlatKeys(input, d.config.FlatKeySeparator)
+		if err != nil {
+			return d.decodingError(MsgInvalidKey, &DecodingError{
+				Kind: DecodingErrorGeneric,
+				Err:  err,
+			})
+		}
+		input = expanded
+	}
+
+	if len(segments) > 0 {
+		v, ok := d.lookupPath(input, segments)
+		if !ok {
+			return d.finishError(d.decodingError(MsgPathNotFound, &DecodingError{
+				Kind: DecodingErrorPathNotFound,
+				Name: path,
+			}))
+		}
+		input = v
+	}
+
+	// Nested structs can only be flattened into a map of interface{}s, so
+	// a struct is flattened into one first if the result is a map of
+	// another type, such as a map[string]string.
+	target, indirect := outVal, false
+	if d.config.FlatKeySeparator != "" && isStructInput(input) &&
+		outVal.Kind() == reflect.Map &&
+		outVal.Type().Key().Kind() == reflect.String &&
+		outVal.Type().Elem().Kind() != reflect.Interface {
+		target, indirect = reflect.New(reflect.TypeOf(map[string]interface{}{})).Elem(), true
+	}
+
+	err := d.decodeInput(path, input, target, !d.config.SkipRootDecodeHook)
+	if err == nil && d.config.FlatKeySeparator != "" {
+		err = d.flattenResult(input, target)
+	}
+	if err == nil && indirect {
+		err = d.decode(path, target.Interface(), outVal)
+	}
+
+	if err != nil {
+		for i := len(segments) - 1; i >= 0; i-- {
+			err = withSourceKey(err, segments[i].String())
+		}
+	}
+
+	return d.finishError(err)
+}
+
+// isStructInput reports whether input is a struct or a pointer to one.
+func isStructInput(input interface{}) bool {
+	return reflect.Indirect(reflect.ValueOf(input)).Kind() == reflect.Struct
+}
+
+// isStringContainer reports whether v is a map with string keys, or a
+// slice or array, that holds nothing but strings, directly or in nested
+// maps and slices. The results for maps and slices are cached, so that
+// checking a container and then the containers nested in it doesn't scan
+// them again.
+func (d *Decoder) isStringContainer(v reflect.Value) bool {
+	v = reflect.Indirect(v)
+	if d.stringContainers == nil || (v.Kind() != reflect.Map && v.Kind() != reflect.Slice) {
+		return d.scanStringContainer(v)
+	}
+
+	id := containerID{v.Type(), v.Pointer(), v.Len()}
+	if ok, cached := d.stringContainers[id]; cached {
+		return ok
+	}
+
+	ok := d.scanStringContainer(v)
+	d.stringContainers[id] = ok
+	return ok
+}
+
+// scanStringContainer is isStringContainer without the cache for v itself.
+func (d *Decoder) scanStringContainer(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return false
+		}
+		if v.Type().Elem().Kind() == reflect.String {
+			return true
+		}
+
+		iter := v.MapRange()
+		for iter.Next() {
+			if !d.isStringValue(iter.Value()) {
+				return false
+			}
+		}
+		return true
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.String {
+			return true
+		}
+
+		for i := 0; i < v.Len(); i++ {
+			if !d.isStringValue(v.Index(i)) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// isStringValue reports whether v is a string or a string container.
+func (d *Decoder) isStringValue(v reflect.Value) bool {
+	if v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	v = reflect.Indirect(v)
+
+	return v.Kind() == reflect.String || d.isStringContainer(v)
+}
+
+// flattenResult flattens the map that a struct was encoded into, so that
+// nested maps and slices become keys such as "server.ports[0]".
+func (d *Decoder) flattenResult(input interface{}, outVal reflect.Value) error {
+	if outVal.Kind() != reflect.Map ||
+		outVal.Type().Key().Kind() != reflect.String ||
+		outVal.Type().Elem().Kind() != reflect.Interface {
+		return nil
+	}
+	if !isStructInput(input) {
+		return nil
+	}
+
+	encode := func(v interface{}) (interface{}, error) {
+		var m map[string]interface{}
+		err := d.decode("", v, reflect.ValueOf(&m).Elem())
+		return m, err
+	}
+
+	flat := make(map[string]interface{}, outVal.Len())
+	for _, k := range outVal.MapKeys() {
+		key := escapePathKey(k.String(), d.config.FlatKeySeparator)
+		err := flattenValue(flat, key, outVal.MapIndex(k).Interface(), d.config.FlatKeySeparator, encode)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, k := range outVal.MapKeys() {
+		outVal.SetMapIndex(k, reflect.Value{})
+	}
+	for k, v := range flat {
+		outVal.SetMapIndex(reflect.ValueOf(k).Convert(outVal.Type().Key()), reflect.ValueOf(&v).Elem())
+	}
+
+	return nil
 }
 
 // Decodes an unknown data type into a specific reflection value.
 func (d *Decoder) decode(name string, input interface{}, outVal reflect.Value) error {
+	return d.decodeInput(name, input, outVal, true)
+}
+
+// decodeInput is decode, but only calls the DecodeHook on the input if
+// runHook is true.
+func (d *Decoder) decodeInput(name string, input interface{}, outVal reflect.Value, runHook bool) error {
+	if node, ok := input.(Node); ok {
+		return d.decodeNode(name, node, outVal, runHook)
+	}
+
 	var inputVal reflect.Value
 	if input != nil {
 		inputVal = reflect.ValueOf(input)
 
+		// Pointer chains in the input, such as a **T, are followed down to
+		// their last pointer so that they decode like a *T. A chain that
+		// loops, as a "type P *P" can, stops where it returns to a pointer
+		// it has already followed.
+		var followed map[uintptr]struct{}
+		for inputVal.Kind() == reflect.Ptr && !inputVal.IsNil() && inputVal.Elem().Kind() == reflect.Ptr {
+			if followed == nil {
+				followed = make(map[uintptr]struct{})
+			}
+			if _, ok := followed[inputVal.Pointer()]; ok {
+				break
+			}
+			followed[inputVal.Pointer()] = struct{}{}
+
+			inputVal = inputVal.Elem()
+			input = inputVal.Interface()
+		}
+
 		// We need to check here if input is a typed nil. Typed nils won't
 		// match the "input == nil" below so we check that here.
 		if inputVal.Kind() == reflect.Ptr && inputVal.IsNil() {
@@ -434,7 +1424,7 @@ func (d *Decoder) decode(name string, input interface{}, outVal reflect.Value) e
 		// If the data is nil, then we don't set anything, unless ZeroFields is set
 		// to true.
 		if d.config.ZeroFields {
-			outVal.Set(reflect.Zero(outVal.Type()))
+			outVal.Set(d.zeroValue(outVal.Type()))
 
 			if d.config.Metadata != nil && name != "" {
 				d.config.Metadata.Keys = append(d.config.Metadata.Keys, name)
@@ -453,54 +1443,105 @@ func (d *Decoder) decode(name string, input interface{}, outVal reflect.Value) e
 		return nil
 	}
 
-	if d.config.DecodeHook != nil {
+	if d.config.EmptyStringAsZero && inputVal.Kind() == reflect.String && inputVal.Len() == 0 &&
+		(outVal.Kind() == reflect.Ptr || outVal.Type() == timeType) {
+		outVal.Set(reflect.Zero(outVal.Type()))
+		if d.config.Metadata != nil && name != "" {
+			d.config.Metadata.Keys = append(d.config.Metadata.Keys, name)
+		}
+		return nil
+	}
+
+	if d.config.WeakStringInput && !d.config.WeaklyTypedInput && d.isStringContainer(inputVal) {
+		weak := d.withConfig(func(c *DecoderConfig) {
+			c.WeaklyTypedInput = true
+		})
+		return weak.decodeInput(name, input, outVal, runHook)
+	}
+
+	if d.config.DecodeHook != nil && runHook {
 		// We have a DecodeHook, so let's pre-process the input.
 		var err error
 		input, err = DecodeHookExec(d.config.DecodeHook, inputVal, outVal)
 		if err != nil {
-			return fmt.Errorf("error decoding '%s': %w", name, err)
+			return d.decodingError(MsgHookFailure, &DecodingError{
+				Kind: DecodingErrorHookFailure,
+				Name: name,
+				Err:  err,
+			})
 		}
 	}
 
 	var err error
 	outputKind := getKind(outVal)
 	addMetaKey := true
-	switch outputKind {
-	case reflect.Bool:
-		err = d.decodeBool(name, input, outVal)
-	case reflect.Interface:
-		err = d.decodeBasic(name, input, outVal)
-	case reflect.String:
-		err = d.decodeString(name, input, outVal)
-	case reflect.Int:
-		err = d.decodeInt(name, input, outVal)
-	case reflect.Uint:
-		err = d.decodeUint(name, input, outVal)
-	case reflect.Float32:
-		err = d.decodeFloat(name, input, outVal)
-	case reflect.Struct:
-		err = d.decodeStruct(name, input, outVal)
-	case reflect.Map:
-		err = d.decodeMap(name, input, outVal)
-	case reflect.Ptr:
-		addMetaKey, err = d.decodePtr(name, input, outVal)
-	case reflect.Slice:
-		err = d.decodeSlice(name, input, outVal)
-	case reflect.Array:
-		err = d.decodeArray(name, input, outVal)
-	case reflect.Func:
-		err = d.decodeFunc(name, input, outVal)
-	default:
-		// If we reached this point then we weren't able to decode it
-		return fmt.Errorf("%s: unsupported type: %s", name, outputKind)
-	}
-
-	// If we reached here, then we successfully decoded SOMETHING, so
-	// mark the key as used if we're tracking metainput.
+	if outputKind != reflect.Array && outputKind != reflect.Slice && outputKind != reflect.Ptr {
+		d = d.nested()
+	}
+	if u, ok := unmarshaler(outVal); ok {
+		err = d.decodeUnmarshaler(name, input, u)
+	} else if registered, registryErr := d.decodeRegistered(name, input, outVal); registered {
+		err = registryErr
+	} else if u, ok := d.textUnmarshaler(input, outVal); ok {
+		err = d.decodeTextUnmarshaler(name, input, outVal, u)
+	} else {
+		switch outputKind {
+		case reflect.Bool:
+			err = d.decodeBool(name, input, outVal)
+		case reflect.Interface:
+			err = d.decodeBasic(name, input, outVal)
+		case reflect.String:
+			err = d.decodeString(name, input, outVal)
+		case reflect.Int:
+			err = d.decodeInt(name, input, outVal)
+		case reflect.Uint:
+			err = d.decodeUint(name, input, outVal)
+		case reflect.Float32:
+			err = d.decodeFloat(name, input, outVal)
+		case reflect.Struct:
+			if outVal.Type() == orderedMapType {
+				err = d.decodeOrderedMap(name, input, outVal)
+			} else {
+				err = d.decodeStruct(name, input, outVal)
+			}
+		case reflect.Map:
+			err = d.decodeMap(name, input, outVal)
+		case reflect.Ptr:
+			addMetaKey, err = d.decodePtr(name, input, outVal)
+		case reflect.Slice:
+			err = d.decodeSlice(name, input, outVal)
+		case reflect.Array:
+			err = d.decodeArray(name, input, outVal)
+		case reflect.Func:
+			err = d.decodeFunc(name, input, outVal)
+		default:
+			// If we reached this point then we weren't able to decode it
+			return d.decodingError(MsgUnsupportedType, &DecodingError{
+				Kind:     DecodingErrorUnsupportedType,
+				Name:     name,
+				Expected: outputKind.String(),
+			})
+		}
+	}
+
+	// If we reached here, then we successfully decoded SOMETHING, so
+	// mark the key as used if we're tracking metainput.
 	if addMetaKey && d.config.Metadata != nil && name != "" {
 		d.config.Metadata.Keys = append(d.config.Metadata.Keys, name)
 	}
 
+	// A pointer that was decoded through its element has already been
+	// passed to the OutputHook as the element.
+	if err == nil && addMetaKey && d.config.OutputHook != nil {
+		if hookErr := d.config.OutputHook(namespaceOf(name), outVal); hookErr != nil {
+			return d.decodingError(MsgHookFailure, &DecodingError{
+				Kind: DecodingErrorHookFailure,
+				Name: name,
+				Err:  hookErr,
+			})
+		}
+	}
+
 	return err
 }
 
@@ -538,6 +1579,14 @@ func (d *Decoder) decodeBasic(name string, data interface{}, val reflect.Value)
 		return nil
 	}
 
+	if d.config.PreserveNumbers != NumbersAsIs && val.Kind() == reflect.Interface && val.NumMethod() == 0 {
+		converted, err := convertNumbers(data, d.config.PreserveNumbers)
+		if err != nil {
+			return d.parseError(name, "number", data, err)
+		}
+		data = converted
+	}
+
 	dataVal := reflect.ValueOf(data)
 
 	// If the input data is a pointer, and the assigned type is the dereference
@@ -553,12 +1602,16 @@ func (d *Decoder) decodeBasic(name string, data interface{}, val reflect.Value)
 
 	dataValType := dataVal.Type()
 	if !dataValType.AssignableTo(val.Type()) {
-		return fmt.Errorf(
-			"'%s' expected type '%s', got '%s'",
-			name, val.Type(), dataValType)
+		return d.decodingError(MsgUnassignableType, &DecodingError{
+			Kind:     DecodingErrorUnconvertibleType,
+			Name:     name,
+			Expected: val.Type().String(),
+			Got:      dataValType.String(),
+			Value:    data,
+		})
 	}
 
-	val.Set(dataVal)
+	val.Set(d.copyValue(dataVal, d.config.InterfaceCopy))
 	return nil
 }
 
@@ -566,10 +1619,11 @@ func (d *Decoder) decodeString(name string, data interface{}, val reflect.Value)
 	dataVal := reflect.Indirect(reflect.ValueOf(data))
 	dataKind := getKind(dataVal)
 
-	converted := true
+	converted, weak := true, d.config.WeaklyTypedInput
 	switch {
 	case dataKind == reflect.String:
 		val.SetString(dataVal.String())
+		weak = false
 	case dataKind == reflect.Bool && d.config.WeaklyTypedInput:
 		if dataVal.Bool() {
 			val.SetString("1")
@@ -582,12 +1636,13 @@ func (d *Decoder) decodeString(name string, data interface{}, val reflect.Value)
 		val.SetString(strconv.FormatUint(dataVal.Uint(), 10))
 	case dataKind == reflect.Float32 && d.config.WeaklyTypedInput:
 		val.SetString(strconv.FormatFloat(dataVal.Float(), 'f', -1, 64))
-	case dataKind == reflect.Slice && d.config.WeaklyTypedInput,
-		dataKind == reflect.Array && d.config.WeaklyTypedInput:
+	case dataKind == reflect.Slice && (d.config.WeaklyTypedInput || d.config.StringBytes),
+		dataKind == reflect.Array && (d.config.WeaklyTypedInput || d.config.StringBytes):
 		dataType := dataVal.Type()
 		elemKind := dataType.Elem().Kind()
 		switch elemKind {
 		case reflect.Uint8:
+			weak = !d.config.StringBytes
 			var uints []uint8
 			if dataKind == reflect.Array {
 				uints = make([]uint8, dataVal.Len(), dataVal.Len())
@@ -606,18 +1661,34 @@ func (d *Decoder) decodeString(name string, data interface{}, val reflect.Value)
 	}
 
 	if !converted {
-		return fmt.Errorf(
-			"'%s' expected type '%s', got unconvertible type '%s', value: '%v'",
-			name, val.Type(), dataVal.Type(), data)
+		return d.unconvertibleTypeError(name, val, dataVal, data)
+	}
+
+	if weak {
+		d.recordWeak(name, dataVal, val)
 	}
 
 	return nil
 }
 
+// weakBytes returns the string held by dataVal if it is a []byte, as
+// returned by many database drivers, and WeaklyTypedInput is set, so that
+// it can be decoded into numbers and bools like that string.
+func (d *Decoder) weakBytes(dataVal reflect.Value) (string, bool) {
+	if !d.config.WeaklyTypedInput || dataVal.Kind() != reflect.Slice || dataVal.Type().Elem().Kind() != reflect.Uint8 {
+		return "", false
+	}
+
+	return string(dataVal.Bytes()), true
+}
+
 func (d *Decoder) decodeInt(name string, data interface{}, val reflect.Value) error {
 	dataVal := reflect.Indirect(reflect.ValueOf(data))
+	if str, ok := d.weakBytes(dataVal); ok {
+		return d.decodeInt(name, str, val)
+	}
 	dataKind := getKind(dataVal)
-	dataType := dataVal.Type()
+	number := d.numberType(dataVal.Type())
 
 	switch {
 	case dataKind == reflect.Int:
@@ -632,30 +1703,36 @@ func (d *Decoder) decodeInt(name string, data interface{}, val reflect.Value) er
 		} else {
 			val.SetInt(0)
 		}
+		d.recordWeak(name, dataVal, val)
 	case dataKind == reflect.String && d.config.WeaklyTypedInput:
 		str := dataVal.String()
 		if str == "" {
 			str = "0"
 		}
 
-		i, err := strconv.ParseInt(str, 0, val.Type().Bits())
+		i, err := strconv.ParseInt(str, d.config.WeakIntBase, val.Type().Bits())
+		if err != nil && val.Type() == durationType {
+			// Durations with a unit, such as "5s"
+			var dur time.Duration
+			if dur, err = time.ParseDuration(str); err != nil {
+				return d.parseError(name, "duration", data, err)
+			}
+			i = int64(dur)
+		}
 		if err == nil {
 			val.SetInt(i)
+			d.recordWeak(name, dataVal, val)
 		} else {
-			return fmt.Errorf("cannot parse '%s' as int: %s", name, err)
+			return d.parseError(name, "int", data, err)
 		}
-	case dataType.PkgPath() == "encoding/json" && dataType.Name() == "Number":
-		jn := data.(json.Number)
-		i, err := jn.Int64()
+	case number.Int64 != nil:
+		n, err := number.Int64(dataVal.Interface())
 		if err != nil {
-			return fmt.Errorf(
-				"error decoding json.Number into %s: %s", name, err)
+			return d.numberError(name, val, dataVal, data, err)
 		}
-		val.SetInt(i)
+		val.SetInt(n)
 	default:
-		return fmt.Errorf(
-			"'%s' expected type '%s', got unconvertible type '%s', value: '%v'",
-			name, val.Type(), dataVal.Type(), data)
+		return d.unconvertibleTypeError(name, val, dataVal, data)
 	}
 
 	return nil
@@ -663,15 +1740,25 @@ func (d *Decoder) decodeInt(name string, data interface{}, val reflect.Value) er
 
 func (d *Decoder) decodeUint(name string, data interface{}, val reflect.Value) error {
 	dataVal := reflect.Indirect(reflect.ValueOf(data))
+	if str, ok := d.weakBytes(dataVal); ok {
+		return d.decodeUint(name, str, val)
+	}
 	dataKind := getKind(dataVal)
-	dataType := dataVal.Type()
+	number := d.numberType(dataVal.Type())
 
 	switch {
 	case dataKind == reflect.Int:
 		i := dataVal.Int()
 		if i < 0 && !d.config.WeaklyTypedInput {
-			return fmt.Errorf("cannot parse '%s', %d overflows uint",
-				name, i)
+			return d.decodingError(MsgOverflow, &DecodingError{
+				Kind:     DecodingErrorOverflow,
+				Name:     name,
+				Expected: "uint",
+				Value:    i,
+			})
+		}
+		if i < 0 {
+			d.recordWeak(name, dataVal, val)
 		}
 		val.SetUint(uint64(i))
 	case dataKind == reflect.Uint:
@@ -679,8 +1766,15 @@ func (d *Decoder) decodeUint(name string, data interface{}, val reflect.Value) e
 	case dataKind == reflect.Float32:
 		f := dataVal.Float()
 		if f < 0 && !d.config.WeaklyTypedInput {
-			return fmt.Errorf("cannot parse '%s', %f overflows uint",
-				name, f)
+			return d.decodingError(MsgOverflow, &DecodingError{
+				Kind:     DecodingErrorOverflow,
+				Name:     name,
+				Expected: "uint",
+				Value:    strconv.FormatFloat(f, 'f', 6, 64),
+			})
+		}
+		if f < 0 {
+			d.recordWeak(name, dataVal, val)
 		}
 		val.SetUint(uint64(f))
 	case dataKind == reflect.Bool && d.config.WeaklyTypedInput:
@@ -689,30 +1783,28 @@ func (d *Decoder) decodeUint(name string, data interface{}, val reflect.Value) e
 		} else {
 			val.SetUint(0)
 		}
+		d.recordWeak(name, dataVal, val)
 	case dataKind == reflect.String && d.config.WeaklyTypedInput:
 		str := dataVal.String()
 		if str == "" {
 			str = "0"
 		}
 
-		i, err := strconv.ParseUint(str, 0, val.Type().Bits())
+		i, err := strconv.ParseUint(str, d.config.WeakIntBase, val.Type().Bits())
 		if err == nil {
 			val.SetUint(i)
+			d.recordWeak(name, dataVal, val)
 		} else {
-			return fmt.Errorf("cannot parse '%s' as uint: %s", name, err)
+			return d.parseError(name, "uint", data, err)
 		}
-	case dataType.PkgPath() == "encoding/json" && dataType.Name() == "Number":
-		jn := data.(json.Number)
-		i, err := strconv.ParseUint(string(jn), 0, 64)
+	case number.Uint64 != nil:
+		n, err := number.Uint64(dataVal.Interface())
 		if err != nil {
-			return fmt.Errorf(
-				"error decoding json.Number into %s: %s", name, err)
+			return d.numberError(name, val, dataVal, data, err)
 		}
-		val.SetUint(i)
+		val.SetUint(n)
 	default:
-		return fmt.Errorf(
-			"'%s' expected type '%s', got unconvertible type '%s', value: '%v'",
-			name, val.Type(), dataVal.Type(), data)
+		return d.unconvertibleTypeError(name, val, dataVal, data)
 	}
 
 	return nil
@@ -720,6 +1812,9 @@ func (d *Decoder) decodeUint(name string, data interface{}, val reflect.Value) e
 
 func (d *Decoder) decodeBool(name string, data interface{}, val reflect.Value) error {
 	dataVal := reflect.Indirect(reflect.ValueOf(data))
+	if str, ok := d.weakBytes(dataVal); ok {
+		return d.decodeBool(name, str, val)
+	}
 	dataKind := getKind(dataVal)
 
 	switch {
@@ -727,32 +1822,61 @@ func (d *Decoder) decodeBool(name string, data interface{}, val reflect.Value) e
 		val.SetBool(dataVal.Bool())
 	case dataKind == reflect.Int && d.config.WeaklyTypedInput:
 		val.SetBool(dataVal.Int() != 0)
+		d.recordWeak(name, dataVal, val)
 	case dataKind == reflect.Uint && d.config.WeaklyTypedInput:
 		val.SetBool(dataVal.Uint() != 0)
+		d.recordWeak(name, dataVal, val)
 	case dataKind == reflect.Float32 && d.config.WeaklyTypedInput:
 		val.SetBool(dataVal.Float() != 0)
-	case dataKind == reflect.String && d.config.WeaklyTypedInput:
+		d.recordWeak(name, dataVal, val)
+	case dataKind == reflect.String && (d.config.WeaklyTypedInput || d.config.ExtendedBools):
+		if d.config.ExtendedBools {
+			if b, ok := parseExtendedBool(dataVal.String()); ok {
+				val.SetBool(b)
+				break
+			}
+		}
+
+		// Strings that ExtendedBools accepts aren't weak conversions.
 		b, err := strconv.ParseBool(dataVal.String())
 		if err == nil {
 			val.SetBool(b)
-		} else if dataVal.String() == "" {
+			if !d.config.ExtendedBools {
+				d.recordWeak(name, dataVal, val)
+			}
+		} else if dataVal.String() == "" && d.config.WeaklyTypedInput {
 			val.SetBool(false)
+			d.recordWeak(name, dataVal, val)
 		} else {
-			return fmt.Errorf("cannot parse '%s' as bool: %s", name, err)
+			return d.parseError(name, "bool", data, err)
 		}
 	default:
-		return fmt.Errorf(
-			"'%s' expected type '%s', got unconvertible type '%s', value: '%v'",
-			name, val.Type(), dataVal.Type(), data)
+		return d.unconvertibleTypeError(name, val, dataVal, data)
 	}
 
 	return nil
 }
 
+// parseExtendedBool parses the YAML 1.1 style bool literals accepted with
+// ExtendedBools, ignoring case.
+func parseExtendedBool(s string) (bool, bool) {
+	switch strings.ToLower(s) {
+	case "y", "yes", "on", "enable", "enabled":
+		return true, true
+	case "n", "no", "off", "disable", "disabled":
+		return false, true
+	default:
+		return false, false
+	}
+}
+
 func (d *Decoder) decodeFloat(name string, data interface{}, val reflect.Value) error {
 	dataVal := reflect.Indirect(reflect.ValueOf(data))
+	if str, ok := d.weakBytes(dataVal); ok {
+		return d.decodeFloat(name, str, val)
+	}
 	dataKind := getKind(dataVal)
-	dataType := dataVal.Type()
+	number := d.numberType(dataVal.Type())
 
 	switch {
 	case dataKind == reflect.Int:
@@ -767,6 +1891,7 @@ func (d *Decoder) decodeFloat(name string, data interface{}, val reflect.Value)
 		} else {
 			val.SetFloat(0)
 		}
+		d.recordWeak(name, dataVal, val)
 	case dataKind == reflect.String && d.config.WeaklyTypedInput:
 		str := dataVal.String()
 		if str == "" {
@@ -776,27 +1901,38 @@ func (d *Decoder) decodeFloat(name string, data interface{}, val reflect.Value)
 		f, err := strconv.ParseFloat(str, val.Type().Bits())
 		if err == nil {
 			val.SetFloat(f)
+			d.recordWeak(name, dataVal, val)
 		} else {
-			return fmt.Errorf("cannot parse '%s' as float: %s", name, err)
+			return d.parseError(name, "float", data, err)
 		}
-	case dataType.PkgPath() == "encoding/json" && dataType.Name() == "Number":
-		jn := data.(json.Number)
-		i, err := jn.Float64()
+	case number.Float64 != nil:
+		n, err := number.Float64(dataVal.Interface())
 		if err != nil {
-			return fmt.Errorf(
-				"error decoding json.Number into %s: %s", name, err)
+			return d.numberError(name, val, dataVal, data, err)
 		}
-		val.SetFloat(i)
+		val.SetFloat(n)
 	default:
-		return fmt.Errorf(
-			"'%s' expected type '%s', got unconvertible type '%s', value: '%v'",
-			name, val.Type(), dataVal.Type(), data)
+		return d.unconvertibleTypeError(name, val, dataVal, data)
 	}
 
 	return nil
 }
 
 func (d *Decoder) decodeMap(name string, data interface{}, val reflect.Value) error {
+	if m, ok := marshaler(reflect.ValueOf(data)); ok {
+		marshaled, err := d.marshal(name, m)
+		if err != nil {
+			return err
+		}
+		data = marshaled
+	}
+
+	data, err := d.pairsInput(name, data)
+	if err != nil {
+		return err
+	}
+	data = mapInput(data)
+
 	valType := val.Type()
 	valKeyType := valType.Key()
 	valElemType := valType.Elem()
@@ -807,8 +1943,12 @@ func (d *Decoder) decodeMap(name string, data interface{}, val reflect.Value) er
 	// If the map is nil or we're purposely zeroing fields, make a new map
 	if valMap.IsNil() || d.config.ZeroFields {
 		// Make a new map to hold our result
-		mapType := reflect.MapOf(valKeyType, valElemType)
-		valMap = reflect.MakeMap(mapType)
+		if fresh, ok := d.freshValue(valType); ok && !fresh.IsNil() {
+			valMap = fresh
+		} else {
+			mapType := reflect.MapOf(valKeyType, valElemType)
+			valMap = reflect.MakeMap(mapType)
+		}
 	}
 
 	// Check input type and based on the input type jump to the proper func
@@ -818,17 +1958,33 @@ func (d *Decoder) decodeMap(name string, data interface{}, val reflect.Value) er
 		return d.decodeMapFromMap(name, dataVal, val, valMap)
 
 	case reflect.Struct:
+		if isMultiValueMap(valType) {
+			if err := d.decodeMultiValueMapFromStruct(name, dataVal, valMap); err != nil {
+				return err
+			}
+
+			val.Set(valMap)
+			return nil
+		}
+
 		return d.decodeMapFromStruct(name, dataVal, val, valMap)
 
 	case reflect.Array, reflect.Slice:
-		if d.config.WeaklyTypedInput {
+		emptyDisabled := dataVal.Len() == 0 && d.config.DisableWeakEmptyMapSlice
+		if d.config.WeaklyTypedInput && !emptyDisabled {
+			d.recordWeak(name, dataVal, val)
 			return d.decodeMapFromSlice(name, dataVal, val, valMap)
 		}
 
 		fallthrough
 
 	default:
-		return fmt.Errorf("'%s' expected a map, got '%s'", name, dataVal.Kind())
+		return d.decodingError(MsgExpectedMap, &DecodingError{
+			Kind:  DecodingErrorUnconvertibleType,
+			Name:  name,
+			Got:   dataVal.Kind().String(),
+			Value: data,
+		})
 	}
 }
 
@@ -840,11 +1996,15 @@ func (d *Decoder) decodeMapFromSlice(name string, dataVal reflect.Value, val ref
 	}
 
 	for i := 0; i < dataVal.Len(); i++ {
+		if err := d.ctxErr(); err != nil {
+			return err
+		}
+
 		err := d.decode(
 			name+"["+strconv.Itoa(i)+"]",
 			dataVal.Index(i).Interface(), val)
 		if err != nil {
-			return err
+			return withSourceKey(err, strconv.Itoa(i))
 		}
 	}
 
@@ -852,12 +2012,22 @@ func (d *Decoder) decodeMapFromSlice(name string, dataVal reflect.Value, val ref
 }
 
 func (d *Decoder) decodeMapFromMap(name string, dataVal reflect.Value, val reflect.Value, valMap reflect.Value) error {
+	dataVal, err := d.applyIncludes(name, dataVal)
+	if err != nil {
+		return err
+	}
+
 	valType := val.Type()
 	valKeyType := valType.Key()
 	valElemType := valType.Elem()
 
-	// Accumulate errors
-	errors := make([]string, 0)
+	// Accumulate errors. Map iteration order is random, so the errors are
+	// kept together with their key and reported in key order.
+	type keyError struct {
+		key reflect.Value
+		err error
+	}
+	keyErrors := make([]keyError, 0)
 
 	// If the input data is empty, then we just match what the input data is.
 	if dataVal.Len() == 0 {
@@ -873,21 +2043,38 @@ func (d *Decoder) decodeMapFromMap(name string, dataVal reflect.Value, val refle
 		return nil
 	}
 
+	multiValue := isMultiValueMap(dataVal.Type())
 	for _, k := range dataVal.MapKeys() {
+		if err := d.ctxErr(); err != nil {
+			return err
+		}
+
 		fieldName := name + "[" + k.String() + "]"
 
 		// First decode the key into the proper type
 		currentKey := reflect.Indirect(reflect.New(valKeyType))
 		if err := d.decode(fieldName, k.Interface(), currentKey); err != nil {
-			errors = appendErrors(errors, err)
+			keyErrors = append(keyErrors, keyError{k, withSourceKey(err, fmt.Sprint(k))})
 			continue
 		}
 
 		// Next decode the data into the proper type
 		v := dataVal.MapIndex(k).Interface()
+		if multiValue {
+			v = d.multiValueInput(v, valElemType)
+		}
 		currentVal := reflect.Indirect(reflect.New(valElemType))
+		keep := valElemType.Kind() == reflect.Ptr ||
+			valElemType.Kind() == reflect.Interface && d.config.KeepInterfaceValues
+		if keep && !d.config.ZeroFields {
+			// Decode into the existing pointee or concrete value, keeping
+			// its identity and the values that the input doesn't set.
+			if existing := valMap.MapIndex(currentKey); existing.IsValid() && !existing.IsNil() {
+				currentVal.Set(existing)
+			}
+		}
 		if err := d.decode(fieldName, v, currentVal); err != nil {
-			errors = appendErrors(errors, err)
+			keyErrors = append(keyErrors, keyError{k, withSourceKey(err, fmt.Sprint(k))})
 			continue
 		}
 
@@ -898,192 +2085,772 @@ func (d *Decoder) decodeMapFromMap(name string, dataVal reflect.Value, val refle
 	val.Set(valMap)
 
 	// If we had errors, return those
-	if len(errors) > 0 {
-		return &Error{errors}
+	if len(keyErrors) > 0 {
+		sort.SliceStable(keyErrors, func(i, j int) bool {
+			return lessKey(keyErrors[i].key, keyErrors[j].key)
+		})
+
+		errors := make([]error, 0, len(keyErrors))
+		for _, ke := range keyErrors {
+			errors = appendErrors(errors, ke.err)
+		}
+
+		return newError(errors)
 	}
 
 	return nil
 }
 
 func (d *Decoder) decodeMapFromStruct(name string, dataVal reflect.Value, val reflect.Value, valMap reflect.Value) error {
+	elemType := valMap.Type().Elem()
+	markSecrets := d.markSecrets && elemType.Kind() == reflect.Interface && elemType.NumMethod() == 0
+	remains, err := d.walkStructFields(name, dataVal, func(f encodedField) error {
+		if err := d.encodeField(name, f, valMap); err != nil {
+			return err
+		}
+
+		if markSecrets && !f.squash && hasTagOption(f.tag, "secret") {
+			key := reflect.ValueOf(f.key)
+			if v := valMap.MapIndex(key); v.IsValid() {
+				valMap.SetMapIndex(key, reflect.ValueOf(secretValue{v.Interface()}))
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, remain := range remains {
+		if err := d.spliceRemain(name, remain.m, remain.prefix, valMap); err != nil {
+			return err
+		}
+	}
+
+	if val.CanAddr() {
+		val.Set(valMap)
+	}
+
+	return nil
+}
+
+// encodeField sets the entry of the field f of the struct at name in
+// valMap, which the struct is encoded into.
+func (d *Decoder) encodeField(name string, f encodedField, valMap reflect.Value) error {
+	elemType := valMap.Type().Elem()
+	if f.squash {
+		return d.squashMarshaledMap(name, f.value, valMap)
+	}
+
+	v := f.value
+	if m, ok := marshaler(v); ok {
+		marshaled, err := d.marshal(f.name, m)
+		if err != nil {
+			return err
+		}
+
+		mv := reflect.ValueOf(marshaled)
+		if !mv.IsValid() {
+			mv = reflect.Zero(elemType)
+		}
+		if !mv.Type().AssignableTo(elemType) {
+			return d.decodingError(MsgUnassignableField, &DecodingError{
+				Kind:     DecodingErrorUnconvertibleType,
+				Name:     name,
+				Expected: elemType.String(),
+				Got:      mv.Type().String(),
+			})
+		}
+		valMap.SetMapIndex(reflect.ValueOf(f.key), mv)
+		return nil
+	}
+
+	if str, ok := d.stringerValue(v); ok && stringType.AssignableTo(elemType) {
+		valMap.SetMapIndex(reflect.ValueOf(f.key), reflect.ValueOf(str))
+		return nil
+	}
+
+	if strs, ok := d.stringsValue(v); ok && strs.Type().AssignableTo(elemType) {
+		valMap.SetMapIndex(reflect.ValueOf(f.key), strs)
+		return nil
+	}
+
+	if d.encodeAsString(v, f.tag) && stringType.AssignableTo(elemType) {
+		str, _ := formatScalar(reflect.Indirect(v))
+		valMap.SetMapIndex(reflect.ValueOf(f.key), reflect.ValueOf(str))
+		return nil
+	}
+
+	if !v.Type().AssignableTo(elemType) {
+		return d.decodingError(MsgUnassignableField, &DecodingError{
+			Kind:     DecodingErrorUnconvertibleType,
+			Name:     name,
+			Expected: elemType.String(),
+			Got:      v.Type().String(),
+		})
+	}
+
+	v = dereferencePtrToStructIfNeeded(v, d.config.TagName)
+	if v.Kind() != reflect.Struct {
+		valMap.SetMapIndex(reflect.ValueOf(f.key), v)
+		return nil
+	}
+
+	// Nested structs are encoded into a map of the same type. The
+	// struct is copied so that the methods of its pointer are found,
+	// and the map is decoded into through a pointer so that it can be
+	// replaced (looking at you decodeMapFromMap).
+	x := reflect.New(v.Type())
+	x.Elem().Set(v)
+
+	nested := reflect.New(valMap.Type())
+	nested.Elem().Set(reflect.MakeMap(valMap.Type()))
+	if err := d.decode(f.name, x.Interface(), nested.Elem()); err != nil {
+		return withSourceKey(err, f.field.Name)
+	}
+
+	valMap.SetMapIndex(reflect.ValueOf(f.key), nested.Elem())
+	return nil
+}
+
+// encodedField is a field of a struct that is encoded into a map, as
+// passed to the visit function of walkStructFields.
+type encodedField struct {
+	field reflect.StructField
+	tag   string
+	value reflect.Value
+
+	// key is the map key of the field and name its full name, which is
+	// the key joined to the name of the struct.
+	key  string
+	name string
+
+	// squash is set for a squashed embedded struct that is a Marshaler,
+	// which is encoded into a map whose entries are added to the map of
+	// the struct. key and name are empty.
+	squash bool
+}
+
+// walkStructFields calls visit for every field of the struct dataVal that
+// is encoded into a map, in the order they are declared, which is shared
+// by the encoders into maps, multi-value maps and ordered maps. Unexported
+// and skipped fields and empty omitempty fields are left out, and
+// squashed embedded structs are walked in place of their field, through
+// pointers and interfaces, unless they are Marshalers.
+//
+// The "remain" and squashed map fields are returned rather than visited,
+// so that their entries can be added once all the other fields are
+// encoded and never replace them.
+func (d *Decoder) walkStructFields(name string, dataVal reflect.Value, visit func(encodedField) error) ([]splicedMap, error) {
 	typ := dataVal.Type()
+	ignoreUntagged := d.ignoreUntagged(typ)
+
+	var remains []splicedMap
 	for i := 0; i < typ.NumField(); i++ {
-		// Get the StructField first since this is a cheap operation. If the
-		// field is unexported, then ignore it.
 		f := typ.Field(i)
-		if f.PkgPath != "" {
+		if f.PkgPath != "" || f.Type == optionsType {
 			continue
 		}
 
-		// Next get the actual value of this field and verify it is assignable
-		// to the map value.
 		v := dataVal.Field(i)
-		if !v.Type().AssignableTo(valMap.Type().Elem()) {
-			return fmt.Errorf("cannot assign type '%s' to map value field of type '%s'", v.Type(), valMap.Type().Elem())
+		if remain, ok := d.splicedMap(f, v); ok {
+			remains = append(remains, remain)
+			continue
 		}
 
-		tagValue := f.Tag.Get(d.config.TagName)
-		keyName := f.Name
+		tag := d.fieldTag(f)
+		if tag == "" && ignoreUntagged {
+			continue
+		}
 
-		if tagValue == "" && d.config.IgnoreUntaggedFields {
+		tagParts := strings.Split(tag, ",")
+		if tagParts[0] == "-" {
 			continue
 		}
 
-		// If Squash is set in the config, we squash the field down.
-		squash := d.config.Squash && v.Kind() == reflect.Struct && f.Anonymous
+		// An embedded interface is squashed through the struct it holds.
+		if f.Anonymous && v.Kind() == reflect.Interface && (d.config.Squash || hasTagOption(tag, "squash")) {
+			if held, ok := interfaceStruct(v); ok {
+				v = held
+			}
+		}
 
-		v = dereferencePtrToStructIfNeeded(v, d.config.TagName)
+		if hasTagOption(tag, "omitempty") && isEmptyValue(v) {
+			continue
+		}
 
-		// Determine the name of the key in the map
-		if index := strings.Index(tagValue, ","); index != -1 {
-			if tagValue[:index] == "-" {
-				continue
-			}
-			// If "omitempty" is specified in the tag, it ignores empty values.
-			if strings.Index(tagValue[index+1:], "omitempty") != -1 && isEmptyValue(v) {
+		squash := d.config.Squash && f.Anonymous && reflect.Indirect(v).Kind() == reflect.Struct
+		if squash || hasTagOption(tag, "squash") {
+			if v.Kind() == reflect.Ptr && v.IsNil() && v.Type().Elem().Kind() == reflect.Struct {
+				// A nil embedded struct has no fields to encode.
 				continue
 			}
 
-			// If "squash" is specified in the tag, we squash the field down.
-			squash = squash || strings.Index(tagValue[index+1:], "squash") != -1
-			if squash {
-				// When squashing, the embedded type can be a pointer to a struct.
-				if v.Kind() == reflect.Ptr && v.Elem().Kind() == reflect.Struct {
-					v = v.Elem()
-				}
+			v = reflect.Indirect(v)
+			if v.Kind() != reflect.Struct {
+				return nil, d.decodingError(MsgSquashNonStruct, &DecodingError{
+					Kind: DecodingErrorInvalidSquash,
+					Name: name,
+					Got:  v.Type().String(),
+				})
+			}
 
-				// The final type must be a struct
-				if v.Kind() != reflect.Struct {
-					return fmt.Errorf("cannot squash non-struct type '%s'", v.Type())
+			if _, ok := marshaler(v); ok {
+				if err := visit(encodedField{field: f, tag: tag, value: v, squash: true}); err != nil {
+					return nil, err
 				}
-			}
-			if keyNameTagValue := tagValue[:index]; keyNameTagValue != "" {
-				keyName = keyNameTagValue
-			}
-		} else if len(tagValue) > 0 {
-			if tagValue == "-" {
 				continue
 			}
-			keyName = tagValue
-		}
 
-		switch v.Kind() {
-		// this is an embedded struct, so handle it differently
-		case reflect.Struct:
-			x := reflect.New(v.Type())
-			x.Elem().Set(v)
-
-			vType := valMap.Type()
-			vKeyType := vType.Key()
-			vElemType := vType.Elem()
-			mType := reflect.MapOf(vKeyType, vElemType)
-			vMap := reflect.MakeMap(mType)
-
-			// Creating a pointer to a map so that other methods can completely
-			// overwrite the map if need be (looking at you decodeMapFromMap). The
-			// indirection allows the underlying map to be settable (CanSet() == true)
-			// where as reflect.MakeMap returns an unsettable map.
-			addrVal := reflect.New(vMap.Type())
-			reflect.Indirect(addrVal).Set(vMap)
-
-			err := d.decode(keyName, x.Interface(), reflect.Indirect(addrVal))
+			nested, err := d.walkStructFields(name, v, visit)
 			if err != nil {
-				return err
+				return nil, err
 			}
+			remains = append(remains, nested...)
+			continue
+		}
 
-			// the underlying map may have been completely overwritten so pull
-			// it indirectly out of the enclosing value.
-			vMap = reflect.Indirect(addrVal)
-
-			if squash {
-				for _, k := range vMap.MapKeys() {
-					valMap.SetMapIndex(k, vMap.MapIndex(k))
-				}
-			} else {
-				valMap.SetMapIndex(reflect.ValueOf(keyName), vMap)
-			}
+		key := d.encodedKeyName(f.Name, tagParts[0])
+		fieldName := key
+		if name != "" {
+			fieldName = name + "." + key
+		}
 
-		default:
-			valMap.SetMapIndex(reflect.ValueOf(keyName), v)
+		if err := visit(encodedField{field: f, tag: tag, value: v, key: key, name: fieldName}); err != nil {
+			return nil, err
 		}
 	}
 
-	if val.CanAddr() {
-		val.Set(valMap)
+	return remains, nil
+}
+
+// squashMarshaledMap adds the entries of the map that the squashed
+// Marshaler v is encoded into to valMap, converted like the entries of
+// any map decoded into it.
+func (d *Decoder) squashMarshaledMap(name string, v reflect.Value, valMap reflect.Value) error {
+	m, _ := marshaler(v)
+	marshaled, err := d.marshal(name, m)
+	if err != nil {
+		return err
+	}
+
+	nested := reflect.New(valMap.Type())
+	nested.Elem().Set(reflect.MakeMap(valMap.Type()))
+	if err := d.decode(name, marshaled, nested.Elem()); err != nil {
+		return err
+	}
+
+	iter := nested.Elem().MapRange()
+	for iter.Next() {
+		valMap.SetMapIndex(iter.Key(), iter.Value())
 	}
 
 	return nil
 }
 
-func (d *Decoder) decodePtr(name string, data interface{}, val reflect.Value) (bool, error) {
-	// If the input data is nil, then we want to just set the output
-	// pointer to be nil as well.
-	isNil := data == nil
-	if !isNil {
-		switch v := reflect.Indirect(reflect.ValueOf(data)); v.Kind() {
-		case reflect.Chan,
-			reflect.Func,
-			reflect.Interface,
-			reflect.Map,
-			reflect.Ptr,
-			reflect.Slice:
-			isNil = v.IsNil()
+// hasTagOption reports whether the given tag has the option opt, such as
+// "remain" or "squash".
+func hasTagOption(tag, opt string) bool {
+	for _, tagOpt := range strings.Split(tag, ",")[1:] {
+		if tagOpt == opt {
+			return true
 		}
 	}
-	if isNil {
-		if !val.IsNil() && val.CanSet() {
-			nilValue := reflect.New(val.Type()).Elem()
-			val.Set(nilValue)
+
+	return false
+}
+
+// tagOptionValue returns the value of the option of the form opt=value
+// in the tag, such as "prefix=label_".
+func tagOptionValue(tag, opt string) (string, bool) {
+	for _, tagOpt := range strings.Split(tag, ",")[1:] {
+		if value := strings.TrimPrefix(tagOpt, opt+"="); value != tagOpt {
+			return value, true
 		}
+	}
 
-		return true, nil
+	return "", false
+}
+
+// splicedMap is a "remain" or squashed map field whose entries are added
+// to the map its struct is encoded into, with prefix added to their keys.
+type splicedMap struct {
+	m      reflect.Value
+	prefix string
+}
+
+// splicedMap returns the map of the field f, whose value is v, if it is
+// a "remain" or squashed map field.
+func (d *Decoder) splicedMap(f reflect.StructField, v reflect.Value) (splicedMap, bool) {
+	v = reflect.Indirect(v)
+	tag := d.fieldTag(f)
+	if v.Kind() != reflect.Map || !hasTagOption(tag, "remain") && !hasTagOption(tag, "squash") {
+		return splicedMap{}, false
 	}
 
-	// Create an element of the concrete (non pointer) type and decode
-	// into that. Then set the value of the pointer to this type.
-	valType := val.Type()
-	valElemType := valType.Elem()
-	if val.CanSet() {
-		realVal := val
-		if realVal.IsNil() || d.config.ZeroFields {
-			realVal = reflect.New(valElemType)
+	prefix, _ := tagOptionValue(tag, "prefix")
+	return splicedMap{m: v, prefix: prefix}, true
+}
+
+// spliceRemain adds the entries of the "remain" or squashed map remain to
+// valMap, with prefix added to their keys, except those whose keys are
+// already set or can't be keys of valMap.
+func (d *Decoder) spliceRemain(name string, remain reflect.Value, prefix string, valMap reflect.Value) error {
+	keyType, elemType := valMap.Type().Key(), valMap.Type().Elem()
+	iter := remain.MapRange()
+	for iter.Next() {
+		k, v := iter.Key(), iter.Value()
+		if k.Kind() == reflect.Interface {
+			k = k.Elem()
+		}
+		if prefix != "" {
+			if !k.IsValid() || k.Kind() != reflect.String {
+				continue
+			}
+			k = reflect.ValueOf(prefix + k.String())
+		}
+		switch {
+		case !k.IsValid():
+			continue
+		case k.Type().AssignableTo(keyType):
+		case k.Kind() == reflect.String && keyType.Kind() == reflect.String:
+			k = k.Convert(keyType)
+		default:
+			continue
+		}
+		if valMap.MapIndex(k).IsValid() {
+			continue
 		}
 
-		if err := d.decode(name, data, reflect.Indirect(realVal)); err != nil {
-			return false, err
+		if !v.Type().AssignableTo(elemType) {
+			return d.decodingError(MsgUnassignableField, &DecodingError{
+				Kind:     DecodingErrorUnconvertibleType,
+				Name:     name,
+				Expected: elemType.String(),
+				Got:      v.Type().String(),
+			})
 		}
+		valMap.SetMapIndex(k, v)
+	}
 
-		val.Set(realVal)
-	} else {
-		if err := d.decode(name, data, reflect.Indirect(val)); err != nil {
-			return false, err
+	return nil
+}
+
+// fieldTag returns the tag of the field f. If the field has no TagName tag
+// and JSONTags is set, its json tag is returned instead, with only the
+// options that mean the same for both, and untagged embedded structs are
+// squashed like encoding/json does.
+func (d *Decoder) fieldTag(f reflect.StructField) string {
+	tag := f.Tag.Get(d.config.TagName)
+	if tag != "" || !d.config.JSONTags {
+		return tag
+	}
+
+	jsonTag, ok := f.Tag.Lookup("json")
+	if !ok {
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			return ",squash"
 		}
+		return ""
 	}
-	return false, nil
+
+	parts := strings.Split(jsonTag, ",")
+	if parts[0] == "-" && len(parts) == 1 {
+		return "-"
+	}
+
+	tagParts := parts[:1]
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" || opt == "string" {
+			tagParts = append(tagParts, opt)
+		}
+	}
+
+	return strings.Join(tagParts, ",")
 }
 
-func (d *Decoder) decodeFunc(name string, data interface{}, val reflect.Value) error {
-	// Create an element of the concrete (non pointer) type and decode
-	// into that. Then set the value of the pointer to this type.
-	dataVal := reflect.Indirect(reflect.ValueOf(data))
-	if val.Type() != dataVal.Type() {
-		return fmt.Errorf(
-			"'%s' expected type '%s', got unconvertible type '%s', value: '%v'",
-			name, val.Type(), dataVal.Type(), data)
+// encodedKeyName returns the map key that the field with the given name
+// and tag name is encoded under.
+func (d *Decoder) encodedKeyName(fieldName, tagName string) string {
+	if d.config.EncodeKeyTransform != nil {
+		return d.config.EncodeKeyTransform(fieldName, tagName)
 	}
-	val.Set(dataVal)
-	return nil
+
+	return defaultKeyName(fieldName, tagName)
 }
 
-func (d *Decoder) decodeSlice(name string, data interface{}, val reflect.Value) error {
-	dataVal := reflect.Indirect(reflect.ValueOf(data))
-	dataValKind := dataVal.Kind()
-	valType := val.Type()
-	valElemType := valType.Elem()
-	sliceType := reflect.SliceOf(valElemType)
+var stringType = reflect.TypeOf("")
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+var timeType = reflect.TypeOf(time.Time{})
+
+var stringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+
+// stringerValue returns the result of the String method of v if
+// EncodeStringers is set and v, or a pointer to it, is a fmt.Stringer.
+// Nil pointers are never encoded as strings.
+func (d *Decoder) stringerValue(v reflect.Value) (string, bool) {
+	if d.config.EncodeAsStrings {
+		if str, ok := d.scalarString(v); ok {
+			return str, true
+		}
+	}
+
+	if !d.config.EncodeStringers || !v.IsValid() {
+		return "", false
+	}
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return "", false
+	}
+
+	if v.Type().Implements(stringerType) {
+		return v.Interface().(fmt.Stringer).String(), true
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(stringerType) {
+		return v.Addr().Interface().(fmt.Stringer).String(), true
+	}
+
+	return "", false
+}
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// isScalarType reports whether EncodeAsStrings encodes values of type typ
+// as strings: they have a MarshalText method, or are neither structs,
+// maps, slices nor arrays, except for []byte.
+func isScalarType(typ reflect.Type) bool {
+	if typ.Implements(textMarshalerType) || reflect.PtrTo(typ).Implements(textMarshalerType) {
+		return true
+	}
+
+	switch typ.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Array, reflect.Interface, reflect.Func, reflect.Chan:
+		return false
+	case reflect.Slice:
+		return typ.Elem().Kind() == reflect.Uint8
+	case reflect.Ptr:
+		return isScalarType(typ.Elem())
+	default:
+		return true
+	}
+}
+
+// scalarString formats the scalar v for EncodeAsStrings: with its
+// MarshalText or String method if it has one, and with the rules of
+// WeaklyTypedInput otherwise. Nil pointers are formatted as "".
+func (d *Decoder) scalarString(v reflect.Value) (string, bool) {
+	if !v.IsValid() {
+		return "", false
+	}
+	if v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	if !isScalarType(v.Type()) {
+		return "", false
+	}
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return "", true
+	}
+
+	v = reflect.Indirect(v)
+	candidates := []reflect.Value{v}
+	if v.CanAddr() {
+		candidates = append(candidates, v.Addr())
+	}
+	for _, c := range candidates {
+		if m, ok := c.Interface().(encoding.TextMarshaler); ok {
+			text, err := m.MarshalText()
+			return string(text), err == nil
+		}
+	}
+	for _, c := range candidates {
+		if s, ok := c.Interface().(fmt.Stringer); ok {
+			return s.String(), true
+		}
+	}
+
+	var str string
+	weak := d.withConfig(func(c *DecoderConfig) {
+		c.WeaklyTypedInput = true
+		c.Metadata = nil
+	})
+	if err := weak.decodeString("", v.Interface(), reflect.ValueOf(&str).Elem()); err != nil {
+		return "", false
+	}
+
+	return str, true
+}
+
+// stringsValue returns the slice or array of scalars v as a []string for
+// EncodeAsStrings.
+func (d *Decoder) stringsValue(v reflect.Value) (reflect.Value, bool) {
+	if !d.config.EncodeAsStrings {
+		return reflect.Value{}, false
+	}
+
+	v = reflect.Indirect(v)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array || !isScalarType(v.Type().Elem()) {
+		return reflect.Value{}, false
+	}
+	if v.Kind() == reflect.Slice && v.IsNil() {
+		return reflect.Value{}, false
+	}
+
+	strs := make([]string, v.Len())
+	for i := range strs {
+		str, ok := d.stringerValue(v.Index(i))
+		if !ok {
+			return reflect.Value{}, false
+		}
+		strs[i] = str
+	}
+
+	return reflect.ValueOf(strs), true
+}
+
+// encodeAsString reports whether the field v with the given tag is
+// encoded as a string: either the tag has the "string" option and v is a
+// number or bool, or v is an int64 or uint64 and Int64AsString is set.
+// Pointers to such values are encoded the same way unless they are nil.
+func (d *Decoder) encodeAsString(v reflect.Value, tagValue string) bool {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return false
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Int64, reflect.Uint64:
+		// Named types, such as time.Duration, aren't plain numbers.
+		if d.config.Int64AsString && v.Type().PkgPath() == "" {
+			return true
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Float32, reflect.Float64, reflect.Bool:
+	default:
+		return false
+	}
+
+	for _, opt := range strings.Split(tagValue, ",")[1:] {
+		if opt == "string" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// transformKeys returns a copy of the map dataVal with transform applied
+// to its string keys. Keys are transformed in sorted order, so if two keys
+// transform to the same key, the value of the greater one is kept.
+func transformKeys(dataVal reflect.Value, transform func(string) string) reflect.Value {
+	keys := dataVal.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+	})
+
+	keyType := dataVal.Type().Key()
+	result := reflect.MakeMapWithSize(dataVal.Type(), len(keys))
+	for _, k := range keys {
+		newKey := k
+		if mK, ok := k.Interface().(string); ok {
+			newKey = reflect.ValueOf(transform(mK)).Convert(keyType)
+		} else if k.Kind() == reflect.String {
+			newKey = reflect.ValueOf(transform(k.String())).Convert(keyType)
+		}
+
+		result.SetMapIndex(newKey, dataVal.MapIndex(k))
+	}
+
+	return result
+}
+
+// decodeMultiValueMapFromStruct encodes a struct into a map of string
+// slices such as url.Values. Scalar fields become single values and
+// slices and arrays become one value per element.
+func (d *Decoder) decodeMultiValueMapFromStruct(name string, dataVal reflect.Value, valMap reflect.Value) error {
+	keyType := valMap.Type().Key()
+	remains, err := d.walkStructFields(name, dataVal, func(f encodedField) error {
+		if f.squash {
+			m, _ := marshaler(f.value)
+			marshaled, err := d.marshal(name, m)
+			if err != nil {
+				return err
+			}
+
+			mv := reflect.Indirect(reflect.ValueOf(mapInput(marshaled)))
+			if mv.Kind() != reflect.Map {
+				return d.decodingError(MsgSquashNonStruct, &DecodingError{
+					Kind: DecodingErrorInvalidSquash,
+					Name: name,
+					Got:  fmt.Sprintf("%T", marshaled),
+				})
+			}
+
+			return d.setMultiValues(name, mv, "", keyType, valMap, true)
+		}
+
+		v := f.value
+		if m, ok := marshaler(v); ok {
+			marshaled, err := d.marshal(f.name, m)
+			if err != nil {
+				return err
+			}
+			v = reflect.ValueOf(marshaled)
+		} else if str, ok := d.stringerValue(v); ok {
+			v = reflect.ValueOf(str)
+		} else if strs, ok := d.stringsValue(v); ok {
+			v = strs
+		} else if d.encodeAsString(v, f.tag) {
+			str, _ := formatScalar(reflect.Indirect(v))
+			v = reflect.ValueOf(str)
+		}
+
+		return d.setMultiValue(f.name, reflect.ValueOf(f.key).Convert(keyType), v, valMap)
+	})
+	if err != nil {
+		return err
+	}
+
+	// The entries of the "remain" and squashed maps never replace those
+	// of the other fields.
+	for _, remain := range remains {
+		if err := d.setMultiValues(name, remain.m, remain.prefix, keyType, valMap, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setMultiValues sets the entries of the multi-value map valMap to the
+// values of the entries of m with string keys, with prefix added to the
+// keys. Existing entries are only replaced if replace is set.
+func (d *Decoder) setMultiValues(name string, m reflect.Value, prefix string, keyType reflect.Type, valMap reflect.Value, replace bool) error {
+	iter := m.MapRange()
+	for iter.Next() {
+		k := iter.Key()
+		if k.Kind() == reflect.Interface {
+			k = k.Elem()
+		}
+		if !k.IsValid() || k.Kind() != reflect.String {
+			continue
+		}
+
+		key := reflect.ValueOf(prefix + k.String()).Convert(keyType)
+		if !replace && valMap.MapIndex(key).IsValid() {
+			continue
+		}
+
+		fieldName := key.String()
+		if name != "" {
+			fieldName = name + "." + fieldName
+		}
+		if err := d.setMultiValue(fieldName, key, iter.Value(), valMap); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setMultiValue sets the entry key of the multi-value map valMap to the
+// values that v is formatted into. Nothing is set for nil values.
+func (d *Decoder) setMultiValue(name string, key, v, valMap reflect.Value) error {
+	if !v.IsValid() {
+		return nil
+	}
+
+	values, ok := formatMultiValue(v)
+	if !ok {
+		return d.unconvertibleTypeError(name, reflect.Zero(multiValueType), v, v.Interface())
+	}
+
+	if values != nil {
+		valMap.SetMapIndex(key, reflect.ValueOf(values))
+	}
+
+	return nil
+}
+
+func (d *Decoder) decodePtr(name string, data interface{}, val reflect.Value) (bool, error) {
+	// If the input data is nil, then we want to just set the output
+	// pointer to be nil as well.
+	isNil := data == nil
+	if !isNil {
+		switch v := reflect.Indirect(reflect.ValueOf(data)); v.Kind() {
+		case reflect.Chan,
+			reflect.Func,
+			reflect.Interface,
+			reflect.Map,
+			reflect.Ptr,
+			reflect.Slice:
+			isNil = v.IsNil()
+		}
+	}
+	if isNil {
+		if !val.IsNil() && val.CanSet() {
+			nilValue := reflect.New(val.Type()).Elem()
+			val.Set(nilValue)
+		}
+
+		return true, nil
+	}
+
+	// Create an element of the concrete (non pointer) type and decode
+	// into that. Then set the value of the pointer to this type.
+	valType := val.Type()
+	valElemType := valType.Elem()
+	if val.CanSet() {
+		realVal := val
+		if realVal.IsNil() || d.config.ZeroFields {
+			realVal = reflect.New(valElemType)
+			if fresh, ok := d.freshValue(valElemType); ok {
+				realVal.Elem().Set(fresh)
+			}
+		}
+
+		if err := d.decode(name, data, reflect.Indirect(realVal)); err != nil {
+			return false, err
+		}
+
+		val.Set(realVal)
+	} else {
+		if err := d.decode(name, data, reflect.Indirect(val)); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+func (d *Decoder) decodeFunc(name string, data interface{}, val reflect.Value) error {
+	// Create an element of the concrete (non pointer) type and decode
+	// into that. Then set the value of the pointer to this type.
+	dataVal := reflect.Indirect(reflect.ValueOf(data))
+	if val.Type() != dataVal.Type() {
+		return d.unconvertibleTypeError(name, val, dataVal, data)
+	}
+	val.Set(dataVal)
+	return nil
+}
+
+func (d *Decoder) decodeSlice(name string, data interface{}, val reflect.Value) error {
+	dataVal := reflect.Indirect(reflect.ValueOf(data))
+	dataValKind := dataVal.Kind()
+	valType := val.Type()
+	valElemType := valType.Elem()
+	sliceType := reflect.SliceOf(valElemType)
 
 	// If we have a non array/slice type then we first attempt to convert.
 	if dataValKind != reflect.Array && dataValKind != reflect.Slice {
+		if d.config.StringBytes && dataValKind == reflect.String && valElemType.Kind() == reflect.Uint8 {
+			return d.decodeSlice(name, []byte(dataVal.String()), val)
+		}
+
 		if d.config.WeaklyTypedInput {
 			switch {
 			// Slice and array we use the normal logic
@@ -1093,25 +2860,46 @@ func (d *Decoder) decodeSlice(name string, data interface{}, val reflect.Value)
 			// Empty maps turn into empty slices
 			case dataValKind == reflect.Map:
 				if dataVal.Len() == 0 {
+					if d.config.DisableWeakEmptyMapSlice {
+						break
+					}
+
 					val.Set(reflect.MakeSlice(sliceType, 0, 0))
+					d.recordWeak(name, dataVal, val)
 					return nil
 				}
 				// Create slice of maps of other sizes
+				d.recordWeak(name, dataVal, val)
 				return d.decodeSlice(name, []interface{}{data}, val)
 
 			case dataValKind == reflect.String && valElemType.Kind() == reflect.Uint8:
+				d.recordWeak(name, dataVal, val)
 				return d.decodeSlice(name, []byte(dataVal.String()), val)
 
+			// Separated numbers, such as "1,2,3", are split into elements.
+			case dataValKind == reflect.String && isNumberKind(getKind(reflect.Zero(valElemType))):
+				d.recordWeak(name, dataVal, val)
+				return d.decodeSlice(name, d.weakSplit(dataVal.String()), val)
+
 			// All other types we try to convert to the slice type
 			// and "lift" it into it. i.e. a string becomes a string slice.
 			default:
 				// Just re-try this function with data as a slice.
+				d.recordWeak(name, dataVal, val)
 				return d.decodeSlice(name, []interface{}{data}, val)
 			}
 		}
 
-		return fmt.Errorf(
-			"'%s': source data must be an array or slice, got %s", name, dataValKind)
+		if d.config.SingleValueToSlice && dataVal.IsValid() {
+			return d.decodeSlice(name, []interface{}{data}, val)
+		}
+
+		return d.decodingError(MsgExpectedSlice, &DecodingError{
+			Kind:  DecodingErrorUnconvertibleType,
+			Name:  name,
+			Got:   dataValKind.String(),
+			Value: data,
+		})
 	}
 
 	// If the input value is nil, then don't allocate since empty != nil
@@ -1119,8 +2907,21 @@ func (d *Decoder) decodeSlice(name string, data interface{}, val reflect.Value)
 		return nil
 	}
 
+	mergeKey := d.mergeKey
+	if mergeKey == "" {
+		mergeKey = d.config.SliceMergeKey
+	}
+	if mergeKey != "" && val.Len() > 0 && !d.config.ZeroFields {
+		if keyField, ok := d.mergeKeyField(valElemType, mergeKey); ok {
+			return d.mergeSlice(name, dataVal, val, keyField, mergeKey)
+		}
+	}
+
 	valSlice := val
-	if valSlice.IsNil() || d.config.ZeroFields {
+	if fresh, ok := d.freshValue(valType); ok && !fresh.IsNil() && (valSlice.IsNil() || d.config.ZeroFields) {
+		// Start from the provided slice, keeping its capacity.
+		valSlice = fresh.Slice(0, 0)
+	} else if valSlice.IsNil() || d.config.ZeroFields {
 		// Make a new slice to hold our result, same size as the original data.
 		valSlice = reflect.MakeSlice(sliceType, dataVal.Len(), dataVal.Len())
 	} else if valSlice.Len() > dataVal.Len() {
@@ -1128,9 +2929,14 @@ func (d *Decoder) decodeSlice(name string, data interface{}, val reflect.Value)
 	}
 
 	// Accumulate any errors
-	errors := make([]string, 0)
+	errors := make([]error, 0)
 
+	elemDecoder := d.nested()
 	for i := 0; i < dataVal.Len(); i++ {
+		if err := d.ctxErr(); err != nil {
+			return err
+		}
+
 		currentData := dataVal.Index(i).Interface()
 		for valSlice.Len() <= i {
 			valSlice = reflect.Append(valSlice, reflect.Zero(valElemType))
@@ -1138,8 +2944,8 @@ func (d *Decoder) decodeSlice(name string, data interface{}, val reflect.Value)
 		currentField := valSlice.Index(i)
 
 		fieldName := name + "[" + strconv.Itoa(i) + "]"
-		if err := d.decode(fieldName, currentData, currentField); err != nil {
-			errors = appendErrors(errors, err)
+		if err := elemDecoder.decode(fieldName, currentData, currentField); err != nil {
+			errors = appendErrors(errors, withSourceKey(err, strconv.Itoa(i)))
 		}
 	}
 
@@ -1148,7 +2954,233 @@ func (d *Decoder) decodeSlice(name string, data interface{}, val reflect.Value)
 
 	// If there were errors, we return those
 	if len(errors) > 0 {
-		return &Error{errors}
+		return newError(errors)
+	}
+
+	return nil
+}
+
+// lessKey orders the keys of a map: numbers by value, and other keys by
+// their string form with runs of digits compared by value, so that 2
+// comes before 10 and "item2" before "item10".
+func lessKey(a, b reflect.Value) bool {
+	if a.Kind() == reflect.Interface && !a.IsNil() {
+		a = a.Elem()
+	}
+	if b.Kind() == reflect.Interface && !b.IsNil() {
+		b = b.Elem()
+	}
+
+	switch aKind, bKind := getKind(a), getKind(b); {
+	case aKind == reflect.Int && bKind == reflect.Int:
+		return a.Int() < b.Int()
+	case aKind == reflect.Uint && bKind == reflect.Uint:
+		return a.Uint() < b.Uint()
+	case isNumberKind(aKind) && isNumberKind(bKind):
+		return floatValue(a) < floatValue(b)
+	}
+
+	return naturalLess(fmt.Sprint(a.Interface()), fmt.Sprint(b.Interface()))
+}
+
+// floatValue returns the number v as a float64.
+func floatValue(v reflect.Value) float64 {
+	switch getKind(v) {
+	case reflect.Int:
+		return float64(v.Int())
+	case reflect.Uint:
+		return float64(v.Uint())
+	default:
+		return v.Float()
+	}
+}
+
+// naturalLess reports whether a sorts before b, comparing runs of digits
+// by their value. Strings whose runs of digits differ only in leading
+// zeros, such as "a01" and "a1", are ordered as plain strings.
+func naturalLess(a, b string) bool {
+	if c := naturalCompare(a, b); c != 0 {
+		return c < 0
+	}
+
+	return a < b
+}
+
+// naturalCompare compares a and b like strings.Compare, except that runs of
+// digits are compared by their value.
+func naturalCompare(a, b string) int {
+	for a != "" && b != "" {
+		aDigits, bDigits := digitPrefix(a), digitPrefix(b)
+		if aDigits > 0 && bDigits > 0 {
+			aNum, bNum := strings.TrimLeft(a[:aDigits], "0"), strings.TrimLeft(b[:bDigits], "0")
+			if len(aNum) != len(bNum) {
+				return len(aNum) - len(bNum)
+			}
+			if c := strings.Compare(aNum, bNum); c != 0 {
+				return c
+			}
+			a, b = a[aDigits:], b[bDigits:]
+			continue
+		}
+
+		if a[0] != b[0] {
+			return int(a[0]) - int(b[0])
+		}
+		a, b = a[1:], b[1:]
+	}
+
+	return len(a) - len(b)
+}
+
+// digitPrefix returns the number of ASCII digits that s starts with.
+func digitPrefix(s string) int {
+	i := 0
+	for i < len(s) && '0' <= s[i] && s[i] <= '9' {
+		i++
+	}
+
+	return i
+}
+
+// isNumberKind reports whether kind, as returned by getKind, is a number.
+func isNumberKind(kind reflect.Kind) bool {
+	return kind == reflect.Int || kind == reflect.Uint || kind == reflect.Float32
+}
+
+// weakSplit splits str on the WeakSliceSeparator into the elements of a
+// slice or array, trimming the spaces around each element.
+func (d *Decoder) weakSplit(str string) []interface{} {
+	parts := strings.Split(str, d.config.WeakSliceSeparator)
+	elems := make([]interface{}, len(parts))
+	for i, part := range parts {
+		elems[i] = strings.TrimSpace(part)
+	}
+
+	return elems
+}
+
+// mergeKeyField returns the field of the struct type elemType, or of the
+// struct type it points to, that mergeKey names. The field must be
+// comparable.
+func (d *Decoder) mergeKeyField(elemType reflect.Type, mergeKey string) (reflect.StructField, bool) {
+	if elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return reflect.StructField{}, false
+	}
+
+	for i := 0; i < elemType.NumField(); i++ {
+		f := elemType.Field(i)
+		if f.PkgPath != "" || !f.Type.Comparable() {
+			continue
+		}
+
+		fieldName := f.Name
+		if tagValue := strings.SplitN(d.fieldTag(f), ",", 2)[0]; tagValue != "" {
+			fieldName = tagValue
+		}
+		if d.config.MatchName(fieldName, mergeKey) {
+			return f, true
+		}
+	}
+
+	return reflect.StructField{}, false
+}
+
+// mergeSlice decodes the elements of dataVal into the elements of the
+// slice val that have the same value in keyField, and appends those that
+// match no element.
+func (d *Decoder) mergeSlice(name string, dataVal, val reflect.Value, keyField reflect.StructField, mergeKey string) error {
+	result := reflect.MakeSlice(val.Type(), val.Len(), val.Len()+dataVal.Len())
+	reflect.Copy(result, val)
+
+	keyOf := func(elem reflect.Value) (reflect.Value, bool) {
+		if elem.Kind() == reflect.Ptr {
+			if elem.IsNil() {
+				return reflect.Value{}, false
+			}
+			elem = elem.Elem()
+		}
+
+		return elem.FieldByIndex(keyField.Index), true
+	}
+
+	// A key field of an interface type can hold a value, such as a map,
+	// that can't be used as a key.
+	uncomparableKeyError := func(name string, key reflect.Value) error {
+		return d.decodingError(MsgUncomparableKey, &DecodingError{
+			Kind:  DecodingErrorUnsupportedType,
+			Name:  name,
+			Got:   key.Elem().Type().String(),
+			Value: key.Interface(),
+		})
+	}
+
+	positions := make(map[interface{}]int, result.Len())
+	for i := 0; i < result.Len(); i++ {
+		if key, ok := keyOf(result.Index(i)); ok {
+			if !key.Comparable() {
+				return uncomparableKeyError(name+"["+strconv.Itoa(i)+"]", key)
+			}
+			positions[key.Interface()] = i
+		}
+	}
+
+	// The key of each element is decoded on its own to find the element
+	// it is merged into. Any error is reported when the whole element is
+	// decoded, so it is neither reported twice nor recorded in metadata.
+	elemDecoder := d.nested()
+	keyDecoder := elemDecoder.withConfig(func(c *DecoderConfig) {
+		c.Metadata = nil
+	})
+
+	// Accumulate any errors
+	errors := make([]error, 0)
+
+	for i := 0; i < dataVal.Len(); i++ {
+		if err := d.ctxErr(); err != nil {
+			return err
+		}
+
+		currentData := dataVal.Index(i).Interface()
+		fieldName := name + "[" + strconv.Itoa(i) + "]"
+
+		target := -1
+		if m := reflect.Indirect(reflect.ValueOf(mapInput(currentData))); m.Kind() == reflect.Map {
+			if rawKey, ok := d.mapIndex(m, mergeKey); ok {
+				key := reflect.New(keyField.Type).Elem()
+				if keyDecoder.decode(fieldName, rawKey, key) == nil {
+					if !key.Comparable() {
+						errors = appendErrors(errors, withSourceKey(uncomparableKeyError(fieldName, key), strconv.Itoa(i)))
+						continue
+					}
+					if j, ok := positions[key.Interface()]; ok {
+						target = j
+					}
+				}
+			}
+		}
+
+		if target < 0 {
+			result = reflect.Append(result, reflect.Zero(val.Type().Elem()))
+			target = result.Len() - 1
+		}
+
+		if err := elemDecoder.decode(fieldName, currentData, result.Index(target)); err != nil {
+			errors = appendErrors(errors, withSourceKey(err, strconv.Itoa(i)))
+		}
+		if key, ok := keyOf(result.Index(target)); ok && key.Comparable() {
+			if _, exists := positions[key.Interface()]; !exists {
+				positions[key.Interface()] = target
+			}
+		}
+	}
+
+	val.Set(result)
+
+	if len(errors) > 0 {
+		return newError(errors)
 	}
 
 	return nil
@@ -1161,52 +3193,95 @@ func (d *Decoder) decodeArray(name string, data interface{}, val reflect.Value)
 	valElemType := valType.Elem()
 	arrayType := reflect.ArrayOf(valType.Len(), valElemType)
 
-	valArray := val
-
-	if valArray.Interface() == reflect.Zero(valArray.Type()).Interface() || d.config.ZeroFields {
-		// Check input type
-		if dataValKind != reflect.Array && dataValKind != reflect.Slice {
-			if d.config.WeaklyTypedInput {
-				switch {
-				// Empty maps turn into empty arrays
-				case dataValKind == reflect.Map:
-					if dataVal.Len() == 0 {
-						val.Set(reflect.Zero(arrayType))
-						return nil
-					}
-
-				// All other types we try to convert to the array type
-				// and "lift" it into it. i.e. a string becomes a string array.
-				default:
-					// Just re-try this function with data as a slice.
-					return d.decodeArray(name, []interface{}{data}, val)
+	// Check input type
+	if dataValKind != reflect.Array && dataValKind != reflect.Slice {
+		if d.config.WeaklyTypedInput {
+			switch {
+			// Empty maps turn into empty arrays
+			case dataValKind == reflect.Map:
+				if dataVal.Len() == 0 && !d.config.DisableWeakEmptyMapSlice {
+					val.Set(reflect.Zero(arrayType))
+					d.recordWeak(name, dataVal, val)
+					return nil
 				}
-			}
 
-			return fmt.Errorf(
-				"'%s': source data must be an array or slice, got %s", name, dataValKind)
+			// Separated numbers, such as "1,2,3", are split into elements.
+			case dataValKind == reflect.String && isNumberKind(getKind(reflect.Zero(valElemType))):
+				d.recordWeak(name, dataVal, val)
+				return d.decodeArray(name, d.weakSplit(dataVal.String()), val)
 
+			// All other types we try to convert to the array type
+			// and "lift" it into it. i.e. a string becomes a string array.
+			default:
+				// Just re-try this function with data as a slice.
+				d.recordWeak(name, dataVal, val)
+				return d.decodeArray(name, []interface{}{data}, val)
+			}
 		}
-		if dataVal.Len() > arrayType.Len() {
-			return fmt.Errorf(
-				"'%s': expected source data to have length less or equal to %d, got %d", name, arrayType.Len(), dataVal.Len())
 
+		if d.config.SingleValueToSlice && dataVal.IsValid() {
+			return d.decodeArray(name, []interface{}{data}, val)
 		}
 
-		// Make a new array to hold our result, same size as the original data.
-		valArray = reflect.New(arrayType).Elem()
+		return d.decodingError(MsgExpectedSlice, &DecodingError{
+			Kind:  DecodingErrorUnconvertibleType,
+			Name:  name,
+			Got:   dataValKind.String(),
+			Value: data,
+		})
+	}
+	if dataVal.Len() > arrayType.Len() {
+		return d.decodingError(MsgArrayLength, &DecodingError{
+			Kind:     DecodingErrorInvalidLength,
+			Name:     name,
+			Expected: strconv.Itoa(arrayType.Len()),
+			Got:      strconv.Itoa(dataVal.Len()),
+		})
+	}
+
+	fill := d.config.ArrayFill
+	if d.exactLength {
+		fill = ArrayFillError
+	}
+	if fill == ArrayFillDefault {
+		fill = ArrayFillKeep
+		if val.IsZero() || d.config.ZeroFields {
+			fill = ArrayFillZero
+		}
+	}
+	if fill == ArrayFillError && dataVal.Len() < arrayType.Len() {
+		return d.decodingError(MsgArrayShort, &DecodingError{
+			Kind:     DecodingErrorInvalidLength,
+			Name:     name,
+			Expected: strconv.Itoa(arrayType.Len()),
+			Got:      strconv.Itoa(dataVal.Len()),
+		})
+	}
+
+	// Make a new array to hold our result, starting from the current
+	// value if its remaining elements are kept.
+	valArray := reflect.New(arrayType).Elem()
+	if fill == ArrayFillKeep {
+		valArray.Set(val)
+	} else if fresh, ok := d.freshValue(valType); ok {
+		valArray.Set(fresh)
 	}
 
 	// Accumulate any errors
-	errors := make([]string, 0)
+	errors := make([]error, 0)
 
+	elemDecoder := d.nested()
 	for i := 0; i < dataVal.Len(); i++ {
+		if err := d.ctxErr(); err != nil {
+			return err
+		}
+
 		currentData := dataVal.Index(i).Interface()
 		currentField := valArray.Index(i)
 
 		fieldName := name + "[" + strconv.Itoa(i) + "]"
-		if err := d.decode(fieldName, currentData, currentField); err != nil {
-			errors = appendErrors(errors, err)
+		if err := elemDecoder.decode(fieldName, currentData, currentField); err != nil {
+			errors = appendErrors(errors, withSourceKey(err, strconv.Itoa(i)))
 		}
 	}
 
@@ -1215,7 +3290,7 @@ func (d *Decoder) decodeArray(name string, data interface{}, val reflect.Value)
 
 	// If there were errors, we return those
 	if len(errors) > 0 {
-		return &Error{errors}
+		return newError(errors)
 	}
 
 	return nil
@@ -1227,10 +3302,25 @@ func (d *Decoder) decodeStruct(name string, data interface{}, val reflect.Value)
 	// If the type of the value to write to and the data match directly,
 	// then we just set it directly instead of recursing into the structure.
 	if dataVal.Type() == val.Type() {
-		val.Set(dataVal)
+		val.Set(d.copyValue(dataVal, CopyShared))
 		return nil
 	}
 
+	// Structs of basic kinds are decoded from plain maps by assignment
+	// when nothing else needs to be done.
+	if m, ok := data.(map[string]interface{}); ok && val.CanAddr() && d.canDecodeFlat() {
+		if plan := d.flatPlan(val.Type()); plan.flat && d.decodeFlat(m, plan, val) {
+			return nil
+		}
+	}
+
+	data, err := d.pairsInput(name, data)
+	if err != nil {
+		return err
+	}
+	data = mapInput(data)
+	dataVal = reflect.Indirect(reflect.ValueOf(data))
+
 	dataValKind := dataVal.Kind()
 	switch dataValKind {
 	case reflect.Map:
@@ -1252,7 +3342,15 @@ func (d *Decoder) decodeStruct(name string, data interface{}, val reflect.Value)
 		addrVal := reflect.New(mval.Type())
 
 		reflect.Indirect(addrVal).Set(mval)
-		if err := d.decodeMapFromStruct(name, dataVal, reflect.Indirect(addrVal), mval); err != nil {
+
+		// The keys of the source struct are read from its own tags.
+		src := d
+		if d.config.SourceTagName != "" {
+			src = d.withConfig(func(c *DecoderConfig) {
+				c.TagName = d.config.SourceTagName
+			})
+		}
+		if err := src.decodeMapFromStruct(name, dataVal, reflect.Indirect(addrVal), mval); err != nil {
 			return err
 		}
 
@@ -1260,16 +3358,43 @@ func (d *Decoder) decodeStruct(name string, data interface{}, val reflect.Value)
 		return result
 
 	default:
-		return fmt.Errorf("'%s' expected a map, got '%s'", name, dataVal.Kind())
+		if d.config.XMLConventions && d.hasCharData(val.Type()) {
+			// An element with only character data
+			text := map[string]interface{}{xmlTextKey: data}
+			return d.decodeStructFromMap(name, reflect.ValueOf(text), val)
+		}
+
+		return d.decodingError(MsgExpectedMap, &DecodingError{
+			Kind:  DecodingErrorUnconvertibleType,
+			Name:  name,
+			Got:   dataVal.Kind().String(),
+			Value: data,
+		})
 	}
 }
 
 func (d *Decoder) decodeStructFromMap(name string, dataVal, val reflect.Value) error {
 	dataValType := dataVal.Type()
 	if kind := dataValType.Key().Kind(); kind != reflect.String && kind != reflect.Interface {
-		return fmt.Errorf(
-			"'%s' needs a map with string keys, has '%s' keys",
-			name, dataValType.Key().Kind())
+		if !d.config.StringifyKeys {
+			return d.decodingError(MsgExpectedStringKeys, &DecodingError{
+				Kind: DecodingErrorUnconvertibleType,
+				Name: name,
+				Got:  dataValType.Key().Kind().String(),
+			})
+		}
+
+		dataVal = stringifyMapKeys(dataVal)
+		dataValType = dataVal.Type()
+	}
+
+	dataVal, err := d.applyIncludes(name, dataVal)
+	if err != nil {
+		return err
+	}
+
+	if d.config.KeyTransform != nil {
+		dataVal = transformKeys(dataVal, d.config.KeyTransform)
 	}
 
 	dataValKeys := make(map[reflect.Value]struct{})
@@ -1279,8 +3404,14 @@ func (d *Decoder) decodeStructFromMap(name string, dataVal, val reflect.Value) e
 		dataValKeysUnused[dataValKey.Interface()] = struct{}{}
 	}
 
+	if !d.config.SetDefaultsAfterDecode {
+		setDefaults(val)
+	}
+
 	targetValKeysUnused := make(map[interface{}]struct{})
-	errors := make([]string, 0)
+	multiValue := isMultiValueMap(dataValType)
+	header := isHeaderMap(dataValType)
+	errors := make([]error, 0)
 
 	// This slice will keep track of all the structs we'll be decoding.
 	// There can be more than one struct if there are embedded structs
@@ -1291,7 +3422,7 @@ func (d *Decoder) decodeStructFromMap(name string, dataVal, val reflect.Value) e
 	// Compile the list of all the fields that we're going to be decoding
 	// from all the structs.
 	type field struct {
-		field reflect.StructField
+		field *structField
 		val   reflect.Value
 	}
 
@@ -1299,43 +3430,80 @@ func (d *Decoder) decodeStructFromMap(name string, dataVal, val reflect.Value) e
 	// we are keeping track of remaining values.
 	var remainField *field
 
+	// squashedMaps are the map fields with the "squash" tag, which collect
+	// the unused keys with their prefix.
+	var squashedMaps []field
+
+	// squashedInterfaces holds the embedded interfaces that hold a struct
+	// value, which can't be set in place. The fields are decoded into a
+	// copy that is stored back in the interface afterwards.
+	type squashedInterface struct {
+		iface, copied reflect.Value
+	}
+	var squashedInterfaces []squashedInterface
+
+	// decoded are the fields set from the input, which keep their values
+	// when SetDefaults is called after decoding.
+	var decoded []reflect.Value
+
 	fields := []field{}
 	for len(structs) > 0 {
 		structVal := structs[0]
 		structs = structs[1:]
 
-		structType := structVal.Type()
-
-		for i := 0; i < structType.NumField(); i++ {
-			fieldType := structType.Field(i)
-			fieldVal := structVal.Field(i)
+		structFields := d.structFields(structVal.Type())
+		for i := range structFields {
+			fieldType := &structFields[i]
+			fieldVal := structVal.FieldByIndex(fieldType.Index)
 			if fieldVal.Kind() == reflect.Ptr && fieldVal.Elem().Kind() == reflect.Struct {
 				// Handle embedded struct pointers as embedded structs.
 				fieldVal = fieldVal.Elem()
 			}
 
-			// If "squash" is specified in the tag, we squash the field down.
-			squash := d.config.Squash && fieldVal.Kind() == reflect.Struct && fieldType.Anonymous
-			remain := false
-
-			// We always parse the tags cause we're looking for other tags too
-			tagParts := strings.Split(fieldType.Tag.Get(d.config.TagName), ",")
-			for _, tag := range tagParts[1:] {
-				if tag == "squash" {
-					squash = true
-					break
+			// An embedded interface is squashed through the struct it holds.
+			if fieldType.Anonymous && fieldVal.Kind() == reflect.Interface {
+				if held, ok := interfaceStruct(fieldVal); ok {
+					if !held.CanSet() {
+						copied := reflect.New(held.Type()).Elem()
+						copied.Set(held)
+						squashedInterfaces = append(squashedInterfaces, squashedInterface{fieldVal, copied})
+						held = copied
+					}
+					fieldVal = held
 				}
+			}
 
-				if tag == "remain" {
-					remain = true
-					break
+			// A nil embedded struct pointer is squashed like a non-nil one.
+			nilStructPtr := fieldVal.Kind() == reflect.Ptr && fieldVal.IsNil() && fieldVal.Type().Elem().Kind() == reflect.Struct
+
+			// If "squash" is specified in the tag, we squash the field down.
+			squash := d.config.Squash && (fieldVal.Kind() == reflect.Struct || nilStructPtr) && fieldType.Anonymous
+			squash = squash || fieldType.squash
+			remain := fieldType.remain && !squash
+
+			if squash && nilStructPtr && fieldVal.CanSet() {
+				// Allocate the struct to decode into it.
+				allocated := reflect.New(fieldVal.Type().Elem())
+				if fresh, ok := d.freshValue(allocated.Elem().Type()); ok {
+					allocated.Elem().Set(fresh)
 				}
+				fieldVal.Set(allocated)
+				fieldVal = allocated.Elem()
+			}
+
+			if squash && (fieldVal.Kind() == reflect.Map ||
+				fieldVal.Kind() == reflect.Ptr && fieldVal.Type().Elem().Kind() == reflect.Map) {
+				squashedMaps = append(squashedMaps, field{fieldType, fieldVal})
+				continue
 			}
 
 			if squash {
 				if fieldVal.Kind() != reflect.Struct {
-					errors = appendErrors(errors,
-						fmt.Errorf("%s: unsupported type for squash: %s", fieldType.Name, fieldVal.Kind()))
+					errors = appendErrors(errors, d.decodingError(MsgSquashUnsupported, &DecodingError{
+						Kind: DecodingErrorInvalidSquash,
+						Name: fieldType.Name,
+						Got:  fieldVal.Kind().String(),
+					}))
 				} else {
 					structs = append(structs, fieldVal)
 				}
@@ -1354,17 +3522,92 @@ func (d *Decoder) decodeStructFromMap(name string, dataVal, val reflect.Value) e
 
 	// for fieldType, field := range fields {
 	for _, f := range fields {
+		if err := d.ctxErr(); err != nil {
+			return err
+		}
+
 		field, fieldValue := f.field, f.val
-		fieldName := field.Name
+		fieldName := field.name
+		if d.config.XMLConventions {
+			fieldName = d.xmlKey(field.StructField, fieldName)
+		}
 
-		tagValue := field.Tag.Get(d.config.TagName)
-		tagValue = strings.SplitN(tagValue, ",", 2)[0]
-		if tagValue != "" {
-			fieldName = tagValue
+		// The options of the tag change how the value of the field is
+		// decoded, wherever it is found.
+		fieldDecoder := d
+		for _, opt := range field.opts {
+			switch {
+			case opt == "exactlength":
+				exact := *fieldDecoder
+				exact.exactLength = true
+				fieldDecoder = &exact
+			case strings.HasPrefix(opt, "mergekey="):
+				merge := *fieldDecoder
+				merge.mergeKey = strings.TrimPrefix(opt, "mergekey=")
+				fieldDecoder = &merge
+			case opt == "secret":
+				secret := *fieldDecoder
+				secret.secret = true
+				fieldDecoder = &secret
+			}
+		}
+
+		if path, ok := field.path, field.hasPath; ok {
+			if !fieldValue.CanSet() {
+				continue
+			}
+
+			segments, err := parsePath(path, ".")
+			if err != nil {
+				errors = appendErrors(errors, d.decodingError(MsgInvalidPath, &DecodingError{
+					Kind: DecodingErrorGeneric,
+					Name: name,
+					Err:  err,
+				}))
+				continue
+			}
+
+			rawVal, ok := d.lookupPath(dataVal.Interface(), segments)
+			if !ok {
+				targetValKeysUnused[path] = struct{}{}
+				if err := d.checkRequired(name, path, field); err != nil {
+					errors = appendErrors(errors, err)
+				}
+				continue
+			}
+
+			// The key is used if the path was looked up under it, which is
+			// matched like the rest of the path rather than with MatchField.
+			if !segments[0].isIndex {
+				if key, ok := d.mapKey(dataVal, segments[0].key); ok {
+					delete(dataValKeysUnused, key.Interface())
+				}
+			}
+
+			if name != "" {
+				path = name + "." + path
+			}
+
+			decoded = append(decoded, fieldValue)
+			if err := fieldDecoder.decode(path, rawVal, fieldValue); err != nil {
+				for i := len(segments) - 1; i >= 0; i-- {
+					err = withSourceKey(err, segments[i].String())
+				}
+				errors = appendErrors(errors, err)
+			}
+			continue
 		}
 
 		rawMapKey := reflect.ValueOf(fieldName)
-		rawMapVal := dataVal.MapIndex(rawMapKey)
+		var rawMapVal reflect.Value
+		if d.config.MatchField == nil {
+			rawMapVal = dataVal.MapIndex(rawMapKey)
+			if !rawMapVal.IsValid() && header {
+				// Header keys are stored in their canonical form
+				rawMapKey = reflect.ValueOf(textproto.CanonicalMIMEHeaderKey(fieldName))
+				rawMapVal = dataVal.MapIndex(rawMapKey)
+			}
+		}
 		if !rawMapVal.IsValid() {
 			// Do a slower search by iterating over each key and
 			// doing case-insensitive search.
@@ -1375,7 +3618,14 @@ func (d *Decoder) decodeStructFromMap(name string, dataVal, val reflect.Value) e
 					continue
 				}
 
-				if d.config.MatchName(mK, fieldName) {
+				var matched bool
+				if d.config.MatchField != nil {
+					matched = d.config.MatchField(mK, field.StructField)
+				} else {
+					matched = d.config.MatchName(mK, fieldName)
+				}
+
+				if matched {
 					rawMapKey = dataValKey
 					rawMapVal = dataVal.MapIndex(dataValKey)
 					break
@@ -1386,6 +3636,9 @@ func (d *Decoder) decodeStructFromMap(name string, dataVal, val reflect.Value) e
 				// There was no matching key in the map for the value in
 				// the struct. Remember it for potential errors and metadata.
 				targetValKeysUnused[fieldName] = struct{}{}
+				if err := d.checkRequired(name, fieldName, field); err != nil {
+					errors = appendErrors(errors, err)
+				}
 				continue
 			}
 		}
@@ -1410,7 +3663,40 @@ func (d *Decoder) decodeStructFromMap(name string, dataVal, val reflect.Value) e
 			fieldName = name + "." + fieldName
 		}
 
-		if err := d.decode(fieldName, rawMapVal.Interface(), fieldValue); err != nil {
+		rawVal := rawMapVal.Interface()
+		if multiValue {
+			rawVal = d.multiValueInput(rawVal, fieldValue.Type())
+		}
+
+		decoded = append(decoded, fieldValue)
+		if err := fieldDecoder.decode(fieldName, rawVal, fieldValue); err != nil {
+			errors = appendErrors(errors, withSourceKey(err, fmt.Sprint(rawMapKey)))
+		}
+	}
+
+	for _, squashed := range squashedInterfaces {
+		if squashed.iface.CanSet() {
+			squashed.iface.Set(squashed.copied)
+		}
+	}
+
+	// The squashed maps take the unused keys with their prefix, which is
+	// stripped, before the "remain" field gets the rest.
+	for _, f := range squashedMaps {
+		prefix, _ := tagOptionValue(f.field.tag, "prefix")
+		keys := make(map[interface{}]struct{})
+		for rawKey := range dataValKeysUnused {
+			if key, ok := rawKey.(string); ok && strings.HasPrefix(key, prefix) {
+				keys[rawKey] = struct{}{}
+				delete(dataValKeysUnused, rawKey)
+			}
+		}
+		if len(keys) == 0 {
+			continue
+		}
+
+		decoded = append(decoded, f.val)
+		if err := d.decodeRemain(name, f.field.StructField, dataVal, keys, prefix, f.val); err != nil {
 			errors = appendErrors(errors, err)
 		}
 	}
@@ -1418,14 +3704,8 @@ func (d *Decoder) decodeStructFromMap(name string, dataVal, val reflect.Value) e
 	// If we have a "remain"-tagged field and we have unused keys then
 	// we put the unused keys directly into the remain field.
 	if remainField != nil && len(dataValKeysUnused) > 0 {
-		// Build a map of only the unused values
-		remain := map[interface{}]interface{}{}
-		for key := range dataValKeysUnused {
-			remain[key] = dataVal.MapIndex(reflect.ValueOf(key)).Interface()
-		}
-
-		// Decode it as-if we were just decoding this map onto our map.
-		if err := d.decodeMap(name, remain, remainField.val); err != nil {
+		decoded = append(decoded, remainField.val)
+		if err := d.decodeRemain(name, remainField.field.StructField, dataVal, dataValKeysUnused, "", remainField.val); err != nil {
 			errors = appendErrors(errors, err)
 		}
 
@@ -1437,33 +3717,41 @@ func (d *Decoder) decodeStructFromMap(name string, dataVal, val reflect.Value) e
 	if d.config.ErrorUnused && len(dataValKeysUnused) > 0 {
 		keys := make([]string, 0, len(dataValKeysUnused))
 		for rawKey := range dataValKeysUnused {
-			keys = append(keys, rawKey.(string))
+			keys = append(keys, fmt.Sprint(rawKey))
 		}
 		sort.Strings(keys)
 
-		err := fmt.Errorf("'%s' has invalid keys: %s", name, strings.Join(keys, ", "))
+		err := d.decodingError(MsgUnusedKeys, &DecodingError{
+			Kind:  DecodingErrorUnusedKeys,
+			Name:  name,
+			Value: strings.Join(keys, ", "),
+		})
 		errors = appendErrors(errors, err)
 	}
 
 	if d.config.ErrorUnset && len(targetValKeysUnused) > 0 {
 		keys := make([]string, 0, len(targetValKeysUnused))
 		for rawKey := range targetValKeysUnused {
-			keys = append(keys, rawKey.(string))
+			keys = append(keys, fmt.Sprint(rawKey))
 		}
 		sort.Strings(keys)
 
-		err := fmt.Errorf("'%s' has unset fields: %s", name, strings.Join(keys, ", "))
+		err := d.decodingError(MsgUnsetFields, &DecodingError{
+			Kind:  DecodingErrorUnsetFields,
+			Name:  name,
+			Value: strings.Join(keys, ", "),
+		})
 		errors = appendErrors(errors, err)
 	}
 
 	if len(errors) > 0 {
-		return &Error{errors}
+		return newError(errors)
 	}
 
 	// Add the unused keys to the list of unused keys if we're tracking metadata
 	if d.config.Metadata != nil {
 		for rawKey := range dataValKeysUnused {
-			key := rawKey.(string)
+			key := fmt.Sprint(rawKey)
 			if name != "" {
 				key = name + "." + key
 			}
@@ -1471,7 +3759,7 @@ func (d *Decoder) decodeStructFromMap(name string, dataVal, val reflect.Value) e
 			d.config.Metadata.Unused = append(d.config.Metadata.Unused, key)
 		}
 		for rawKey := range targetValKeysUnused {
-			key := rawKey.(string)
+			key := fmt.Sprint(rawKey)
 			if name != "" {
 				key = name + "." + key
 			}
@@ -1480,9 +3768,217 @@ func (d *Decoder) decodeStructFromMap(name string, dataVal, val reflect.Value) e
 		}
 	}
 
+	if d.config.SetDefaultsAfterDecode {
+		setDefaultsKeeping(val, decoded)
+	}
+
+	return nil
+}
+
+// checkRequired returns the error for the field f, whose key in the map
+// that the struct at name is decoded from is key, missing from it, if f
+// must be present.
+func (d *Decoder) checkRequired(name, key string, f *structField) error {
+	if !d.config.ErrorRequired || !f.required {
+		return nil
+	}
+
+	if name != "" {
+		key = name + "." + key
+	}
+
+	return d.decodingError(MsgMissingRequired, &DecodingError{
+		Kind: DecodingErrorMissingRequired,
+		Name: key,
+	})
+}
+
+// interfaceStruct returns the struct held by the non-nil interface v,
+// either directly or through a non-nil pointer.
+func interfaceStruct(v reflect.Value) (reflect.Value, bool) {
+	if v.IsNil() {
+		return reflect.Value{}, false
+	}
+
+	held := v.Elem()
+	if held.Kind() == reflect.Ptr && !held.IsNil() {
+		held = held.Elem()
+	}
+
+	return held, held.Kind() == reflect.Struct
+}
+
+// freshValue returns the value of the ZeroValueProviders entry for typ, if
+// there is one.
+func (d *Decoder) freshValue(typ reflect.Type) (reflect.Value, bool) {
+	provide, ok := d.config.ZeroValueProviders[typ]
+	if !ok {
+		return reflect.Value{}, false
+	}
+
+	// NewDecoder checked that the provider returns a typ.
+	v := reflect.ValueOf(provide())
+	if !v.IsValid() {
+		return reflect.Zero(typ), true
+	}
+
+	return v, true
+}
+
+// zeroValue returns the value that ZeroFields resets a value of type typ
+// to: the fresh value from ZeroValueProviders or the zero value.
+func (d *Decoder) zeroValue(typ reflect.Type) reflect.Value {
+	if fresh, ok := d.freshValue(typ); ok {
+		return fresh
+	}
+
+	return reflect.Zero(typ)
+}
+
+// decodeRemain decodes the values of the unused keys of dataVal into val,
+// the map of the "remain" or squashed field f, or a pointer to one, under
+// the keys without prefix. Every value is converted to the element type
+// of the map like the value of any other field, and errors are named
+// after its key.
+func (d *Decoder) decodeRemain(name string, f reflect.StructField, dataVal reflect.Value, keys map[interface{}]struct{}, prefix string, val reflect.Value) error {
+	if !val.CanSet() {
+		return nil
+	}
+	if val.Kind() == reflect.Ptr && val.Type().Elem().Kind() == reflect.Map {
+		if val.IsNil() {
+			val.Set(reflect.New(val.Type().Elem()))
+		}
+		val = val.Elem()
+	}
+	remainName := f.Name
+	if name != "" {
+		remainName = name + "." + remainName
+	}
+	if val.Kind() != reflect.Map {
+		return d.decodingError(MsgUnsupportedType, &DecodingError{
+			Kind:     DecodingErrorUnsupportedType,
+			Name:     remainName,
+			Expected: val.Type().String(),
+		})
+	}
+
+	// Keys matching an "except=" pattern are dropped.
+	var except []string
+	for _, opt := range strings.Split(d.fieldTag(f), ",")[1:] {
+		if pattern := strings.TrimPrefix(opt, "except="); pattern != opt {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return d.decodingError(MsgInvalidPattern, &DecodingError{
+					Kind:  DecodingErrorGeneric,
+					Name:  remainName,
+					Value: pattern,
+					Err:   err,
+				})
+			}
+			except = append(except, pattern)
+		}
+	}
+
+	valMap := val
+	if valMap.IsNil() || d.config.ZeroFields {
+		valMap = reflect.MakeMap(val.Type())
+	}
+
+	// The keys of interface-keyed maps need not be strings; they are
+	// named, matched and sorted by their string form, and converted to
+	// the key type of the map like any other value.
+	type remainKey struct {
+		raw  interface{}
+		name string
+	}
+	sortedKeys := make([]remainKey, 0, len(keys))
+	for key := range keys {
+		name := fmt.Sprint(key)
+		if !matchAny(except, name) {
+			sortedKeys = append(sortedKeys, remainKey{key, name})
+		}
+	}
+	sort.Slice(sortedKeys, func(i, j int) bool {
+		return sortedKeys[i].name < sortedKeys[j].name
+	})
+
+	// The keys are converted without recording them in the metadata,
+	// which only records the names of the values.
+	keyDecoder := d.withConfig(func(c *DecoderConfig) {
+		c.Metadata = nil
+	})
+
+	errors := make([]error, 0)
+	for _, key := range sortedKeys {
+		fieldName := key.name
+		if name != "" {
+			fieldName = name + "." + key.name
+		}
+
+		rawKey := key.raw
+		if s, ok := rawKey.(string); ok {
+			rawKey = strings.TrimPrefix(s, prefix)
+		}
+
+		currentKey := reflect.New(val.Type().Key()).Elem()
+		if err := keyDecoder.decode(fieldName, rawKey, currentKey); err != nil {
+			errors = appendErrors(errors, withSourceKey(err, key.name))
+			continue
+		}
+
+		currentVal := reflect.New(val.Type().Elem()).Elem()
+		if err := d.decode(fieldName, dataVal.MapIndex(reflect.ValueOf(key.raw)).Interface(), currentVal); err != nil {
+			errors = appendErrors(errors, withSourceKey(err, key.name))
+			continue
+		}
+
+		valMap.SetMapIndex(currentKey, currentVal)
+	}
+
+	val.Set(valMap)
+
+	if len(errors) > 0 {
+		return newError(errors)
+	}
+
 	return nil
 }
 
+// matchAny reports whether name matches any of the given patterns, which
+// use the syntax of path.Match.
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// nested returns the decoder for the values nested in the one being
+// decoded, which the tag options of the field holding it don't apply to.
+func (d *Decoder) nested() *Decoder {
+	if !d.exactLength && d.mergeKey == "" {
+		return d
+	}
+
+	copied := *d
+	copied.exactLength = false
+	copied.mergeKey = ""
+	return &copied
+}
+
+// withConfig returns a copy of d with a copy of its config changed by
+// update, for decoding a single field.
+func (d *Decoder) withConfig(update func(*DecoderConfig)) *Decoder {
+	config := *d.config
+	update(&config)
+
+	copied := *d
+	copied.config = &config
+	return &copied
+}
+
 func isEmptyValue(v reflect.Value) bool {
 	switch getKind(v) {
 	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
@@ -283,3 +283,58 @@ func Benchmark_DecodeTagged(b *testing.B) {
 		Decode(input, &result)
 	}
 }
+
+func Benchmark_DecodeFlat(b *testing.B) {
+	type Request struct {
+		Method  string `mapstructure:"method"`
+		ID      int64  `mapstructure:"id"`
+		Retries uint8  `mapstructure:"retries"`
+		Timeout float64
+		Async   bool
+	}
+
+	input := map[string]interface{}{
+		"method":  "get",
+		"id":      int64(42),
+		"retries": 3,
+		"timeout": 1.5,
+		"async":   true,
+	}
+
+	var result Request
+	decoder, err := NewDecoder(&DecoderConfig{Result: &result})
+	if err != nil {
+		b.Fatalf("err: %s", err)
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		decoder.Decode(input)
+	}
+}
+
+func Benchmark_DecodeBatch(b *testing.B) {
+	inputs := make([]map[string]interface{}, 100)
+	for i := range inputs {
+		inputs[i] = map[string]interface{}{
+			"method":  "get",
+			"id":      i,
+			"RETRIES": 3,
+			"timeout": 1.5,
+			"async":   true,
+		}
+	}
+
+	decoder, err := NewDecoder(&DecoderConfig{Result: &struct{}{}})
+	if err != nil {
+		b.Fatalf("err: %s", err)
+	}
+
+	var results []flatRequest
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := decoder.DecodeBatch(inputs, &results); err != nil {
+			b.Fatalf("err: %s", err)
+		}
+	}
+}
@@ -1,10 +1,15 @@
 package mapstructure
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"math"
 	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -751,6 +756,52 @@ func TestDecode_EmbeddedPointerSquash_FromMapToStruct(t *testing.T) {
 	}
 }
 
+func TestDecode_EmbeddedPointerSquash_FromMapToStructNil(t *testing.T) {
+	t.Parallel()
+
+	input := map[string]interface{}{
+		"Vstring": "foo",
+		"Vunique": "bar",
+	}
+
+	var result EmbeddedPointerSquash
+	err := Decode(input, &result)
+	if err != nil {
+		t.Fatalf("got an err: %s", err.Error())
+	}
+
+	if result.Basic == nil {
+		t.Fatal("basic should be allocated")
+	}
+
+	if result.Vstring != "foo" {
+		t.Errorf("vstring value should be 'foo': %#v", result.Vstring)
+	}
+
+	if result.Vunique != "bar" {
+		t.Errorf("vunique value should be 'bar': %#v", result.Vunique)
+	}
+
+	var squashed EmbeddedPointer
+	config := &DecoderConfig{
+		Squash: true,
+		Result: &squashed,
+	}
+
+	decoder, err := NewDecoder(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := decoder.Decode(input); err != nil {
+		t.Fatalf("got an err: %s", err.Error())
+	}
+
+	if squashed.Basic == nil || squashed.Vstring != "foo" {
+		t.Errorf("vstring value should be 'foo': %#v", squashed.Basic)
+	}
+}
+
 func TestDecode_EmbeddedPointerSquashWithNestedMapstructure_FromStructToMap(t *testing.T) {
 	t.Parallel()
 
@@ -915,6 +966,59 @@ func TestDecodeFrom_EmbeddedSquashConfig(t *testing.T) {
 	}
 }
 
+func TestDecode_StructToStructTags(t *testing.T) {
+	t.Parallel()
+
+	type Endpoint struct {
+		Address string `mapstructure:"addr" json:"address"`
+	}
+
+	type Source struct {
+		ServiceName string   `mapstructure:"name" json:"service_name"`
+		Main        Endpoint `mapstructure:"endpoint" json:"main_endpoint"`
+	}
+
+	type Target struct {
+		Name     string `mapstructure:"service_name"`
+		Endpoint struct {
+			Addr string `mapstructure:"address"`
+		} `mapstructure:"main_endpoint"`
+	}
+
+	type SameTags struct {
+		Title string `mapstructure:"name"`
+		Where struct {
+			URL string `mapstructure:"addr"`
+		} `mapstructure:"endpoint"`
+	}
+
+	input := Source{ServiceName: "api", Main: Endpoint{Address: "localhost:80"}}
+
+	// Fields are matched by the keys in their tags, not their names.
+	var same SameTags
+	if err := Decode(input, &same); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if same.Title != "api" || same.Where.URL != "localhost:80" {
+		t.Fatalf("bad: %#v", same)
+	}
+
+	var result Target
+	decoder, err := NewDecoder(&DecoderConfig{
+		SourceTagName: "json",
+		Result:        &result,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := decoder.Decode(input); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if result.Name != "api" || result.Endpoint.Addr != "localhost:80" {
+		t.Fatalf("bad: %#v", result)
+	}
+}
+
 func TestDecodeFrom_EmbeddedSquashConfig_WithTags(t *testing.T) {
 	t.Parallel()
 
@@ -978,6 +1082,68 @@ func TestDecode_SquashOnNonStructType(t *testing.T) {
 	}
 }
 
+type PluginOptions interface {
+	PluginName() string
+}
+
+type HTTPPluginOptions struct {
+	Address string
+	Timeout int
+}
+
+func (o HTTPPluginOptions) PluginName() string { return "http" }
+
+func TestDecode_SquashInterface(t *testing.T) {
+	t.Parallel()
+
+	type Options struct {
+		Name          string
+		PluginOptions `mapstructure:",squash"`
+	}
+
+	input := map[string]interface{}{
+		"Name":    "api",
+		"Address": ":8080",
+		"Timeout": 5,
+	}
+
+	// A struct held by value is decoded into a copy that is stored back.
+	byValue := Options{PluginOptions: HTTPPluginOptions{Timeout: 1}}
+	if err := Decode(input, &byValue); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := Options{
+		Name:          "api",
+		PluginOptions: HTTPPluginOptions{Address: ":8080", Timeout: 5},
+	}
+	if !reflect.DeepEqual(byValue, expected) {
+		t.Fatalf("bad: %#v", byValue)
+	}
+
+	plugin := &HTTPPluginOptions{}
+	byPointer := Options{PluginOptions: plugin}
+	if err := Decode(input, &byPointer); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if plugin.Address != ":8080" || plugin.Timeout != 5 {
+		t.Fatalf("bad: %#v", plugin)
+	}
+
+	var result map[string]interface{}
+	if err := Decode(byValue, &result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(result, input) {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	// A nil interface still can't be squashed.
+	var empty Options
+	if err := Decode(input, &empty); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
 func TestDecode_DecodeHook(t *testing.T) {
 	t.Parallel()
 
@@ -1051,6 +1217,72 @@ func TestDecode_DecodeHookType(t *testing.T) {
 	}
 }
 
+func TestDecode_DecodeHookError(t *testing.T) {
+	t.Parallel()
+
+	input := map[string]interface{}{
+		"vint":    "WHAT",
+		"vstring": 42,
+	}
+
+	hookErr := errors.New("invalid value")
+	decodeHook := func(from reflect.Type, to reflect.Type, v interface{}) (interface{}, error) {
+		if v == "WHAT" {
+			return nil, hookErr
+		}
+
+		return v, nil
+	}
+
+	var result Basic
+	config := &DecoderConfig{
+		DecodeHook: decodeHook,
+		Result:     &result,
+	}
+
+	decoder, err := NewDecoder(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	err = decoder.Decode(input)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	if !errors.Is(err, hookErr) {
+		t.Fatalf("expected error to wrap the hook error: %s", err)
+	}
+
+	derr := err.(*Error)
+	if len(derr.Errors) != 2 {
+		t.Fatalf("expected 2 errors, got: %#v", derr.Errors)
+	}
+
+	var kinds []DecodingErrorKind
+	for _, e := range derr.WrappedErrors() {
+		var de *DecodingError
+		if !errors.As(e, &de) {
+			t.Fatalf("expected a DecodingError: %#v", e)
+		}
+		kinds = append(kinds, de.Kind)
+
+		if de.Kind == DecodingErrorHookFailure {
+			if de.Name != "Vint" {
+				t.Errorf("bad name: %s", de.Name)
+			}
+			if de.Error() != "error decoding 'Vint': invalid value" {
+				t.Errorf("bad error: %s", de)
+			}
+		}
+	}
+
+	expected := []DecodingErrorKind{DecodingErrorUnconvertibleType, DecodingErrorHookFailure}
+	if !reflect.DeepEqual(kinds, expected) {
+		t.Fatalf("bad kinds: %#v", kinds)
+	}
+}
+
 func TestDecode_Nil(t *testing.T) {
 	t.Parallel()
 
@@ -1406,6 +1638,71 @@ func TestDecoder_ErrorUnset(t *testing.T) {
 	}
 }
 
+func TestDecoder_ErrorRequired(t *testing.T) {
+	t.Parallel()
+
+	type Server struct {
+		Host string `mapstructure:"host"`
+		Port int    `mapstructure:"port,required"`
+	}
+	type Config struct {
+		Name   string `mapstructure:"name,required"`
+		Server Server `mapstructure:"server"`
+		Region string `mapstructure:"region,required" jpath:"meta.region"`
+	}
+
+	decode := func(input map[string]interface{}) error {
+		var result Config
+		decoder, err := NewDecoder(&DecoderConfig{
+			ErrorRequired: true,
+			PathTagName:   "jpath",
+			Result:        &result,
+		})
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		return decoder.Decode(input)
+	}
+
+	err := decode(map[string]interface{}{
+		"server": map[string]interface{}{"host": "localhost"},
+	})
+	var derr *Error
+	if !errors.As(err, &derr) {
+		t.Fatalf("expected an Error, got %#v", err)
+	}
+
+	if len(derr.FilterByKind(DecodingErrorMissingRequired).Errors) != len(derr.Errors) {
+		t.Fatalf("expected only missing required errors, got %s", derr)
+	}
+	got := append([]string(nil), derr.Errors...)
+	sort.Strings(got)
+	expected := []string{
+		"'meta.region' is required",
+		"'name' is required",
+		"'server.port' is required",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+
+	// Nil values count as present, and the host isn't required.
+	err = decode(map[string]interface{}{
+		"name":   nil,
+		"server": map[string]interface{}{"port": 80},
+		"meta":   map[string]interface{}{"region": "eu"},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Without ErrorRequired, the option is ignored.
+	var result Config
+	if err := Decode(map[string]interface{}{}, &result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
 func TestMap(t *testing.T) {
 	t.Parallel()
 
@@ -1476,88 +1773,317 @@ func TestMapMerge(t *testing.T) {
 	}
 }
 
-func TestMapOfStruct(t *testing.T) {
+func TestDecode_pointerChains(t *testing.T) {
 	t.Parallel()
 
+	type Leaf struct {
+		A int
+		B string
+	}
+	type Config struct {
+		Struct **Leaf
+		Map    **map[string]int
+		Slice  ***[]int
+		Iface  *interface{}
+		Nested **interface{}
+	}
+
 	input := map[string]interface{}{
-		"value": map[string]interface{}{
-			"foo": map[string]string{"vstring": "one"},
-			"bar": map[string]string{"vstring": "two"},
-		},
+		"struct": map[string]interface{}{"a": 1},
+		"map":    map[string]interface{}{"x": 1},
+		"slice":  []interface{}{1, 2},
+		"iface":  map[string]interface{}{"k": "v"},
+		"nested": 3,
 	}
 
-	var result MapOfStruct
-	err := Decode(input, &result)
+	var result Config
+	if err := Decode(input, &result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if (**result.Struct).A != 1 ||
+		!reflect.DeepEqual(**result.Map, map[string]int{"x": 1}) ||
+		!reflect.DeepEqual(***result.Slice, []int{1, 2}) ||
+		!reflect.DeepEqual(*result.Iface, map[string]interface{}{"k": "v"}) ||
+		**result.Nested != 3 {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	// Pointers that are set are decoded into in place at every level.
+	leaf := *result.Struct
+	leaf.B = "keep"
+	var held interface{} = &Leaf{A: 5}
+	result.Iface = &held
+	input = map[string]interface{}{
+		"struct": map[string]interface{}{"a": 2},
+		"map":    map[string]interface{}{"y": 2},
+		"iface":  map[string]interface{}{"b": "set"},
+	}
+	if err := Decode(input, &result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if *result.Struct != leaf || *leaf != (Leaf{A: 2, B: "keep"}) {
+		t.Fatalf("bad struct: %#v", **result.Struct)
+	}
+	if !reflect.DeepEqual(**result.Map, map[string]int{"x": 1, "y": 2}) {
+		t.Fatalf("bad map: %#v", **result.Map)
+	}
+	if held.(*Leaf).A != 5 || held.(*Leaf).B != "set" {
+		t.Fatalf("bad iface: %#v", held)
+	}
+
+	// With ZeroFields every level is allocated anew.
+	decoder, err := NewDecoder(&DecoderConfig{ZeroFields: true, Result: &result})
 	if err != nil {
-		t.Fatalf("got an err: %s", err)
+		t.Fatalf("err: %s", err)
+	}
+	if err := decoder.Decode(map[string]interface{}{"struct": map[string]interface{}{"a": 3}}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if *result.Struct == leaf || **result.Struct != (Leaf{A: 3}) {
+		t.Fatalf("bad struct: %#v", **result.Struct)
 	}
 
-	if result.Value == nil {
-		t.Fatal("value should not be nil")
+	// A nil input leaves the pointers as they are, a nil map or slice
+	// sets them to nil.
+	var nilMap map[string]int
+	var nilSlice []int
+	input = map[string]interface{}{
+		"struct": nil,
+		"map":    nilMap,
+		"slice":  &nilSlice,
+	}
+	if err := Decode(input, &result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if result.Struct == nil || result.Map != nil || result.Slice != nil {
+		t.Fatalf("bad: %#v", result)
 	}
 
-	if len(result.Value) != 2 {
-		t.Error("value should have two items")
+	// Pointer chains in the input are followed.
+	leafPtr := &Leaf{A: 4}
+	n := 7
+	np := &n
+	var out struct {
+		Leaf Leaf
+		N    *int
+	}
+	if err := Decode(map[string]interface{}{"leaf": &leafPtr, "n": &np}, &out); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if out.Leaf.A != 4 || *out.N != 7 {
+		t.Fatalf("bad: %#v", out)
 	}
 
-	if result.Value["foo"].Vstring != "one" {
-		t.Errorf("foo value should be 'one', got: %s", result.Value["foo"].Vstring)
+	var nilPtr *int
+	out.N = &n
+	if err := Decode(map[string]interface{}{"n": &nilPtr}, &out); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if out.N != &n {
+		t.Fatalf("expected a nil chain to leave the pointer, got %#v", out.N)
 	}
 
-	if result.Value["bar"].Vstring != "two" {
-		t.Errorf("bar value should be 'two', got: %s", result.Value["bar"].Vstring)
+	// A chain that loops doesn't hang the decoder.
+	type loop *loop
+	var l loop
+	l = &l
+	if err := Decode(map[string]interface{}{"n": l}, &out); err == nil {
+		t.Fatal("expected error")
 	}
 }
 
-func TestNestedType(t *testing.T) {
+func TestMapMerge_pointerValues(t *testing.T) {
 	t.Parallel()
 
+	type Backend struct {
+		Host string
+		Port int
+	}
+
+	web := &Backend{Host: "web", Port: 80}
+	result := map[string]*Backend{"web": web}
+
 	input := map[string]interface{}{
-		"vfoo": "foo",
-		"vbar": map[string]interface{}{
-			"vstring": "foo",
-			"vint":    42,
-			"vbool":   true,
-		},
+		"web": map[string]interface{}{"port": 8080},
+		"api": map[string]interface{}{"host": "api"},
 	}
 
-	var result Nested
-	err := Decode(input, &result)
-	if err != nil {
-		t.Fatalf("got an err: %s", err.Error())
+	if err := Decode(input, &result); err != nil {
+		t.Fatalf("got an error: %s", err)
 	}
 
-	if result.Vfoo != "foo" {
-		t.Errorf("vfoo value should be 'foo': %#v", result.Vfoo)
+	if result["web"] != web {
+		t.Fatal("existing value should be decoded in place")
 	}
 
-	if result.Vbar.Vstring != "foo" {
-		t.Errorf("vstring value should be 'foo': %#v", result.Vbar.Vstring)
+	expected := map[string]*Backend{
+		"web": {Host: "web", Port: 8080},
+		"api": {Host: "api"},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("bad: %#v", result)
 	}
 
-	if result.Vbar.Vint != 42 {
-		t.Errorf("vint value should be 42: %#v", result.Vbar.Vint)
+	// With ZeroFields the values are replaced.
+	config := &DecoderConfig{
+		ZeroFields: true,
+		Result:     &result,
 	}
 
-	if result.Vbar.Vbool != true {
-		t.Errorf("vbool value should be true: %#v", result.Vbar.Vbool)
+	decoder, err := NewDecoder(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
 	}
 
-	if result.Vbar.Vextra != "" {
-		t.Errorf("vextra value should be empty: %#v", result.Vbar.Vextra)
+	if err := decoder.Decode(input); err != nil {
+		t.Fatalf("got an error: %s", err)
+	}
+
+	if result["web"] == web || result["web"].Host != "" {
+		t.Errorf("bad: %#v", result["web"])
 	}
 }
 
-func TestNestedTypePointer(t *testing.T) {
+func TestMapMerge_interfaceValues(t *testing.T) {
 	t.Parallel()
 
+	type Plugin interface{}
+	type Backend struct {
+		Host string
+		Port int
+	}
+
+	web := &Backend{Host: "web", Port: 80}
+	result := map[string]Plugin{
+		"web":   web,
+		"cache": Backend{Host: "cache", Port: 6379},
+	}
+
 	input := map[string]interface{}{
-		"vfoo": "foo",
-		"vbar": &map[string]interface{}{
-			"vstring": "foo",
-			"vint":    42,
-			"vbool":   true,
-		},
+		"web":   map[string]interface{}{"port": 8080},
+		"cache": map[string]interface{}{"port": 6380},
+		"api":   map[string]interface{}{"host": "api"},
+	}
+
+	config := &DecoderConfig{
+		KeepInterfaceValues: true,
+		Result:              &result,
+	}
+
+	decoder, err := NewDecoder(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := decoder.Decode(input); err != nil {
+		t.Fatalf("got an error: %s", err)
+	}
+
+	if result["web"] != web {
+		t.Fatal("existing value should be decoded in place")
+	}
+
+	expected := map[string]Plugin{
+		"web":   &Backend{Host: "web", Port: 8080},
+		"cache": Backend{Host: "cache", Port: 6380},
+		"api":   map[string]interface{}{"host": "api"},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("bad: %#v", result)
+	}
+
+	// Without KeepInterfaceValues the values are replaced.
+	if err := Decode(input, &result); err != nil {
+		t.Fatalf("got an error: %s", err)
+	}
+
+	if !reflect.DeepEqual(result["web"], map[string]interface{}{"port": 8080}) {
+		t.Errorf("bad: %#v", result["web"])
+	}
+}
+
+func TestMapOfStruct(t *testing.T) {
+	t.Parallel()
+
+	input := map[string]interface{}{
+		"value": map[string]interface{}{
+			"foo": map[string]string{"vstring": "one"},
+			"bar": map[string]string{"vstring": "two"},
+		},
+	}
+
+	var result MapOfStruct
+	err := Decode(input, &result)
+	if err != nil {
+		t.Fatalf("got an err: %s", err)
+	}
+
+	if result.Value == nil {
+		t.Fatal("value should not be nil")
+	}
+
+	if len(result.Value) != 2 {
+		t.Error("value should have two items")
+	}
+
+	if result.Value["foo"].Vstring != "one" {
+		t.Errorf("foo value should be 'one', got: %s", result.Value["foo"].Vstring)
+	}
+
+	if result.Value["bar"].Vstring != "two" {
+		t.Errorf("bar value should be 'two', got: %s", result.Value["bar"].Vstring)
+	}
+}
+
+func TestNestedType(t *testing.T) {
+	t.Parallel()
+
+	input := map[string]interface{}{
+		"vfoo": "foo",
+		"vbar": map[string]interface{}{
+			"vstring": "foo",
+			"vint":    42,
+			"vbool":   true,
+		},
+	}
+
+	var result Nested
+	err := Decode(input, &result)
+	if err != nil {
+		t.Fatalf("got an err: %s", err.Error())
+	}
+
+	if result.Vfoo != "foo" {
+		t.Errorf("vfoo value should be 'foo': %#v", result.Vfoo)
+	}
+
+	if result.Vbar.Vstring != "foo" {
+		t.Errorf("vstring value should be 'foo': %#v", result.Vbar.Vstring)
+	}
+
+	if result.Vbar.Vint != 42 {
+		t.Errorf("vint value should be 42: %#v", result.Vbar.Vint)
+	}
+
+	if result.Vbar.Vbool != true {
+		t.Errorf("vbool value should be true: %#v", result.Vbar.Vbool)
+	}
+
+	if result.Vbar.Vextra != "" {
+		t.Errorf("vextra value should be empty: %#v", result.Vbar.Vextra)
+	}
+}
+
+func TestNestedTypePointer(t *testing.T) {
+	t.Parallel()
+
+	input := map[string]interface{}{
+		"vfoo": "foo",
+		"vbar": &map[string]interface{}{
+			"vstring": "foo",
+			"vint":    42,
+			"vbool":   true,
+		},
 	}
 
 	var result NestedPointer
@@ -1731,6 +2257,207 @@ func TestSliceOfStruct(t *testing.T) {
 	}
 }
 
+func TestSliceMergeKey(t *testing.T) {
+	t.Parallel()
+
+	type Backend struct {
+		Name    string `mapstructure:"name"`
+		Port    int    `mapstructure:"port"`
+		Enabled bool   `mapstructure:"enabled"`
+	}
+	type Config struct {
+		Backends []Backend  `mapstructure:"backends,mergekey=name"`
+		Mirrors  []*Backend `mapstructure:"mirrors"`
+	}
+
+	result := Config{
+		Backends: []Backend{
+			{Name: "a", Port: 80, Enabled: true},
+			{Name: "b", Port: 81, Enabled: true},
+		},
+		Mirrors: []*Backend{{Name: "a", Port: 80}},
+	}
+
+	input := map[string]interface{}{
+		"backends": []map[string]interface{}{
+			{"name": "b", "port": 8081},
+			{"name": "c", "port": 82},
+		},
+		"mirrors": []map[string]interface{}{
+			{"name": "b", "port": 81},
+		},
+	}
+
+	if err := Decode(input, &result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []Backend{
+		{Name: "a", Port: 80, Enabled: true},
+		{Name: "b", Port: 8081, Enabled: true},
+		{Name: "c", Port: 82},
+	}
+	if !reflect.DeepEqual(result.Backends, expected) {
+		t.Fatalf("bad: %#v", result.Backends)
+	}
+
+	// Without a merge key the mirrors are replaced by position.
+	if len(result.Mirrors) != 1 || result.Mirrors[0].Name != "b" {
+		t.Fatalf("bad: %#v", result.Mirrors)
+	}
+}
+
+func TestSliceMergeKey_config(t *testing.T) {
+	t.Parallel()
+
+	type Backend struct {
+		ID   int
+		Host string
+	}
+
+	result := []*Backend{{ID: 1, Host: "a"}, {ID: 2, Host: "b"}}
+	decoder, err := NewDecoder(&DecoderConfig{
+		SliceMergeKey:    "id",
+		WeaklyTypedInput: true,
+		Result:           &result,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	err = decoder.Decode([]interface{}{
+		map[string]interface{}{"id": "2", "host": "c"},
+		map[string]interface{}{"id": 3, "host": "d"},
+		map[string]interface{}{"id": 3, "host": "e"},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []*Backend{{ID: 1, Host: "a"}, {ID: 2, Host: "c"}, {ID: 3, Host: "e"}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+}
+
+func TestSliceMergeKey_nested(t *testing.T) {
+	t.Parallel()
+
+	type Route struct {
+		Name string `mapstructure:"name"`
+		Path string `mapstructure:"path"`
+	}
+	type Backend struct {
+		Name   string  `mapstructure:"name"`
+		Routes []Route `mapstructure:"routes"`
+	}
+	type Config struct {
+		Backends []Backend `mapstructure:"backends,mergekey=name"`
+	}
+
+	result := Config{
+		Backends: []Backend{
+			{Name: "a", Routes: []Route{{Name: "x", Path: "/x"}, {Name: "y", Path: "/y"}}},
+		},
+	}
+
+	input := map[string]interface{}{
+		"backends": []map[string]interface{}{
+			{"name": "a", "routes": []map[string]interface{}{{"name": "y"}}},
+		},
+	}
+
+	if err := Decode(input, &result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The routes have no merge key of their own, so they are replaced by
+	// position.
+	expected := []Backend{{Name: "a", Routes: []Route{{Name: "y", Path: "/x"}}}}
+	if !reflect.DeepEqual(result.Backends, expected) {
+		t.Fatalf("bad: %#v", result.Backends)
+	}
+}
+
+func TestSliceMergeKey_uncomparable(t *testing.T) {
+	t.Parallel()
+
+	type Backend struct {
+		ID   interface{} `mapstructure:"id"`
+		Host string      `mapstructure:"host"`
+	}
+	type Config struct {
+		Backends []Backend `mapstructure:"backends,mergekey=id"`
+	}
+
+	result := Config{Backends: []Backend{{ID: "a", Host: "a"}}}
+	err := Decode(map[string]interface{}{
+		"backends": []map[string]interface{}{
+			{"id": []string{"b"}, "host": "b"},
+		},
+	}, &result)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	var decodingErr *DecodingError
+	if !errors.As(err, &decodingErr) || decodingErr.Kind != DecodingErrorUnsupportedType {
+		t.Fatalf("bad: %#v", err)
+	}
+	if expected := "'backends[0]' has merge key '[b]' of type '[]string', which can't be compared"; !strings.Contains(err.Error(), expected) {
+		t.Fatalf("expected %q in: %s", expected, err)
+	}
+}
+
+func TestSingleValueToSlice(t *testing.T) {
+	t.Parallel()
+
+	type Backend struct {
+		Host string
+	}
+	type Config struct {
+		Hosts    []string
+		Ports    [2]int
+		Backends []Backend
+		Names    []string
+	}
+
+	input := map[string]interface{}{
+		"hosts":    "a",
+		"ports":    80,
+		"backends": map[string]interface{}{"host": "b"},
+		"names":    []string{"c", "d"},
+	}
+
+	var result Config
+	decoder, err := NewDecoder(&DecoderConfig{
+		SingleValueToSlice: true,
+		Result:             &result,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := decoder.Decode(input); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := Config{
+		Hosts:    []string{"a"},
+		Ports:    [2]int{80},
+		Backends: []Backend{{Host: "b"}},
+		Names:    []string{"c", "d"},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	// Other weak conversions stay disabled
+	err = decoder.Decode(map[string]interface{}{"hosts": 1})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
+
 func TestSliceCornerCases(t *testing.T) {
 	t.Parallel()
 
@@ -1826,66 +2553,193 @@ func TestInvalidArray(t *testing.T) {
 	}
 }
 
-func TestArrayOfStruct(t *testing.T) {
+func TestArrayFill(t *testing.T) {
 	t.Parallel()
 
 	input := map[string]interface{}{
-		"value": []map[string]interface{}{
-			{"vstring": "one"},
-			{"vstring": "two"},
-		},
+		"ports": []int{80, 443},
 	}
 
-	var result ArrayOfStruct
-	err := Decode(input, &result)
-	if err != nil {
-		t.Fatalf("got unexpected error: %s", err)
+	type Listener struct {
+		Ports [4]int
 	}
 
-	if len(result.Value) != 2 {
-		t.Fatalf("expected two values, got %d", len(result.Value))
-	}
+	cases := []struct {
+		fill     ArrayFillPolicy
+		current  [4]int
+		expected [4]int
+		err      bool
+	}{
+		{ArrayFillDefault, [4]int{}, [4]int{80, 443}, false},
+		{ArrayFillDefault, [4]int{1, 2, 3, 4}, [4]int{80, 443, 3, 4}, false},
+		{ArrayFillZero, [4]int{1, 2, 3, 4}, [4]int{80, 443}, false},
+		{ArrayFillKeep, [4]int{1, 2, 3, 4}, [4]int{80, 443, 3, 4}, false},
+		{ArrayFillError, [4]int{1, 2, 3, 4}, [4]int{1, 2, 3, 4}, true},
+	}
+
+	for _, tc := range cases {
+		result := Listener{Ports: tc.current}
+		decoder, err := NewDecoder(&DecoderConfig{
+			ArrayFill: tc.fill,
+			Result:    &result,
+		})
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
 
-	if result.Value[0].Vstring != "one" {
-		t.Errorf("first value should be 'one', got: %s", result.Value[0].Vstring)
-	}
+		err = decoder.Decode(input)
+		if tc.err {
+			var derr *DecodingError
+			if !errors.As(err, &derr) || derr.Kind != DecodingErrorInvalidLength {
+				t.Fatalf("%d: expected length error, got %v", tc.fill, err)
+			}
+		} else if err != nil {
+			t.Fatalf("%d: err: %s", tc.fill, err)
+		}
 
-	if result.Value[1].Vstring != "two" {
-		t.Errorf("second value should be 'two', got: %s", result.Value[1].Vstring)
+		if result.Ports != tc.expected {
+			t.Fatalf("%d: bad: %#v", tc.fill, result.Ports)
+		}
 	}
 }
 
-func TestArrayToMap(t *testing.T) {
+func TestArrayNonZeroInvalidInput(t *testing.T) {
 	t.Parallel()
 
-	input := []map[string]interface{}{
-		{
-			"foo": "bar",
-		},
-		{
-			"bar": "baz",
-		},
-	}
-
-	var result map[string]interface{}
-	err := WeakDecode(input, &result)
-	if err != nil {
-		t.Fatalf("got an error: %s", err)
+	result := Array{Vbar: [2]string{"a", "b"}}
+	err := Decode(map[string]interface{}{"vbar": 42}, &result)
+	if err == nil {
+		t.Fatal("expected failure")
 	}
 
-	expected := map[string]interface{}{
-		"foo": "bar",
-		"bar": "baz",
-	}
-	if !reflect.DeepEqual(result, expected) {
-		t.Errorf("bad: %#v", result)
+	err = Decode(map[string]interface{}{"vbar": []string{"a", "b", "c"}}, &result)
+	if err == nil {
+		t.Fatal("expected failure")
 	}
 }
 
-func TestDecodeTable(t *testing.T) {
+func TestArrayExactLength(t *testing.T) {
 	t.Parallel()
 
-	// We need to make new types so that we don't get the short-circuit
+	type Frame struct {
+		MAC     [6]byte `mapstructure:"mac,exactlength"`
+		Padding [4]byte `mapstructure:"padding"`
+	}
+
+	var result Frame
+	err := Decode(map[string]interface{}{
+		"mac":     []byte{1, 2, 3, 4, 5, 6},
+		"padding": []byte{1},
+	}, &result)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if result.MAC != [6]byte{1, 2, 3, 4, 5, 6} || result.Padding != [4]byte{1} {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	err = Decode(map[string]interface{}{
+		"mac": []byte{1, 2, 3},
+	}, &result)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if !strings.Contains(err.Error(), "'mac': expected source data to have length 6, got 3") {
+		t.Fatalf("bad: %s", err)
+	}
+
+	err = Decode(map[string]interface{}{
+		"mac": []byte{1, 2, 3, 4, 5, 6, 7},
+	}, &result)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	// The option applies to the array of the field, not to the arrays
+	// nested in it.
+	type Routes struct {
+		Hops *[2][4]byte `mapstructure:"hops,exactlength"`
+	}
+
+	var routes Routes
+	err = Decode(map[string]interface{}{
+		"hops": [][]byte{{10, 0, 0, 1}, {10}},
+	}, &routes)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if *routes.Hops != [2][4]byte{{10, 0, 0, 1}, {10}} {
+		t.Fatalf("bad: %#v", routes.Hops)
+	}
+
+	err = Decode(map[string]interface{}{
+		"hops": [][]byte{{10, 0, 0, 1}},
+	}, &routes)
+	if err == nil || !strings.Contains(err.Error(), "'hops': expected source data to have length 2, got 1") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestArrayOfStruct(t *testing.T) {
+	t.Parallel()
+
+	input := map[string]interface{}{
+		"value": []map[string]interface{}{
+			{"vstring": "one"},
+			{"vstring": "two"},
+		},
+	}
+
+	var result ArrayOfStruct
+	err := Decode(input, &result)
+	if err != nil {
+		t.Fatalf("got unexpected error: %s", err)
+	}
+
+	if len(result.Value) != 2 {
+		t.Fatalf("expected two values, got %d", len(result.Value))
+	}
+
+	if result.Value[0].Vstring != "one" {
+		t.Errorf("first value should be 'one', got: %s", result.Value[0].Vstring)
+	}
+
+	if result.Value[1].Vstring != "two" {
+		t.Errorf("second value should be 'two', got: %s", result.Value[1].Vstring)
+	}
+}
+
+func TestArrayToMap(t *testing.T) {
+	t.Parallel()
+
+	input := []map[string]interface{}{
+		{
+			"foo": "bar",
+		},
+		{
+			"bar": "baz",
+		},
+	}
+
+	var result map[string]interface{}
+	err := WeakDecode(input, &result)
+	if err != nil {
+		t.Fatalf("got an error: %s", err)
+	}
+
+	expected := map[string]interface{}{
+		"foo": "bar",
+		"bar": "baz",
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("bad: %#v", result)
+	}
+}
+
+func TestDecodeTable(t *testing.T) {
+	t.Parallel()
+
+	// We need to make new types so that we don't get the short-circuit
 	// copy functionality. We want to test the deep copying functionality.
 	type BasicCopy Basic
 	type NestedPointerCopy NestedPointer
@@ -2320,6 +3174,55 @@ func TestInvalidType(t *testing.T) {
 	}
 }
 
+func TestDecodeTo(t *testing.T) {
+	t.Parallel()
+
+	input := map[string]interface{}{
+		"vstring": "foo",
+		"vint":    "42",
+	}
+
+	result, err := DecodeTo[Basic](input, func(c *DecoderConfig) {
+		c.WeaklyTypedInput = true
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if result.Vstring != "foo" || result.Vint != 42 {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	// The Result set by an option is ignored.
+	var other Basic
+	ptr, err := DecodeTo[*Basic](input, func(c *DecoderConfig) {
+		c.Result = &other
+		c.WeaklyTypedInput = true
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if ptr == nil || ptr.Vint != 42 || other.Vint != 0 {
+		t.Fatalf("bad: %#v, %#v", ptr, other)
+	}
+
+	m, err := DecodeTo[map[string]string](input)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(m, map[string]string{"vstring": "foo", "vint": "42"}) {
+		t.Fatalf("bad: %#v", m)
+	}
+
+	// A failed decode returns the zero value.
+	result, err = DecodeTo[Basic](input)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if !reflect.DeepEqual(result, Basic{}) {
+		t.Fatalf("expected the zero value, got %#v", result)
+	}
+}
+
 func TestDecodeMetadata(t *testing.T) {
 	t.Parallel()
 
@@ -2510,170 +3413,2380 @@ func TestWeakDecode(t *testing.T) {
 	}
 }
 
-func TestWeakDecodeMetadata(t *testing.T) {
+func TestWeakDecode_duration(t *testing.T) {
 	t.Parallel()
 
 	input := map[string]interface{}{
-		"foo":        "4",
-		"bar":        "value",
-		"unused":     "value",
-		"unexported": "value",
+		"timeout":  "5s",
+		"interval": "1500",
+		"retry":    "",
 	}
 
-	var md Metadata
 	var result struct {
-		Foo        int
-		Bar        string
-		unexported string
+		Timeout  time.Duration
+		Interval time.Duration
+		Retry    time.Duration
 	}
 
-	if err := WeakDecodeMetadata(input, &result, &md); err != nil {
+	if err := WeakDecode(input, &result); err != nil {
 		t.Fatalf("err: %s", err)
 	}
-	if result.Foo != 4 {
-		t.Fatalf("bad: %#v", result)
-	}
-	if result.Bar != "value" {
+	if result.Timeout != 5*time.Second || result.Interval != 1500 || result.Retry != 0 {
 		t.Fatalf("bad: %#v", result)
 	}
 
-	expectedKeys := []string{"Bar", "Foo"}
-	sort.Strings(md.Keys)
-	if !reflect.DeepEqual(md.Keys, expectedKeys) {
-		t.Fatalf("bad keys: %#v", md.Keys)
+	input["timeout"] = "5 seconds"
+	err := WeakDecode(input, &result)
+	var derr *DecodingError
+	if !errors.As(err, &derr) || derr.Kind != DecodingErrorParseFailure {
+		t.Fatalf("bad err: %#v", err)
 	}
 
-	expectedUnused := []string{"unexported", "unused"}
-	sort.Strings(md.Unused)
-	if !reflect.DeepEqual(md.Unused, expectedUnused) {
-		t.Fatalf("bad unused: %#v", md.Unused)
+	// Without WeaklyTypedInput strings are still not converted.
+	input["timeout"] = "5s"
+	if err := Decode(input, &result); err == nil {
+		t.Fatal("expected error")
 	}
 }
 
-func TestDecode_StructTaggedWithOmitempty_OmitEmptyValues(t *testing.T) {
+func TestWeakDecode_intBase(t *testing.T) {
 	t.Parallel()
 
-	input := &StructWithOmitEmpty{}
+	input := map[string]interface{}{
+		"id":   "0755",
+		"mode": "0755",
+		"ids":  "007,010",
+	}
 
-	var emptySlice []interface{}
-	var emptyMap map[string]interface{}
-	var emptyNested *Nested
-	expected := &map[string]interface{}{
-		"visible-string": "",
-		"visible-int":    0,
-		"visible-float":  0.0,
-		"visible-slice":  emptySlice,
-		"visible-map":    emptyMap,
-		"visible-nested": emptyNested,
+	var result struct {
+		ID   int
+		Mode uint32
+		IDs  []int
 	}
 
-	actual := &map[string]interface{}{}
-	Decode(input, actual)
+	if err := WeakDecode(input, &result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if result.ID != 493 || result.Mode != 493 || !reflect.DeepEqual(result.IDs, []int{7, 8}) {
+		t.Fatalf("bad: %#v", result)
+	}
 
-	if !reflect.DeepEqual(actual, expected) {
-		t.Fatalf("Decode() expected: %#v, got: %#v", expected, actual)
+	decoder, err := NewDecoder(&DecoderConfig{
+		WeaklyTypedInput: true,
+		WeakIntBase:      10,
+		Result:           &result,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := decoder.Decode(input); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if result.ID != 755 || result.Mode != 755 || !reflect.DeepEqual(result.IDs, []int{7, 10}) {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	input["id"] = "0x1f"
+	if err := decoder.Decode(input); err == nil {
+		t.Fatal("expected error")
+	}
+
+	if _, err := NewDecoder(&DecoderConfig{WeakIntBase: 1, Result: &result}); err == nil {
+		t.Fatal("expected error for an invalid base")
 	}
 }
 
-func TestDecode_StructTaggedWithOmitempty_KeepNonEmptyValues(t *testing.T) {
+func TestWeakDecode_bytes(t *testing.T) {
 	t.Parallel()
 
-	input := &StructWithOmitEmpty{
-		VisibleStringField: "",
-		OmitStringField:    "string",
-		VisibleIntField:    0,
-		OmitIntField:       1,
-		VisibleFloatField:  0.0,
-		OmitFloatField:     1.0,
-		VisibleSliceField:  nil,
-		OmitSliceField:     []interface{}{1},
-		VisibleMapField:    nil,
-		OmitMapField:       map[string]interface{}{"k": "v"},
-		NestedField:        nil,
-		OmitNestedField:    &Nested{},
+	input := map[string]interface{}{
+		"id":      []byte("42"),
+		"count":   []byte("0x10"),
+		"ratio":   []byte("0.5"),
+		"enabled": []byte("true"),
+		"name":    []byte("web"),
+		"empty":   []byte{},
 	}
 
-	var emptySlice []interface{}
-	var emptyMap map[string]interface{}
-	var emptyNested *Nested
-	expected := &map[string]interface{}{
-		"visible-string":   "",
-		"omittable-string": "string",
-		"visible-int":      0,
-		"omittable-int":    1,
-		"visible-float":    0.0,
-		"omittable-float":  1.0,
-		"visible-slice":    emptySlice,
-		"omittable-slice":  []interface{}{1},
-		"visible-map":      emptyMap,
-		"omittable-map":    map[string]interface{}{"k": "v"},
-		"visible-nested":   emptyNested,
-		"omittable-nested": &Nested{},
+	var result struct {
+		ID      int
+		Count   uint
+		Ratio   float64
+		Enabled bool
+		Name    string
+		Empty   int
 	}
 
-	actual := &map[string]interface{}{}
-	Decode(input, actual)
+	if err := WeakDecode(input, &result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if result.ID != 42 || result.Count != 16 || result.Ratio != 0.5 || !result.Enabled || result.Name != "web" || result.Empty != 0 {
+		t.Fatalf("bad: %#v", result)
+	}
 
-	if !reflect.DeepEqual(actual, expected) {
-		t.Fatalf("Decode() expected: %#v, got: %#v", expected, actual)
+	input["id"] = []byte("forty-two")
+	err := WeakDecode(input, &result)
+	var derr *DecodingError
+	if !errors.As(err, &derr) || derr.Kind != DecodingErrorParseFailure {
+		t.Fatalf("bad err: %#v", err)
 	}
-}
 
-func TestDecode_mapToStruct(t *testing.T) {
-	type Target struct {
-		String    string
-		StringPtr *string
+	// Without WeaklyTypedInput []byte is still not converted.
+	input["id"] = []byte("42")
+	if err := Decode(input, &result); err == nil {
+		t.Fatal("expected error")
 	}
+}
 
-	expected := Target{
-		String: "hello",
+func TestDecoder_DecodeContext(t *testing.T) {
+	t.Parallel()
+
+	input := make([]interface{}, 1000)
+	for i := range input {
+		input[i] = map[string]interface{}{"vint": i}
 	}
 
-	var target Target
-	err := Decode(map[string]interface{}{
-		"string":    "hello",
-		"StringPtr": "goodbye",
-	}, &target)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The hook cancels the context while the slice is decoded.
+	calls := 0
+	var result []Basic
+	decoder, err := NewDecoder(&DecoderConfig{
+		DecodeHook: func(_, _ reflect.Type, data interface{}) (interface{}, error) {
+			if calls++; calls == 100 {
+				cancel()
+			}
+			return data, nil
+		},
+		Result: &result,
+	})
 	if err != nil {
-		t.Fatalf("got error: %s", err)
+		t.Fatalf("err: %s", err)
 	}
 
-	// Pointers fail reflect test so do those manually
-	if target.StringPtr == nil || *target.StringPtr != "goodbye" {
-		t.Fatalf("bad: %#v", target)
+	if err := decoder.DecodeContext(ctx, input); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if calls >= 1000 {
+		t.Fatalf("expected decoding to stop early, the hook was called %d times", calls)
 	}
-	target.StringPtr = nil
 
-	if !reflect.DeepEqual(target, expected) {
-		t.Fatalf("bad: %#v", target)
+	// A context that is done already decodes nothing.
+	result = nil
+	if err := decoder.DecodeContext(ctx, input); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if result != nil {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	if err := decoder.DecodeContext(context.Background(), input); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(result) != 1000 || result[999].Vint != 999 {
+		t.Fatalf("bad: %d", len(result))
 	}
 }
 
-func TestDecoder_MatchName(t *testing.T) {
+func TestDecoder_Atomic(t *testing.T) {
 	t.Parallel()
 
-	type Target struct {
-		FirstMatch  string `mapstructure:"first_match"`
+	type Config struct {
+		Name   string
+		Port   int
+		Labels map[string]string
+	}
+
+	live := Config{Name: "web", Port: 80, Labels: map[string]string{"app": "web"}}
+	decoder, err := NewDecoder(&DecoderConfig{
+		Atomic: true,
+		Result: &live,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	input := map[string]interface{}{
+		"name":   "api",
+		"labels": map[string]interface{}{"tier": "backend"},
+		"port":   "not a port",
+	}
+	if err := decoder.Decode(input); err == nil {
+		t.Fatal("expected error")
+	}
+
+	expected := Config{Name: "web", Port: 80, Labels: map[string]string{"app": "web"}}
+	if !reflect.DeepEqual(live, expected) {
+		t.Fatalf("result changed on failure: %#v", live)
+	}
+
+	input["port"] = 8080
+	if err := decoder.Decode(input); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected = Config{Name: "api", Port: 8080, Labels: map[string]string{"app": "web", "tier": "backend"}}
+	if !reflect.DeepEqual(live, expected) {
+		t.Fatalf("bad: %#v", live)
+	}
+}
+
+func TestDecoder_EmptyStringAsZero(t *testing.T) {
+	t.Parallel()
+
+	type Row struct {
+		Name    string
+		Created time.Time
+		Updated time.Time
+		Count   *int
+		Note    *string
+	}
+
+	input := map[string]interface{}{
+		"name":    "",
+		"created": "",
+		"updated": "2024-01-02T03:04:05Z",
+		"count":   "",
+		"note":    "",
+	}
+
+	count := 3
+	result := Row{Created: time.Now(), Count: &count}
+	decoder, err := NewDecoder(&DecoderConfig{
+		DecodeHook:        StringToTimeHookFunc(time.RFC3339),
+		EmptyStringAsZero: true,
+		Result:            &result,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := decoder.Decode(input); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := Row{Updated: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	// Without EmptyStringAsZero the hook fails on "".
+	decoder, err = NewDecoder(&DecoderConfig{
+		DecodeHook: StringToTimeHookFunc(time.RFC3339),
+		Result:     &result,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := decoder.Decode(input); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestDecoder_StringBytes(t *testing.T) {
+	t.Parallel()
+
+	input := map[string]interface{}{
+		"data":  "payload",
+		"name":  []byte("web"),
+		"hash":  [4]byte{'a', 'b', 'c', 'd'},
+		"count": "42",
+	}
+
+	var result struct {
+		Data  []byte
+		Name  string
+		Hash  string
+		Count int
+	}
+
+	var md Metadata
+	decoder, err := NewDecoder(&DecoderConfig{
+		StringBytes: true,
+		Metadata:    &md,
+		Result:      &result,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Other weak conversions are still errors.
+	if err := decoder.Decode(input); err == nil || !strings.Contains(err.Error(), "'Count'") {
+		t.Fatalf("expected an error for Count, got %v", err)
+	}
+
+	if string(result.Data) != "payload" || result.Name != "web" || result.Hash != "abcd" {
+		t.Fatalf("bad: %#v", result)
+	}
+	if len(md.Weak) != 0 {
+		t.Fatalf("expected no weak conversions, got %#v", md.Weak)
+	}
+}
+
+func TestDecoder_WeakStringInput(t *testing.T) {
+	t.Parallel()
+
+	type Server struct {
+		Port    int
+		Debug   bool
+		Timeout time.Duration
+	}
+	type Config struct {
+		Env    Server
+		Typed  Server
+		Ports  []int
+		Labels map[string]string
+	}
+
+	input := map[string]interface{}{
+		"env":    map[string]string{"port": "8080", "debug": "true", "timeout": "5s"},
+		"typed":  map[string]interface{}{"port": 80, "debug": "true"},
+		"ports":  []interface{}{"1", "2"},
+		"labels": map[string]interface{}{"app": "web"},
+	}
+
+	var result Config
+	decoder, err := NewDecoder(&DecoderConfig{
+		WeakStringInput: true,
+		Result:          &result,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The map with a real int is checked strictly.
+	err = decoder.Decode(input)
+	if err == nil || !strings.Contains(err.Error(), "'Typed.Debug'") {
+		t.Fatalf("expected an error for Typed.Debug, got %v", err)
+	}
+	if strings.Count(err.Error(), "* ") != 1 {
+		t.Fatalf("expected a single error: %s", err)
+	}
+
+	expected := Config{
+		Env:    Server{Port: 8080, Debug: true, Timeout: 5 * time.Second},
+		Typed:  Server{Port: 80},
+		Ports:  []int{1, 2},
+		Labels: map[string]string{"app": "web"},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+}
+
+func TestIsStringContainer_cache(t *testing.T) {
+	t.Parallel()
+
+	leaf := map[string]interface{}{"n": 1}
+	mid := []interface{}{map[string]interface{}{"a": "b"}, leaf}
+	root := map[string]interface{}{"mid": mid}
+
+	d := &Decoder{stringContainers: make(map[containerID]bool)}
+	if d.isStringContainer(reflect.ValueOf(root)) {
+		t.Fatal("expected root not to be a string container")
+	}
+
+	// The nested containers were checked along with the root.
+	for _, v := range []interface{}{mid, leaf} {
+		val := reflect.ValueOf(v)
+		ok, cached := d.stringContainers[containerID{val.Type(), val.Pointer(), val.Len()}]
+		if !cached || ok {
+			t.Fatalf("%#v: expected a cached false, got %v, %v", v, ok, cached)
+		}
+	}
+}
+
+func TestWeakDecode_separatedNumbers(t *testing.T) {
+	t.Parallel()
+
+	input := map[string]interface{}{
+		"ports":   "80, 443,8080",
+		"weights": "0.5;1.5",
+		"mask":    "255,255,0",
+		"names":   "a,b",
+	}
+
+	var result struct {
+		Ports   []int
+		Weights []float64
+		Mask    [4]uint8
+		Names   []string
+	}
+
+	config := &DecoderConfig{
+		WeaklyTypedInput: true,
+		Result:           &result,
+	}
+
+	decoder, err := NewDecoder(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The weights use another separator
+	if err := decoder.Decode(input); err == nil {
+		t.Fatal("expected error")
+	}
+
+	delete(input, "weights")
+	if err := decoder.Decode(input); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(result.Ports, []int{80, 443, 8080}) {
+		t.Fatalf("bad: %#v", result.Ports)
+	}
+	if result.Mask != [4]uint8{255, 255, 0, 0} {
+		t.Fatalf("bad: %#v", result.Mask)
+	}
+	// Strings aren't split
+	if !reflect.DeepEqual(result.Names, []string{"a,b"}) {
+		t.Fatalf("bad: %#v", result.Names)
+	}
+
+	config.WeakSliceSeparator = ";"
+	decoder, err = NewDecoder(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := decoder.Decode(map[string]interface{}{"weights": "0.5;1.5"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(result.Weights, []float64{0.5, 1.5}) {
+		t.Fatalf("bad: %#v", result.Weights)
+	}
+}
+
+func TestWeakDecode_disableEmptyMapSlice(t *testing.T) {
+	t.Parallel()
+
+	type Config struct {
+		Port   int
+		Hosts  []string
+		Ports  [2]int
+		Labels map[string]string
+	}
+
+	decode := func(input map[string]interface{}, disable bool) (Config, error) {
+		var result Config
+		decoder, err := NewDecoder(&DecoderConfig{
+			WeaklyTypedInput:         true,
+			DisableWeakEmptyMapSlice: disable,
+			Result:                   &result,
+		})
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		return result, decoder.Decode(input)
+	}
+
+	input := map[string]interface{}{
+		"port":   "80",
+		"hosts":  map[string]interface{}{},
+		"ports":  map[string]interface{}{},
+		"labels": []interface{}{},
+	}
+	result, err := decode(input, false)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if result.Port != 80 || result.Hosts == nil || result.Labels == nil {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	for _, key := range []string{"hosts", "ports", "labels"} {
+		_, err := decode(map[string]interface{}{"port": "80", key: input[key]}, true)
+		if err == nil {
+			t.Fatalf("%s: expected error", key)
+		}
+	}
+
+	result, err = decode(map[string]interface{}{"port": "80"}, true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if result.Port != 80 {
+		t.Fatalf("bad: %#v", result)
+	}
+}
+
+func TestWeakDecodeMetadata(t *testing.T) {
+	t.Parallel()
+
+	input := map[string]interface{}{
+		"foo":        "4",
+		"bar":        "value",
+		"unused":     "value",
+		"unexported": "value",
+	}
+
+	var md Metadata
+	var result struct {
+		Foo        int
+		Bar        string
+		unexported string
+	}
+
+	if err := WeakDecodeMetadata(input, &result, &md); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if result.Foo != 4 {
+		t.Fatalf("bad: %#v", result)
+	}
+	if result.Bar != "value" {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	expectedKeys := []string{"Bar", "Foo"}
+	sort.Strings(md.Keys)
+	if !reflect.DeepEqual(md.Keys, expectedKeys) {
+		t.Fatalf("bad keys: %#v", md.Keys)
+	}
+
+	expectedUnused := []string{"unexported", "unused"}
+	sort.Strings(md.Unused)
+	if !reflect.DeepEqual(md.Unused, expectedUnused) {
+		t.Fatalf("bad unused: %#v", md.Unused)
+	}
+}
+
+func TestWeakDecodeMetadata_weak(t *testing.T) {
+	t.Parallel()
+
+	input := map[string]interface{}{
+		"count":   "42",
+		"enabled": 1,
+		"name":    true,
+		"tags":    "a",
+		"ports":   "80,443",
+		"ratio":   0.5,
+	}
+
+	var md Metadata
+	var result struct {
+		Count   int
+		Enabled bool
+		Name    string
+		Tags    []string
+		Ports   []uint16
+		Ratio   float64
+	}
+
+	if err := WeakDecodeMetadata(input, &result, &md); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	sort.Slice(md.Weak, func(i, j int) bool {
+		return md.Weak[i].Name < md.Weak[j].Name
+	})
+	expected := []WeakConversion{
+		{Name: "Count", From: "string", To: "int"},
+		{Name: "Enabled", From: "int", To: "bool"},
+		{Name: "Name", From: "bool", To: "string"},
+		{Name: "Ports", From: "string", To: "[]uint16"},
+		{Name: "Ports[0]", From: "string", To: "uint16"},
+		{Name: "Ports[1]", From: "string", To: "uint16"},
+		{Name: "Tags", From: "string", To: "[]string"},
+	}
+	if !reflect.DeepEqual(md.Weak, expected) {
+		t.Fatalf("bad weak: %#v", md.Weak)
+	}
+}
+
+func TestDecode_StructTaggedWithOmitempty_OmitEmptyValues(t *testing.T) {
+	t.Parallel()
+
+	input := &StructWithOmitEmpty{}
+
+	var emptySlice []interface{}
+	var emptyMap map[string]interface{}
+	var emptyNested *Nested
+	expected := &map[string]interface{}{
+		"visible-string": "",
+		"visible-int":    0,
+		"visible-float":  0.0,
+		"visible-slice":  emptySlice,
+		"visible-map":    emptyMap,
+		"visible-nested": emptyNested,
+	}
+
+	actual := &map[string]interface{}{}
+	Decode(input, actual)
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("Decode() expected: %#v, got: %#v", expected, actual)
+	}
+}
+
+func TestDecode_StructTaggedWithOmitempty_KeepNonEmptyValues(t *testing.T) {
+	t.Parallel()
+
+	input := &StructWithOmitEmpty{
+		VisibleStringField: "",
+		OmitStringField:    "string",
+		VisibleIntField:    0,
+		OmitIntField:       1,
+		VisibleFloatField:  0.0,
+		OmitFloatField:     1.0,
+		VisibleSliceField:  nil,
+		OmitSliceField:     []interface{}{1},
+		VisibleMapField:    nil,
+		OmitMapField:       map[string]interface{}{"k": "v"},
+		NestedField:        nil,
+		OmitNestedField:    &Nested{},
+	}
+
+	var emptySlice []interface{}
+	var emptyMap map[string]interface{}
+	var emptyNested *Nested
+	expected := &map[string]interface{}{
+		"visible-string":   "",
+		"omittable-string": "string",
+		"visible-int":      0,
+		"omittable-int":    1,
+		"visible-float":    0.0,
+		"omittable-float":  1.0,
+		"visible-slice":    emptySlice,
+		"omittable-slice":  []interface{}{1},
+		"visible-map":      emptyMap,
+		"omittable-map":    map[string]interface{}{"k": "v"},
+		"visible-nested":   emptyNested,
+		"omittable-nested": &Nested{},
+	}
+
+	actual := &map[string]interface{}{}
+	Decode(input, actual)
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("Decode() expected: %#v, got: %#v", expected, actual)
+	}
+}
+
+func TestDecode_mapToStruct(t *testing.T) {
+	type Target struct {
+		String    string
+		StringPtr *string
+	}
+
+	expected := Target{
+		String: "hello",
+	}
+
+	var target Target
+	err := Decode(map[string]interface{}{
+		"string":    "hello",
+		"StringPtr": "goodbye",
+	}, &target)
+	if err != nil {
+		t.Fatalf("got error: %s", err)
+	}
+
+	// Pointers fail reflect test so do those manually
+	if target.StringPtr == nil || *target.StringPtr != "goodbye" {
+		t.Fatalf("bad: %#v", target)
+	}
+	target.StringPtr = nil
+
+	if !reflect.DeepEqual(target, expected) {
+		t.Fatalf("bad: %#v", target)
+	}
+}
+
+func TestDecoder_MatchName(t *testing.T) {
+	t.Parallel()
+
+	type Target struct {
+		FirstMatch  string `mapstructure:"first_match"`
 		SecondMatch string
 		NoMatch     string `mapstructure:"no_match"`
 	}
 
 	input := map[string]interface{}{
-		"first_match": "foo",
-		"SecondMatch": "bar",
-		"NO_MATCH":    "baz",
+		"first_match": "foo",
+		"SecondMatch": "bar",
+		"NO_MATCH":    "baz",
+	}
+
+	expected := Target{
+		FirstMatch:  "foo",
+		SecondMatch: "bar",
+	}
+
+	var actual Target
+	config := &DecoderConfig{
+		Result: &actual,
+		MatchName: func(mapKey, fieldName string) bool {
+			return mapKey == fieldName
+		},
+	}
+
+	decoder, err := NewDecoder(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	err = decoder.Decode(input)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("Decode() expected: %#v, got: %#v", expected, actual)
+	}
+}
+
+func TestDecoder_IgnoreUntaggedFields(t *testing.T) {
+	type Input struct {
+		UntaggedNumber int
+		TaggedNumber   int `mapstructure:"tagged_number"`
+		UntaggedString string
+		TaggedString   string `mapstructure:"tagged_string"`
+	}
+	input := &Input{
+		UntaggedNumber: 31,
+		TaggedNumber:   42,
+		UntaggedString: "hidden",
+		TaggedString:   "visible",
+	}
+
+	actual := make(map[string]interface{})
+	config := &DecoderConfig{
+		Result:               &actual,
+		IgnoreUntaggedFields: true,
+	}
+
+	decoder, err := NewDecoder(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	err = decoder.Decode(input)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[string]interface{}{
+		"tagged_number": 42,
+		"tagged_string": "visible",
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("Decode() expected: %#v\ngot: %#v", expected, actual)
+	}
+}
+
+func TestDecoder_ErrorMessages(t *testing.T) {
+	t.Parallel()
+
+	input := map[string]interface{}{
+		"vstring": []int{1},
+		"vint":    "nope",
+	}
+
+	var result Basic
+	config := &DecoderConfig{
+		Result:           &result,
+		WeaklyTypedInput: true,
+		ErrorMessages: map[MessageID]string{
+			MsgUnconvertibleType: "{name}: erwartet {expected}, erhalten {got}",
+		},
+		TranslateErrorMessage: func(kind DecodingErrorKind, template string) string {
+			if kind == DecodingErrorParseFailure {
+				return "{name}: kann {value} nicht als {expected} lesen"
+			}
+			return template
+		},
+	}
+
+	decoder, err := NewDecoder(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	err = decoder.Decode(input)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	expected := []string{
+		"Vstring: erwartet string, erhalten []int",
+		"Vint: kann nope nicht als int lesen",
+	}
+	if actual := err.(*Error).Errors; !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("expected: %#v\ngot: %#v", expected, actual)
+	}
+}
+
+// This test changes a default template, so it doesn't run in parallel.
+func TestDecoder_ErrorMessagesChangedTemplate(t *testing.T) {
+	old := defaultMessages[MsgUnconvertibleType]
+	defaultMessages[MsgUnconvertibleType] = "'{name}' can't be converted to {expected}"
+	defer func() { defaultMessages[MsgUnconvertibleType] = old }()
+
+	var result Basic
+	config := &DecoderConfig{
+		Result: &result,
+		ErrorMessages: map[MessageID]string{
+			MsgUnconvertibleType: "{name}: erwartet {expected}",
+		},
+	}
+
+	decoder, err := NewDecoder(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	err = decoder.Decode(map[string]interface{}{"vstring": []int{1}})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	var derr *DecodingError
+	if !errors.As(err, &derr) {
+		t.Fatalf("expected a DecodingError, got %T", err)
+	}
+	if derr.Message != MsgUnconvertibleType {
+		t.Fatalf("bad message: %q", derr.Message)
+	}
+	if expected := "Vstring: erwartet string"; derr.Error() != expected {
+		t.Fatalf("expected: %q\ngot: %q", expected, derr.Error())
+	}
+}
+
+func TestDecoder_ErrorsMapKeyOrder(t *testing.T) {
+	t.Parallel()
+
+	var ports map[int]int
+	err := Decode(map[int]interface{}{10: "a", 2: "b", -1: "c"}, &ports)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	var values []interface{}
+	for _, e := range err.(*Error).WrappedErrors() {
+		values = append(values, e.(*DecodingError).Value)
+	}
+	if expected := []interface{}{"c", "b", "a"}; !reflect.DeepEqual(values, expected) {
+		t.Fatalf("expected: %#v\ngot: %#v", expected, values)
+	}
+
+	var items map[string]int
+	err = Decode(map[string]interface{}{
+		"item10": "a", "item2": "b", "item": "c", "item1": "d", "item01": "e",
+	}, &items)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	var names []string
+	for _, e := range err.(*Error).WrappedErrors() {
+		names = append(names, e.(*DecodingError).Name)
+	}
+	expected := []string{"[item]", "[item01]", "[item1]", "[item2]", "[item10]"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected: %#v\ngot: %#v", expected, names)
+	}
+}
+
+func TestDecoder_ErrorsFormatter(t *testing.T) {
+	t.Parallel()
+
+	input := map[string]interface{}{
+		"vfoo": 1,
+		"vother": map[string]interface{}{
+			"c": 3,
+			"a": 1,
+			"b": 2,
+		},
+	}
+
+	var result Map
+	err := Decode(input, &result)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	expected := "4 error(s) decoding:\n\n" +
+		"* 'Vfoo' expected type 'string', got unconvertible type 'int', value: '1'\n" +
+		"* 'Vother[a]' expected type 'string', got unconvertible type 'int', value: '1'\n" +
+		"* 'Vother[b]' expected type 'string', got unconvertible type 'int', value: '2'\n" +
+		"* 'Vother[c]' expected type 'string', got unconvertible type 'int', value: '3'"
+	if err.Error() != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, err)
+	}
+
+	input = map[string]interface{}{
+		"vstring": 1,
+		"vint":    "a",
+		"vbool":   "b",
+	}
+
+	var basic Basic
+	config := &DecoderConfig{
+		Result:          &basic,
+		ErrorsFormatter: SortedDecodingErrorsFormatter,
+	}
+
+	decoder, err := NewDecoder(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	err = decoder.Decode(input)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	expected = "3 error(s) decoding:\n\n" +
+		"* 'Vbool' expected type 'bool', got unconvertible type 'string', value: 'b'\n" +
+		"* 'Vint' expected type 'int', got unconvertible type 'string', value: 'a'\n" +
+		"* 'Vstring' expected type 'string', got unconvertible type 'int', value: '1'"
+	if err.Error() != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, err)
+	}
+
+	// The errors themselves are still kept in the order they occurred.
+	order := []string{"Vstring", "Vint", "Vbool"}
+	for i, e := range err.(*Error).WrappedErrors() {
+		if name := e.(*DecodingError).Name; name != order[i] {
+			t.Errorf("error %d: expected %s, got %s", i, order[i], name)
+		}
+	}
+}
+
+func TestDecoder_ValueFormatter(t *testing.T) {
+	t.Parallel()
+
+	input := map[string]interface{}{
+		"vstring": map[string]interface{}{"key": strings.Repeat("é", 20)},
+	}
+
+	cases := []struct {
+		formatter ValueFormatter
+		expected  string
+	}{
+		{nil, "value: 'map[key:" + strings.Repeat("é", 20) + "]'"},
+		{TruncatingValueFormatter(10), "value: 'map[key:é...'"},
+		{TypeValueFormatter, "value: '<map[string]interface {}>'"},
+	}
+
+	for _, tc := range cases {
+		var result Basic
+		decoder, err := NewDecoder(&DecoderConfig{
+			Result:         &result,
+			ValueFormatter: tc.formatter,
+		})
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		err = decoder.Decode(input)
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		if !strings.HasSuffix(err.Error(), tc.expected) {
+			t.Errorf("expected %q at the end of: %s", tc.expected, err)
+		}
+	}
+}
+
+func TestDecoder_ValueFormatterErr(t *testing.T) {
+	t.Parallel()
+
+	input := map[string]interface{}{
+		"vint": strings.Repeat("x", 40),
+	}
+
+	var result Basic
+	decoder, err := NewDecoder(&DecoderConfig{
+		Result:           &result,
+		WeaklyTypedInput: true,
+		ValueFormatter:   TruncatingValueFormatter(-1),
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	err = decoder.Decode(input)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	expected := `cannot parse 'Vint' as int: strconv.ParseInt: parsing "...": invalid syntax`
+	if !strings.HasSuffix(err.Error(), expected) {
+		t.Fatalf("expected %q at the end of: %s", expected, err)
+	}
+}
+
+func TestDecoder_ErrorNames(t *testing.T) {
+	t.Parallel()
+
+	type Listener struct {
+		Port int `mapstructure:"port"`
+	}
+	type Server struct {
+		Listener  `mapstructure:",squash"`
+		Listeners []Listener
+	}
+
+	input := map[string]interface{}{
+		"SERVER": map[string]interface{}{
+			"port":      "x",
+			"listeners": []interface{}{map[string]interface{}{"port": "y"}},
+		},
+	}
+
+	cases := []struct {
+		names    ErrorNames
+		expected []string
+	}{
+		{ErrorNamesField, []string{"'Server.port'", "'Server.Listeners[0].port'"}},
+		{ErrorNamesSource, []string{"'SERVER.port'", "'SERVER.listeners.0.port'"}},
+		{ErrorNamesBoth, []string{"'Server.port (SERVER.port)'", "'Server.Listeners[0].port (SERVER.listeners.0.port)'"}},
+	}
+
+	for _, tc := range cases {
+		var result struct {
+			Server Server
+		}
+		decoder, err := NewDecoder(&DecoderConfig{
+			ErrorNames: tc.names,
+			Result:     &result,
+		})
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		err = decoder.Decode(input)
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		for _, expected := range tc.expected {
+			if !strings.Contains(err.Error(), expected) {
+				t.Errorf("names %d: expected %s in: %s", tc.names, expected, err)
+			}
+		}
+
+		var decodingErr *DecodingError
+		if !errors.As(err, &decodingErr) {
+			t.Fatalf("expected a *DecodingError: %s", err)
+		}
+		if source := decodingErr.SourceNamespace().String(); source != "SERVER.listeners.0.port" {
+			t.Errorf("bad source namespace: %s", source)
+		}
+	}
+}
+
+func TestError_Query(t *testing.T) {
+	t.Parallel()
+
+	type Server struct {
+		Host string
+		Port int
+	}
+	type Config struct {
+		Name   string
+		Server Server
+	}
+
+	input := map[string]interface{}{
+		"name":   1,
+		"server": map[string]interface{}{"host": 2, "port": "x"},
+		"extra":  true,
+	}
+
+	var result Config
+	decoder, err := NewDecoder(&DecoderConfig{
+		Result:      &result,
+		ErrorUnused: true,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	err = decoder.Decode(input)
+	var decodeErr *Error
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf("expected an *Error, got %v", err)
+	}
+
+	if first := decodeErr.First(); first == nil || first.Name != "Name" {
+		t.Fatalf("bad first: %#v", first)
+	}
+
+	unused := decodeErr.FilterByKind(DecodingErrorUnusedKeys)
+	if unused == nil || len(unused.Errors) != 1 || unused.First().Kind != DecodingErrorUnusedKeys {
+		t.Fatalf("bad unused: %#v", unused)
+	}
+	if decodeErr.FilterByKind(DecodingErrorHookFailure) != nil {
+		t.Fatal("expected no hook failures")
+	}
+
+	ns, err := ParseNamespace("Server")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	server := decodeErr.ForNamespacePrefix(ns)
+	if server == nil || len(server.Errors) != 2 {
+		t.Fatalf("bad server errors: %#v", server)
+	}
+	for _, err := range server.WrappedErrors() {
+		if name := err.(*DecodingError).Name; !strings.HasPrefix(name, "Server.") {
+			t.Errorf("bad name: %s", name)
+		}
+	}
+
+	// Filters can be chained, even when nothing matches.
+	if server.FilterByKind(DecodingErrorUnusedKeys).First() != nil {
+		t.Fatal("expected no unused keys under Server")
+	}
+}
+
+func TestDecoder_RootLabel(t *testing.T) {
+	t.Parallel()
+
+	var result Basic
+	decoder, err := NewDecoder(&DecoderConfig{
+		Result:    &result,
+		RootLabel: "app.yaml",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	err = decoder.Decode(map[string]interface{}{"vint": "a", "vbool": 1})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	for _, expected := range []string{
+		"* app.yaml: 'Vint' expected type 'int', got unconvertible type 'string', value: 'a'",
+		"* app.yaml: 'Vbool' expected type 'bool', got unconvertible type 'int', value: '1'",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected %q in: %s", expected, err)
+		}
+	}
+
+	var decodingErr *DecodingError
+	if !errors.As(err, &decodingErr) {
+		t.Fatalf("expected a *DecodingError: %s", err)
+	}
+	if decodingErr.Root != "app.yaml" || !strings.HasPrefix(decodingErr.Name, "V") {
+		t.Fatalf("bad: %#v", decodingErr)
+	}
+}
+
+type testPositions map[string]Position
+
+func (p testPositions) Position(path []string) (Position, bool) {
+	pos, ok := p[strings.Join(path, "/")]
+	return pos, ok
+}
+
+func TestDecoder_Positions(t *testing.T) {
+	t.Parallel()
+
+	type Server struct {
+		Name string
+		Port int
+	}
+
+	type Config struct {
+		Servers []Server
+		Timeout int
+	}
+
+	input := map[string]interface{}{
+		"servers": []interface{}{
+			map[string]interface{}{"name": "a", "port": 80},
+			map[string]interface{}{"name": 2, "port": "http"},
+		},
+		"timeout": "soon",
+	}
+
+	var result Config
+	config := &DecoderConfig{
+		Result: &result,
+		Positions: testPositions{
+			"servers/1":      {Filename: "config.yaml", Line: 5, Column: 3},
+			"servers/1/port": {Filename: "config.yaml", Line: 6, Column: 11},
+			"timeout":        {Filename: "config.yaml", Line: 7, Column: 10},
+		},
+	}
+
+	decoder, err := NewDecoder(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	err = decoder.Decode(input)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	expected := []string{
+		"config.yaml:5:3: 'Servers[1].Name' expected type 'string', got unconvertible type 'int', value: '2'",
+		"config.yaml:6:11: 'Servers[1].Port' expected type 'int', got unconvertible type 'string', value: 'http'",
+		"config.yaml:7:10: 'Timeout' expected type 'int', got unconvertible type 'string', value: 'soon'",
+	}
+	if actual := err.(*Error).Errors; !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("expected: %#v\ngot: %#v", expected, actual)
+	}
+
+	var derr *DecodingError
+	if !errors.As(err, &derr) {
+		t.Fatal("expected a DecodingError")
+	}
+	if derr.Position.Line != 5 {
+		t.Fatalf("bad position: %#v", derr.Position)
+	}
+}
+
+func TestDecoder_FlatKeySeparator(t *testing.T) {
+	t.Parallel()
+
+	type HTTP struct {
+		Port int
+	}
+
+	type Server struct {
+		HTTP  HTTP
+		Hosts []string
+	}
+
+	type Config struct {
+		Server Server
+		Labels map[string]string
+	}
+
+	input := map[string]interface{}{
+		"server.http.port":                8080,
+		"server.hosts[0]":                 "a",
+		"server.hosts[1]":                 "b",
+		`labels.app\.kubernetes\.io/name`: "demo",
+	}
+
+	var result Config
+	config := &DecoderConfig{
+		Result:           &result,
+		FlatKeySeparator: ".",
+	}
+
+	decoder, err := NewDecoder(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := decoder.Decode(input); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := Config{
+		Server: Server{
+			HTTP:  HTTP{Port: 8080},
+			Hosts: []string{"a", "b"},
+		},
+		Labels: map[string]string{"app.kubernetes.io/name": "demo"},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	err = decoder.Decode(map[string]interface{}{"server": 1, "server.http.port": 2})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestDecoder_FlatKeySeparator_encode(t *testing.T) {
+	t.Parallel()
+
+	type Listener struct {
+		Port int
+	}
+
+	type Server struct {
+		Name      string
+		Listeners []Listener
+		Tags      []string
+	}
+
+	type Config struct {
+		Server Server
+		Labels map[string]string
+	}
+
+	input := Config{
+		Server: Server{
+			Name:      "web",
+			Listeners: []Listener{{Port: 80}, {Port: 443}},
+			Tags:      []string{},
+		},
+		Labels: map[string]string{"app.kubernetes.io/name": "demo"},
+	}
+
+	var result map[string]interface{}
+	config := &DecoderConfig{
+		Result:           &result,
+		FlatKeySeparator: ".",
+	}
+
+	decoder, err := NewDecoder(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := decoder.Decode(input); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[string]interface{}{
+		"Server.Name":                     "web",
+		"Server.Listeners[0].Port":        80,
+		"Server.Listeners[1].Port":        443,
+		"Server.Tags":                     []string{},
+		`Labels.app\.kubernetes\.io/name`: "demo",
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	// Decoding the flattened map back gives the original struct.
+	var roundTrip Config
+	config = &DecoderConfig{
+		Result:           &roundTrip,
+		FlatKeySeparator: ".",
+	}
+
+	decoder, err = NewDecoder(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := decoder.Decode(result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !reflect.DeepEqual(roundTrip, input) {
+		t.Fatalf("bad: %#v", roundTrip)
+	}
+}
+
+func TestDecodePath(t *testing.T) {
+	t.Parallel()
+
+	type Item struct {
+		Name string
+	}
+
+	type User struct {
+		Name      string
+		CobrandID int    `jpath:"userContext.cobrandId"`
+		Locale    string `jpath:"userContext.conversationCredentials.locale"`
+		FirstItem string `jpath:"items.0.name"`
+		LastItem  Item   `jpath:"items[1]"`
+		Missing   string `jpath:"userContext.missing"`
+	}
+
+	input := map[string]interface{}{
+		"name": "alice",
+		"userContext": map[string]interface{}{
+			"cobrandId": 10000004,
+			"conversationCredentials": map[string]interface{}{
+				"locale": "en_US",
+			},
+		},
+		"items": []interface{}{
+			map[string]interface{}{"name": "first"},
+			map[string]interface{}{"name": "second"},
+		},
+	}
+
+	var result User
+	if err := DecodePath(input, &result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := User{
+		Name:      "alice",
+		CobrandID: 10000004,
+		Locale:    "en_US",
+		FirstItem: "first",
+		LastItem:  Item{Name: "second"},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+}
+
+func TestDecoder_PathTagName(t *testing.T) {
+	t.Parallel()
+
+	type Inner struct {
+		Port int `path:"listeners[0].port"`
+	}
+
+	type Config struct {
+		Inner Inner `path:"server"`
+	}
+
+	input := map[string]interface{}{
+		"server": map[string]interface{}{
+			"listeners": []interface{}{
+				map[string]interface{}{"port": "http"},
+			},
+		},
+		"extra": true,
+	}
+
+	var result Config
+	var md Metadata
+	config := &DecoderConfig{
+		Result:      &result,
+		Metadata:    &md,
+		PathTagName: "path",
+		ErrorUnused: true,
+	}
+
+	decoder, err := NewDecoder(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	err = decoder.Decode(input)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	var derr *DecodingError
+	if !errors.As(err, &derr) {
+		t.Fatalf("expected a DecodingError, got %#v", err)
+	}
+	if derr.Name != "server.listeners[0].port" {
+		t.Fatalf("bad name: %q", derr.Name)
+	}
+	if !strings.Contains(err.Error(), "extra") {
+		t.Fatalf("expected unused key error, got: %s", err)
+	}
+}
+
+func TestDecoder_PathTagOption(t *testing.T) {
+	t.Parallel()
+
+	type Config struct {
+		Name    string
+		Port    int           `mapstructure:"server.listeners[0].port,path"`
+		Timeout time.Duration `mapstructure:"server.timeout,path"`
+		Region  string        `mapstructure:"server.region,path"`
+	}
+
+	input := map[string]interface{}{
+		"name": "web",
+		"server": map[string]interface{}{
+			"listeners": []interface{}{
+				map[string]interface{}{"port": 8080},
+			},
+			"timeout": "5s",
+		},
+	}
+
+	var result Config
+	var md Metadata
+	decoder, err := NewDecoder(&DecoderConfig{
+		Result:     &result,
+		Metadata:   &md,
+		DecodeHook: StringToTimeDurationHookFunc(),
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := decoder.Decode(input); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := Config{Name: "web", Port: 8080, Timeout: 5 * time.Second}
+	if result != expected {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	sort.Strings(md.Keys)
+	if !reflect.DeepEqual(md.Keys, []string{"Name", "server.listeners[0].port", "server.timeout"}) {
+		t.Fatalf("bad keys: %#v", md.Keys)
+	}
+	if !reflect.DeepEqual(md.Unset, []string{"server.region"}) {
+		t.Fatalf("bad unset: %#v", md.Unset)
+	}
+
+	input["server"].(map[string]interface{})["timeout"] = "soon"
+	err = decoder.Decode(input)
+
+	var derr *DecodingError
+	if !errors.As(err, &derr) || derr.Name != "server.timeout" {
+		t.Fatalf("bad: %#v", err)
+	}
+}
+
+func TestDecodePath_wildcard(t *testing.T) {
+	t.Parallel()
+
+	type Result struct {
+		Endpoints []string `jpath:"clusters.*.endpoint"`
+		Regions   []string `jpath:"regions.*.name"`
+		Ports     [][]int  `jpath:"clusters.*.nodes.*.port"`
+	}
+
+	input := map[string]interface{}{
+		"clusters": []interface{}{
+			map[string]interface{}{
+				"endpoint": "a.example.com",
+				"nodes": []interface{}{
+					map[string]interface{}{"port": 1},
+					map[string]interface{}{"port": 2},
+				},
+			},
+			map[string]interface{}{"nodes": []interface{}{}},
+			map[string]interface{}{"endpoint": "c.example.com"},
+		},
+		"regions": map[string]interface{}{
+			"us": map[string]interface{}{"name": "US"},
+			"eu": map[string]interface{}{"name": "EU"},
+		},
+	}
+
+	var result Result
+	if err := DecodePath(input, &result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := Result{
+		Endpoints: []string{"a.example.com", "c.example.com"},
+		Regions:   []string{"EU", "US"},
+		Ports:     [][]int{{1, 2}, {}},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+}
+
+func TestDecoder_DecodeAt(t *testing.T) {
+	t.Parallel()
+
+	type Service struct {
+		Image string
+		Port  int
+	}
+
+	input := map[string]interface{}{
+		"version": 3,
+		"services": map[string]interface{}{
+			"web": map[string]interface{}{
+				"image": "nginx",
+				"port":  "http",
+			},
+			"db": map[string]interface{}{
+				"image": "postgres",
+				"port":  5432,
+			},
+		},
+	}
+
+	var result Service
+	decoder, err := NewDecoder(&DecoderConfig{Result: &result})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := decoder.DecodeAt(input, "services.db"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(result, Service{Image: "postgres", Port: 5432}) {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	err = decoder.DecodeAt(input, "services.web")
+	var derr *DecodingError
+	if !errors.As(err, &derr) {
+		t.Fatalf("expected a DecodingError, got %#v", err)
+	}
+	if derr.Name != "services.web.Port" {
+		t.Fatalf("bad name: %q", derr.Name)
+	}
+	if path := strings.Join(derr.sourcePath(), "."); path != "services.web.port" {
+		t.Fatalf("bad source path: %q", path)
+	}
+
+	err = decoder.DecodeAt(input, "services.cache")
+	if !errors.As(err, &derr) || derr.Kind != DecodingErrorPathNotFound {
+		t.Fatalf("expected a path not found error, got %#v", err)
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	t.Parallel()
+
+	type Result struct {
+		ID    int64
+		Ratio float64
+		Name  string
+		Extra interface{}
+	}
+
+	input := `{"id": 9007199254740993, "ratio": 0.5, "name": "x", "extra": 12345678901234567890}`
+
+	var result Result
+	if err := DecodeJSON(strings.NewReader(input), &result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := Result{
+		ID:    9007199254740993,
+		Ratio: 0.5,
+		Name:  "x",
+		Extra: json.Number("12345678901234567890"),
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	var weak Result
+	err := DecodeJSON(strings.NewReader(`{"id": "1"}`), &weak, &DecoderConfig{
+		WeaklyTypedInput: true,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if weak.ID != 1 {
+		t.Fatalf("bad: %#v", weak)
+	}
+
+	err = DecodeJSON(strings.NewReader(`{"unknown": 1}`), &result, &DecoderConfig{
+		ErrorUnused: true,
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	for _, input := range []string{`{`, `{} {}`, `{"id": 1} x`, `{}]`} {
+		if err := DecodeJSON(strings.NewReader(input), &result); err == nil {
+			t.Errorf("%s: expected error", input)
+		}
+	}
+
+	if err := DecodeJSON(strings.NewReader("{}\n\t "), &result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestDecoder_Int64AsString(t *testing.T) {
+	t.Parallel()
+
+	type Account struct {
+		ID      int64
+		Balance uint64
+		Count   int
+		Ratio   float64 `mapstructure:",string"`
+		Parent  *int64
+		Missing *int64
+		Active  bool `mapstructure:"active,string"`
+		Timeout time.Duration
+	}
+
+	parent := int64(9007199254740993)
+	input := Account{
+		ID:      9007199254740993,
+		Balance: math.MaxUint64,
+		Count:   3,
+		Ratio:   0.5,
+		Parent:  &parent,
+		Active:  true,
+		Timeout: time.Second,
+	}
+
+	var result map[string]interface{}
+	config := &DecoderConfig{
+		Result:        &result,
+		Int64AsString: true,
+	}
+
+	decoder, err := NewDecoder(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := decoder.Decode(input); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[string]interface{}{
+		"ID":      "9007199254740993",
+		"Balance": "18446744073709551615",
+		"Count":   3,
+		"Ratio":   "0.5",
+		"Parent":  "9007199254740993",
+		"Missing": (*int64)(nil),
+		"active":  "true",
+		"Timeout": time.Second,
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	var roundTrip Account
+	if err := WeakDecode(result, &roundTrip); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(roundTrip, input) {
+		t.Fatalf("bad: %#v", roundTrip)
+	}
+}
+
+type testColor int
+
+func (c testColor) String() string {
+	return [...]string{"red", "green"}[c]
+}
+
+type testID struct {
+	kind string
+	n    int
+}
+
+func (id *testID) String() string {
+	return fmt.Sprintf("%s-%d", id.kind, id.n)
+}
+
+func TestDecoder_EncodeStringers(t *testing.T) {
+	t.Parallel()
+
+	type Item struct {
+		Color  testColor
+		Colors []testColor
+		ID     testID
+		Owner  *testID
+		None   *testID
+		Count  int
+	}
+
+	input := &Item{
+		Color:  1,
+		Colors: []testColor{0},
+		ID:     testID{"item", 7},
+		Owner:  &testID{"user", 1},
+		Count:  2,
+	}
+
+	var result map[string]interface{}
+	decoder, err := NewDecoder(&DecoderConfig{
+		EncodeStringers: true,
+		Result:          &result,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := decoder.Decode(input); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[string]interface{}{
+		"Color":  "green",
+		"Colors": []testColor{0},
+		"ID":     "item-7",
+		"Owner":  "user-1",
+		"None":   (*testID)(nil),
+		"Count":  2,
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	type Enum struct {
+		Color testColor
+	}
+
+	var flat map[string]string
+	decoder, err = NewDecoder(&DecoderConfig{
+		EncodeStringers: true,
+		Result:          &flat,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := decoder.Decode(Enum{Color: 0}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if flat["Color"] != "red" {
+		t.Fatalf("bad: %#v", flat)
+	}
+}
+
+func TestDecoder_EncodeAsStrings(t *testing.T) {
+	t.Parallel()
+
+	type Server struct {
+		Host  string
+		Ports []int
+	}
+
+	type Config struct {
+		Name    string
+		Debug   bool
+		Workers *int
+		Limit   *int
+		Ratio   float64
+		Color   testColor
+		Started time.Time
+		Timeout time.Duration
+		Server  Server
+	}
+
+	workers := 4
+	input := Config{
+		Name:    "api",
+		Debug:   true,
+		Workers: &workers,
+		Ratio:   0.5,
+		Color:   1,
+		Started: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Timeout: 90,
+		Server:  Server{Host: "localhost", Ports: []int{80, 443}},
+	}
+
+	var result map[string]interface{}
+	decoder, err := NewDecoder(&DecoderConfig{
+		EncodeAsStrings: true,
+		Result:          &result,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := decoder.Decode(input); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[string]interface{}{
+		"Name":    "api",
+		"Debug":   "1",
+		"Workers": "4",
+		"Limit":   "",
+		"Ratio":   "0.5",
+		"Color":   "green",
+		"Started": "2020-01-02T03:04:05Z",
+		"Timeout": "90ns",
+		"Server": map[string]interface{}{
+			"Host":  "localhost",
+			"Ports": []string{"80", "443"},
+		},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	var flat map[string]string
+	decoder, err = NewDecoder(&DecoderConfig{
+		EncodeAsStrings:  true,
+		FlatKeySeparator: "_",
+		Result:           &flat,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := decoder.Decode(input); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if len(flat) != 11 || flat["Server_Host"] != "localhost" || flat["Server_Ports[1]"] != "443" || flat["Debug"] != "1" {
+		t.Fatalf("bad: %#v", flat)
+	}
+
+	// The strings decode back into the struct with WeaklyTypedInput.
+	var roundTrip Config
+	decoder, err = NewDecoder(&DecoderConfig{
+		WeaklyTypedInput: true,
+		FlatKeySeparator: "_",
+		DecodeHook: ComposeDecodeHookFunc(
+			StringToTimeHookFunc(time.RFC3339),
+			StringToTimeDurationHookFunc(),
+		),
+		Result: &roundTrip,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	// Stringers don't parse back, and "" decodes into a pointer to 0.
+	delete(flat, "Color")
+	delete(flat, "Limit")
+	if err := decoder.Decode(flat); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	input.Color = 0
+	if !reflect.DeepEqual(roundTrip, input) {
+		t.Fatalf("bad: %#v", roundTrip)
+	}
+}
+
+func TestDecoder_ExtendedBools(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		input    string
+		weak     bool
+		expected bool
+		err      bool
+	}{
+		{"yes", false, true, false},
+		{"No", false, false, false},
+		{"ON", false, true, false},
+		{"off", false, false, false},
+		{"enabled", false, true, false},
+		{"Disabled", false, false, false},
+		{"y", false, true, false},
+		{"true", false, true, false},
+		{"0", false, false, false},
+		{"", false, false, true},
+		{"", true, false, false},
+		{"maybe", true, false, true},
+	}
+
+	for _, tc := range cases {
+		var result bool
+		decoder, err := NewDecoder(&DecoderConfig{
+			ExtendedBools:    true,
+			WeaklyTypedInput: tc.weak,
+			Result:           &result,
+		})
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		err = decoder.Decode(tc.input)
+		if tc.err != (err != nil) {
+			t.Fatalf("%q: unexpected error: %v", tc.input, err)
+		}
+		if result != tc.expected {
+			t.Fatalf("%q: bad: %v", tc.input, result)
+		}
+	}
+
+	// Numbers are still only converted with WeaklyTypedInput
+	var result bool
+	decoder, err := NewDecoder(&DecoderConfig{ExtendedBools: true, Result: &result})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := decoder.Decode(1); err == nil {
+		t.Fatal("expected error")
+	}
+
+	// Without ExtendedBools the literals are errors even when weak
+	if err := WeakDecode("yes", &result); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestDecoder_ZeroValueProviders(t *testing.T) {
+	t.Parallel()
+
+	type Limits struct {
+		Max int
+		Min int
+	}
+	type Config struct {
+		Labels map[string]string
+		Hosts  []string
+		Limits *Limits
+		Name   string
+	}
+
+	providers := map[reflect.Type]func() interface{}{
+		reflect.TypeOf(map[string]string{}): func() interface{} {
+			return map[string]string{"managed": "true"}
+		},
+		reflect.TypeOf([]string{}): func() interface{} {
+			return make([]string, 0, 16)
+		},
+		reflect.TypeOf(Limits{}): func() interface{} {
+			return Limits{Max: 100}
+		},
+		reflect.TypeOf(""): func() interface{} {
+			return "unnamed"
+		},
+	}
+
+	result := Config{
+		Labels: map[string]string{"old": "x"},
+		Hosts:  []string{"old"},
+		Name:   "old",
+	}
+	decoder, err := NewDecoder(&DecoderConfig{
+		ZeroFields:         true,
+		ZeroValueProviders: providers,
+		Result:             &result,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	err = decoder.Decode(map[string]interface{}{
+		"labels": map[string]string{"app": "web"},
+		"hosts":  []string{"a"},
+		"limits": map[string]interface{}{"min": 5},
+		"name":   nil,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := Config{
+		Labels: map[string]string{"managed": "true", "app": "web"},
+		Hosts:  []string{"a"},
+		Limits: &Limits{Max: 100, Min: 5},
+		Name:   "unnamed",
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+	if cap(result.Hosts) != 16 {
+		t.Fatalf("bad capacity: %d", cap(result.Hosts))
+	}
+
+	_, err = NewDecoder(&DecoderConfig{
+		ZeroValueProviders: map[reflect.Type]func() interface{}{
+			reflect.TypeOf(0): func() interface{} { return "zero" },
+		},
+		Result: &result,
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestDecode_RemainRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	type Config struct {
+		Name  string                 `mapstructure:"name"`
+		Other map[string]interface{} `mapstructure:",remain"`
+	}
+
+	input := map[string]interface{}{
+		"name":    "web",
+		"timeout": 30,
+		"tls":     map[string]interface{}{"enabled": true},
+	}
+
+	var config Config
+	if err := Decode(input, &config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	config.Name = "api"
+	config.Other["name"] = "ignored"
+
+	var result map[string]interface{}
+	if err := Decode(config, &result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[string]interface{}{
+		"name":    "api",
+		"timeout": 30,
+		"tls":     map[string]interface{}{"enabled": true},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	var strs map[string]string
+	if err := Decode(config, &strs); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestDecode_RemainTyped(t *testing.T) {
+	t.Parallel()
+
+	type Config struct {
+		Name   string         `mapstructure:"name"`
+		Limits map[string]int `mapstructure:",remain"`
+	}
+
+	input := map[string]interface{}{
+		"name":  "web",
+		"cpu":   2,
+		"mem":   "512",
+		"disk":  "large",
+		"procs": 4.0,
+	}
+
+	var result Config
+	err := WeakDecode(input, &result)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if !strings.Contains(err.Error(), "'disk'") {
+		t.Fatalf("bad err: %s", err)
+	}
+
+	expected := map[string]int{"cpu": 2, "mem": 512, "procs": 4}
+	if !reflect.DeepEqual(result.Limits, expected) {
+		t.Fatalf("bad: %#v", result.Limits)
+	}
+
+	type Labels struct {
+		Labels *map[string]string `mapstructure:",remain"`
+	}
+
+	var labels Labels
+	if err := Decode(map[string]interface{}{"app": "web"}, &labels); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if labels.Labels == nil || !reflect.DeepEqual(*labels.Labels, map[string]string{"app": "web"}) {
+		t.Fatalf("bad: %#v", labels.Labels)
+	}
+
+	type Invalid struct {
+		Other []string `mapstructure:",remain"`
+	}
+
+	var invalid Invalid
+	if err := Decode(map[string]interface{}{"app": "web"}, &invalid); err == nil {
+		t.Fatal("expected error")
+	}
+
+	// Keys that aren't strings are converted to the key type of the map
+	// like any other value.
+	type Any struct {
+		Name string                      `mapstructure:"name"`
+		Rest map[interface{}]interface{} `mapstructure:",remain"`
+	}
+
+	input2 := map[interface{}]interface{}{"name": "web", 2: "x"}
+	var anyKeys Any
+	if err := Decode(input2, &anyKeys); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(anyKeys.Rest, map[interface{}]interface{}{2: "x"}) {
+		t.Fatalf("bad: %#v", anyKeys.Rest)
+	}
+
+	var strKeys Config
+	err = Decode(input2, &strKeys)
+	if err == nil || !strings.Contains(err.Error(), "'2' expected type 'string'") {
+		t.Fatalf("expected a key error, got %v", err)
+	}
+}
+
+func TestDecode_SquashMap(t *testing.T) {
+	t.Parallel()
+
+	type Pod struct {
+		Name        string                 `mapstructure:"name"`
+		Labels      map[string]string      `mapstructure:",squash,prefix=label_"`
+		Annotations *map[string]int        `mapstructure:",squash,prefix=note_"`
+		Other       map[string]interface{} `mapstructure:",remain"`
+	}
+
+	input := map[string]interface{}{
+		"name":       "web",
+		"label_app":  "web",
+		"label_tier": "frontend",
+		"note_rev":   3,
+		"replicas":   2,
 	}
 
-	expected := Target{
-		FirstMatch:  "foo",
-		SecondMatch: "bar",
+	var pod Pod
+	if err := Decode(input, &pod); err != nil {
+		t.Fatalf("err: %s", err)
 	}
 
-	var actual Target
+	if !reflect.DeepEqual(pod.Labels, map[string]string{"app": "web", "tier": "frontend"}) {
+		t.Fatalf("bad labels: %#v", pod.Labels)
+	}
+	if pod.Annotations == nil || !reflect.DeepEqual(*pod.Annotations, map[string]int{"rev": 3}) {
+		t.Fatalf("bad annotations: %#v", pod.Annotations)
+	}
+	if !reflect.DeepEqual(pod.Other, map[string]interface{}{"replicas": 2}) {
+		t.Fatalf("bad other: %#v", pod.Other)
+	}
+
+	var result map[string]interface{}
+	if err := Decode(pod, &result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(result, input) {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	// Keys of other fields are never replaced.
+	type Unprefixed struct {
+		Name   string            `mapstructure:"name"`
+		Labels map[string]string `mapstructure:",squash"`
+	}
+
+	var strs map[string]string
+	if err := Decode(Unprefixed{Name: "web", Labels: map[string]string{"name": "ignored", "app": "web"}}, &strs); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(strs, map[string]string{"name": "web", "app": "web"}) {
+		t.Fatalf("bad: %#v", strs)
+	}
+}
+
+func TestDecode_RemainExcept(t *testing.T) {
+	t.Parallel()
+
+	type Config struct {
+		Name  string                 `mapstructure:"name"`
+		Other map[string]interface{} `mapstructure:",remain,except=_*,except=x-*"`
+	}
+
+	input := map[string]interface{}{
+		"name":     "web",
+		"_comment": "internal",
+		"x-source": "generated",
+		"timeout":  30,
+	}
+
+	var result Config
+	var md Metadata
 	config := &DecoderConfig{
-		Result: &actual,
-		MatchName: func(mapKey, fieldName string) bool {
-			return mapKey == fieldName
+		Result:      &result,
+		Metadata:    &md,
+		ErrorUnused: true,
+	}
+
+	decoder, err := NewDecoder(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := decoder.Decode(input); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[string]interface{}{"timeout": 30}
+	if !reflect.DeepEqual(result.Other, expected) {
+		t.Fatalf("bad: %#v", result.Other)
+	}
+	if len(md.Unused) != 0 {
+		t.Fatalf("bad unused: %#v", md.Unused)
+	}
+
+	type Invalid struct {
+		Other map[string]interface{} `mapstructure:",remain,except=[a"`
+	}
+
+	var invalid Invalid
+	if err := Decode(input, &invalid); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestDecode_Secret(t *testing.T) {
+	t.Parallel()
+
+	type Credentials struct {
+		Token int `mapstructure:"token"`
+	}
+
+	type Database struct {
+		User        string      `mapstructure:"user"`
+		Password    int         `mapstructure:"password,secret"`
+		Credentials Credentials `mapstructure:"credentials,secret"`
+		Port        int         `mapstructure:"port"`
+	}
+
+	input := map[string]interface{}{
+		"user":        "admin",
+		"password":    "hunter2",
+		"credentials": map[string]interface{}{"token": "s3cr3t"},
+		"port":        "open",
+	}
+
+	var result Database
+	err := WeakDecode(input, &result)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	msg := err.Error()
+	for _, expected := range []string{
+		"cannot parse 'password' as int: <redacted>",
+		"cannot parse 'credentials.token' as int: <redacted>",
+		`cannot parse 'port' as int: strconv.ParseInt: parsing "open": invalid syntax`,
+	} {
+		if !strings.Contains(msg, expected) {
+			t.Fatalf("expected %q in err: %s", expected, msg)
+		}
+	}
+	if !errors.Is(err, strconv.ErrSyntax) {
+		t.Fatalf("expected the underlying error to be kept: %s", msg)
+	}
+
+	err = Decode(map[string]interface{}{"password": []string{"hunter2"}}, &result)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if !strings.Contains(err.Error(), "value: '<redacted>'") || strings.Contains(err.Error(), "hunter2") {
+		t.Fatalf("bad err: %s", err)
+	}
+
+	// Fields decoded from a path are redacted too.
+	type Nested struct {
+		Password int `mapstructure:"db.password,path,secret"`
+	}
+
+	var nested Nested
+	err = Decode(map[string]interface{}{"db": map[string]interface{}{"password": []string{"hunter2"}}}, &nested)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if !strings.Contains(err.Error(), "value: '<redacted>'") || strings.Contains(err.Error(), "hunter2") {
+		t.Fatalf("bad err: %s", err)
+	}
+}
+
+func TestDecoder_OutputHook(t *testing.T) {
+	t.Parallel()
+
+	type Backend struct {
+		Host   string
+		Weight int
+	}
+
+	type Config struct {
+		Backends []Backend
+		Primary  *Backend
+	}
+
+	input := map[string]interface{}{
+		"backends": []interface{}{
+			map[string]interface{}{"host": "A.example.com", "weight": 500},
+			map[string]interface{}{"host": "b.example.com", "weight": -1},
+		},
+		"primary": map[string]interface{}{"host": "C.Example.com"},
+	}
+
+	var names []string
+	var result Config
+	config := &DecoderConfig{
+		Result: &result,
+		OutputHook: func(ns *Namespace, v reflect.Value) error {
+			names = append(names, ns.String())
+			switch v.Kind() {
+			case reflect.String:
+				v.SetString(strings.ToLower(v.String()))
+			case reflect.Int:
+				if v.Int() < 0 {
+					v.SetInt(0)
+				} else if v.Int() > 100 {
+					v.SetInt(100)
+				}
+			}
+			return nil
 		},
 	}
 
@@ -2682,34 +5795,179 @@ func TestDecoder_MatchName(t *testing.T) {
 		t.Fatalf("err: %s", err)
 	}
 
+	if err := decoder.Decode(input); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := Config{
+		Backends: []Backend{
+			{Host: "a.example.com", Weight: 100},
+			{Host: "b.example.com", Weight: 0},
+		},
+		Primary: &Backend{Host: "c.example.com"},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	expectedNames := []string{
+		"Backends[0].Host", "Backends[0].Weight", "Backends[0]",
+		"Backends[1].Host", "Backends[1].Weight", "Backends[1]",
+		"Backends", "Primary.Host", "Primary", "",
+	}
+	if !reflect.DeepEqual(names, expectedNames) {
+		t.Fatalf("bad names: %#v", names)
+	}
+
+	config.OutputHook = func(ns *Namespace, v reflect.Value) error {
+		if ns.String() == "Primary.Host" {
+			return errors.New("invalid host")
+		}
+		return nil
+	}
+	decoder, err = NewDecoder(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
 	err = decoder.Decode(input)
+	var derr *DecodingError
+	if !errors.As(err, &derr) || derr.Kind != DecodingErrorHookFailure || derr.Name != "Primary.Host" {
+		t.Fatalf("bad err: %#v", err)
+	}
+}
+
+func TestDecoder_SkipRootDecodeHook(t *testing.T) {
+	t.Parallel()
+
+	type Config struct {
+		Name string
+		Tags map[string]string
+	}
+
+	input := map[string]interface{}{
+		"name": "web",
+		"tags": map[string]interface{}{"env": "prod"},
+	}
+
+	for _, skip := range []bool{false, true} {
+		var maps int
+		var result Config
+		config := &DecoderConfig{
+			Result:             &result,
+			SkipRootDecodeHook: skip,
+			DecodeHook: func(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+				if from.Kind() == reflect.Map {
+					maps++
+				}
+				if s, ok := data.(string); ok {
+					return strings.ToUpper(s), nil
+				}
+				return data, nil
+			},
+		}
+
+		decoder, err := NewDecoder(config)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		if err := decoder.Decode(input); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		expected := Config{Name: "WEB", Tags: map[string]string{"ENV": "PROD"}}
+		if !reflect.DeepEqual(result, expected) {
+			t.Fatalf("bad: %#v", result)
+		}
+
+		expectedMaps := 2
+		if skip {
+			expectedMaps = 1
+		}
+		if maps != expectedMaps {
+			t.Fatalf("skip %t: hook called for %d maps", skip, maps)
+		}
+	}
+}
+
+func TestDecoder_KeyTransform(t *testing.T) {
+	t.Parallel()
+
+	type Request struct {
+		RequestID string                 `mapstructure:"request_id"`
+		UserAgent string                 `mapstructure:"user_agent"`
+		Other     map[string]interface{} `mapstructure:",remain"`
+	}
+
+	input := map[string]interface{}{
+		"X-Request-Id": "abc",
+		"User-Agent":   "curl",
+		"X-Trace":      "t",
+	}
+
+	var result Request
+	var md Metadata
+	config := &DecoderConfig{
+		Result:   &result,
+		Metadata: &md,
+		KeyTransform: func(key string) string {
+			key = strings.TrimPrefix(key, "X-")
+			return strings.ToLower(strings.ReplaceAll(key, "-", "_"))
+		},
+	}
+
+	decoder, err := NewDecoder(config)
 	if err != nil {
 		t.Fatalf("err: %s", err)
 	}
 
-	if !reflect.DeepEqual(expected, actual) {
-		t.Fatalf("Decode() expected: %#v, got: %#v", expected, actual)
+	if err := decoder.Decode(input); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := Request{
+		RequestID: "abc",
+		UserAgent: "curl",
+		Other:     map[string]interface{}{"trace": "t"},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	// The input isn't modified
+	if _, ok := input["X-Request-Id"]; !ok {
+		t.Fatalf("input was modified: %#v", input)
 	}
 }
 
-func TestDecoder_IgnoreUntaggedFields(t *testing.T) {
-	type Input struct {
-		UntaggedNumber int
-		TaggedNumber   int `mapstructure:"tagged_number"`
-		UntaggedString string
-		TaggedString   string `mapstructure:"tagged_string"`
+func TestDecoder_MatchField(t *testing.T) {
+	t.Parallel()
+
+	type Record struct {
+		ID    int    `json:"id"`
+		Label string `json:"label"`
+		Name  string
 	}
-	input := &Input{
-		UntaggedNumber: 31,
-		TaggedNumber:   42,
-		UntaggedString: "hidden",
-		TaggedString:   "visible",
+
+	input := map[string]interface{}{
+		"id":    1,
+		"label": "a",
+		"Name":  "n",
 	}
 
-	actual := make(map[string]interface{})
+	var result Record
+	var md Metadata
 	config := &DecoderConfig{
-		Result:               &actual,
-		IgnoreUntaggedFields: true,
+		Result:   &result,
+		Metadata: &md,
+		MatchField: func(mapKey string, field reflect.StructField) bool {
+			// Only match the json names of integer fields
+			if field.Type.Kind() == reflect.Int {
+				return mapKey == field.Tag.Get("json")
+			}
+			return strings.EqualFold(mapKey, field.Name)
+		},
 	}
 
 	decoder, err := NewDecoder(config)
@@ -2717,18 +5975,131 @@ func TestDecoder_IgnoreUntaggedFields(t *testing.T) {
 		t.Fatalf("err: %s", err)
 	}
 
-	err = decoder.Decode(input)
+	if err := decoder.Decode(input); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := Record{ID: 1, Label: "a", Name: "n"}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	result = Record{}
+	if err := decoder.Decode(map[string]interface{}{"ID": 1}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if result.ID != 0 {
+		t.Fatalf("expected ID not to match, got %#v", result)
+	}
+}
+
+func TestDecoder_MatchFieldPath(t *testing.T) {
+	t.Parallel()
+
+	type Config struct {
+		Port int `mapstructure:"server.port,path"`
+	}
+
+	var result Config
+	decoder, err := NewDecoder(&DecoderConfig{
+		Result:      &result,
+		ErrorUnused: true,
+		MatchField: func(mapKey string, field reflect.StructField) bool {
+			return mapKey == field.Name
+		},
+	})
 	if err != nil {
 		t.Fatalf("err: %s", err)
 	}
 
-	expected := map[string]interface{}{
-		"tagged_number": 42,
-		"tagged_string": "visible",
+	// Only the key the path is looked up under is used.
+	err = decoder.Decode(map[string]interface{}{
+		"server": map[string]interface{}{"port": 80},
+		"SERVER": map[string]interface{}{"port": 81},
+	})
+	if err == nil || !strings.Contains(err.Error(), "has invalid keys: SERVER") {
+		t.Fatalf("bad: %v", err)
+	}
+	if result.Port != 80 {
+		t.Fatalf("bad: %#v", result)
 	}
+}
 
-	if !reflect.DeepEqual(expected, actual) {
-		t.Fatalf("Decode() expected: %#v\ngot: %#v", expected, actual)
+func TestDecoder_JSONTags(t *testing.T) {
+	t.Parallel()
+
+	type Meta struct {
+		Version int `json:"version"`
+	}
+
+	type Payload struct {
+		Meta
+		ID       int64  `json:"id,string"`
+		Name     string `json:"name,omitempty"`
+		Secret   string `json:"-"`
+		Override string `json:"ignored" mapstructure:"override"`
+		Plain    string
+	}
+
+	input := map[string]interface{}{
+		"version":  2,
+		"id":       "17",
+		"name":     "n",
+		"Secret":   "s",
+		"override": "o",
+		"plain":    "p",
+	}
+
+	var result Payload
+	config := &DecoderConfig{
+		Result:           &result,
+		JSONTags:         true,
+		WeaklyTypedInput: true,
+	}
+
+	decoder, err := NewDecoder(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := decoder.Decode(input); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := Payload{
+		Meta:     Meta{Version: 2},
+		ID:       17,
+		Name:     "n",
+		Override: "o",
+		Plain:    "p",
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	var encoded map[string]interface{}
+	config = &DecoderConfig{
+		Result:   &encoded,
+		JSONTags: true,
+	}
+
+	decoder, err = NewDecoder(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := decoder.Decode(Payload{ID: 1, Secret: "s"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expectedMap := map[string]interface{}{
+		"version":  0,
+		"id":       "1",
+		"override": "",
+		"Plain":    "",
+	}
+	if !reflect.DeepEqual(encoded, expectedMap) {
+		t.Fatalf("bad: %#v", encoded)
 	}
 }
 
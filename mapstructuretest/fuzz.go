@@ -0,0 +1,220 @@
+package mapstructuretest
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// maxFuzzDepth limits how deeply FuzzInput nests maps and slices.
+const maxFuzzDepth = 5
+
+// FuzzDecodeInto returns a function for fuzzers that decodes the input
+// built by FuzzInput from its argument into a new value of the type that
+// target points to, using config if given. The keys of the input are
+// picked from the field names of that type where possible, so that the
+// fuzzer reaches the fields quickly. For example:
+//
+//	func FuzzConfig(f *testing.F) {
+//		decode := mapstructuretest.FuzzDecodeInto(&Config{})
+//		f.Fuzz(func(t *testing.T, data []byte) {
+//			decode(data)
+//		})
+//	}
+//
+// The function panics if the decoder panics or returns an error that
+// isn't a *mapstructure.Error made up of DecodingErrors with messages.
+// FuzzDecodeInto panics right away if target isn't a pointer.
+func FuzzDecodeInto(target interface{}, config ...*mapstructure.DecoderConfig) func([]byte) {
+	c := copyConfig(config)
+	if c.TagName == "" {
+		c.TagName = "mapstructure"
+	}
+
+	typ := reflect.TypeOf(target)
+	if typ == nil || typ.Kind() != reflect.Ptr {
+		panic("mapstructuretest: target must be a pointer")
+	}
+	keys := fieldKeys(typ, c.TagName, map[reflect.Type]bool{})
+
+	return func(data []byte) {
+		decodeConfig := *c
+		decodeConfig.Result = reflect.New(typ.Elem()).Interface()
+		decoder, err := mapstructure.NewDecoder(&decodeConfig)
+		if err != nil {
+			panic(fmt.Sprintf("mapstructuretest: creating decoder: %s", err))
+		}
+
+		if err := checkError(decoder.Decode(FuzzInput(data, keys...))); err != nil {
+			panic(fmt.Sprintf("mapstructuretest: %s", err))
+		}
+	}
+}
+
+// FuzzInput builds a nested map from data, such as the input of a fuzz
+// test. The same data always gives the same map. The map keys are picked
+// from keys or made up from data.
+func FuzzInput(data []byte, keys ...string) map[string]interface{} {
+	r := &fuzzReader{data: data, keys: keys}
+	return r.mapValue(0)
+}
+
+// checkError returns an error describing how err breaks the invariants of
+// the errors returned by Decode, or nil if it doesn't.
+func checkError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var aggregate *mapstructure.Error
+	if !errors.As(err, &aggregate) {
+		return fmt.Errorf("error is a %T, not a *mapstructure.Error: %s", err, err)
+	}
+
+	wrapped := aggregate.WrappedErrors()
+	if len(wrapped) == 0 || len(wrapped) != len(aggregate.Errors) {
+		return fmt.Errorf("error wraps %d errors for %d messages", len(wrapped), len(aggregate.Errors))
+	}
+
+	for _, err := range wrapped {
+		if _, ok := err.(*mapstructure.Error); ok {
+			if err := checkError(err); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var derr *mapstructure.DecodingError
+		if !errors.As(err, &derr) {
+			return fmt.Errorf("wrapped error is a %T, not a *mapstructure.DecodingError: %s", err, err)
+		}
+		if err.Error() == "" {
+			return fmt.Errorf("error of kind %q has no message", derr.Kind)
+		}
+	}
+
+	return nil
+}
+
+// fieldKeys returns the keys that the fields of typ and the struct types
+// it contains are decoded from.
+func fieldKeys(typ reflect.Type, tagName string, seen map[reflect.Type]bool) []string {
+	switch typ.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return fieldKeys(typ.Elem(), tagName, seen)
+	case reflect.Struct:
+	default:
+		return nil
+	}
+
+	if seen[typ] {
+		return nil
+	}
+	seen[typ] = true
+
+	var keys []string
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		name := strings.SplitN(f.Tag.Get(tagName), ",", 2)[0]
+		if name == "" {
+			name = f.Name
+		}
+		if name != "-" {
+			keys = append(keys, name)
+		}
+
+		keys = append(keys, fieldKeys(f.Type, tagName, seen)...)
+	}
+
+	return keys
+}
+
+// fuzzReader builds values from the bytes of a fuzz input. Once the
+// bytes run out, it reads zeros.
+type fuzzReader struct {
+	data []byte
+	keys []string
+}
+
+func (r *fuzzReader) byte() byte {
+	if len(r.data) == 0 {
+		return 0
+	}
+
+	b := r.data[0]
+	r.data = r.data[1:]
+	return b
+}
+
+func (r *fuzzReader) uint64() uint64 {
+	var buf [8]byte
+	for i := range buf {
+		buf[i] = r.byte()
+	}
+
+	return binary.LittleEndian.Uint64(buf[:])
+}
+
+func (r *fuzzReader) string() string {
+	b := make([]byte, r.byte()%16)
+	for i := range b {
+		b[i] = r.byte()
+	}
+
+	return string(b)
+}
+
+func (r *fuzzReader) key() string {
+	b := r.byte()
+	if len(r.keys) > 0 && b&1 == 0 {
+		return r.keys[int(b>>1)%len(r.keys)]
+	}
+
+	return r.string()
+}
+
+func (r *fuzzReader) mapValue(depth int) map[string]interface{} {
+	n := int(r.byte() % 8)
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		key := r.key()
+		m[key] = r.value(depth + 1)
+	}
+
+	return m
+}
+
+func (r *fuzzReader) value(depth int) interface{} {
+	kinds := byte(8)
+	if depth >= maxFuzzDepth {
+		kinds = 6
+	}
+
+	switch r.byte() % kinds {
+	case 0:
+		return nil
+	case 1:
+		return r.byte()&1 == 1
+	case 2:
+		return int(int64(r.uint64()))
+	case 3:
+		return math.Float64frombits(r.uint64())
+	case 4:
+		return r.string()
+	case 5:
+		return r.key()
+	case 6:
+		s := make([]interface{}, r.byte()%4)
+		for i := range s {
+			s[i] = r.value(depth + 1)
+		}
+		return s
+	default:
+		return r.mapValue(depth)
+	}
+}
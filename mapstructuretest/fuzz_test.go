@@ -0,0 +1,66 @@
+package mapstructuretest
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+type fuzzConfig struct {
+	Name      string            `mapstructure:"name"`
+	Listeners []Listener        `mapstructure:"listeners"`
+	Labels    map[string]string `mapstructure:"labels"`
+	Timeout   time.Duration     `mapstructure:"timeout"`
+	Backend   *struct {
+		Hosts   [2]string `mapstructure:"hosts"`
+		Enabled bool      `mapstructure:"enabled"`
+	} `mapstructure:"backend"`
+	Extra map[string]interface{} `mapstructure:",remain"`
+}
+
+func TestFuzzInput(t *testing.T) {
+	t.Parallel()
+
+	data := []byte("\x02\x00\x04\x05hello\x02\x01\x03")
+	a := FuzzInput(data, "name", "port")
+	b := FuzzInput(data, "name", "port")
+	if !reflect.DeepEqual(a, b) {
+		t.Fatalf("not deterministic: %#v != %#v", a, b)
+	}
+	if a["name"] != "hello" || a["port"] != true {
+		t.Fatalf("bad: %#v", a)
+	}
+
+	if len(FuzzInput(nil)) != 0 {
+		t.Fatal("expected empty map")
+	}
+}
+
+func TestCheckError(t *testing.T) {
+	t.Parallel()
+
+	var result fuzzConfig
+	err := mapstructure.Decode(map[string]interface{}{"name": 1}, &result)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if err := checkError(err); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	f.Add([]byte(""))
+	f.Add([]byte("\x02\x00\x04\x05hello\x02\x01\x03"))
+	f.Add([]byte("\x02\x02\x07\x02\x00\x06\x03\x07\x01\x02\x02\x04\x02\x01\x00"))
+
+	decode := FuzzDecodeInto(&fuzzConfig{}, &mapstructure.DecoderConfig{
+		DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+		WeaklyTypedInput: true,
+	})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		decode(data)
+	})
+}
@@ -0,0 +1,184 @@
+// Package mapstructuretest provides helpers for testing code that decodes
+// with mapstructure.
+//
+// RequireDecode and RequireRoundTrip check the result of decoding, and
+// RequireErrors compares the decoding errors in an error against a golden
+// list:
+//
+//	err := mapstructure.Decode(input, &config)
+//	mapstructuretest.RequireErrors(t, err,
+//	    "listeners[0].port: parse failure",
+//	    "name: unconvertible type",
+//	)
+package mapstructuretest
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// RequireDecode decodes input into a new value of the type of want and
+// fails t if decoding fails or the result isn't deeply equal to want.
+// The config, if given, is used for decoding; its Result is ignored.
+func RequireDecode(t testing.TB, input, want interface{}, config ...*mapstructure.DecoderConfig) {
+	t.Helper()
+
+	result := reflect.New(reflect.TypeOf(want))
+	if err := decode(input, result.Interface(), config); err != nil {
+		t.Fatalf("error decoding: %s", err)
+	}
+
+	if got := result.Elem().Interface(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("decoded value doesn't match:\n got: %#v\nwant: %#v", got, want)
+	}
+}
+
+// RequireRoundTrip encodes value, a struct or a pointer to one, into a
+// map, decodes the map into a new value of the same type and fails t if
+// the result isn't deeply equal to value. The config, if given, is used
+// in both directions; its Result is ignored.
+func RequireRoundTrip(t testing.TB, value interface{}, config ...*mapstructure.DecoderConfig) {
+	t.Helper()
+
+	var encoded map[string]interface{}
+	if err := decode(value, &encoded, config); err != nil {
+		t.Fatalf("error encoding: %s", err)
+	}
+
+	want := reflect.Indirect(reflect.ValueOf(value)).Interface()
+	result := reflect.New(reflect.TypeOf(want))
+	if err := decode(encoded, result.Interface(), config); err != nil {
+		t.Fatalf("error decoding %#v: %s", encoded, err)
+	}
+
+	if got := result.Elem().Interface(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("round trip through %#v doesn't match:\n got: %#v\nwant: %#v", encoded, got, want)
+	}
+}
+
+// RequireErrors fails t unless the decoding errors in err are exactly
+// want, in any order. Each error is given as "namespace: kind", such as
+// "listeners[0].port: parse failure", where the kind is the string form of
+// a DecodingErrorKind. Namespaces are compared with Namespace.Equal, so
+// "listeners.0.port" matches as well. An error that has no decoding
+// errors, such as one returned before decoding started, always fails t.
+func RequireErrors(t testing.TB, err error, want ...string) {
+	t.Helper()
+
+	unexpected := decodingErrors(err)
+	if err != nil && len(unexpected) == 0 {
+		t.Fatalf("expected decoding errors, got: %v", err)
+		return
+	}
+
+	var missing []string
+	for _, w := range want {
+		name, kind := w, ""
+		if i := strings.LastIndex(w, ": "); i >= 0 {
+			name, kind = w[:i], w[i+2:]
+		}
+
+		found := false
+		for i, e := range unexpected {
+			if e.Kind.String() == kind && sameNamespace(e.Name, name) {
+				unexpected = append(unexpected[:i], unexpected[i+1:]...)
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, w)
+		}
+	}
+
+	if len(missing) > 0 || len(unexpected) > 0 {
+		lines := make([]string, len(unexpected))
+		for i, e := range unexpected {
+			lines[i] = errorLine(e)
+		}
+		sort.Strings(lines)
+
+		t.Fatalf("decoding errors don't match:\n   missing: %s\nunexpected: %s\n\nerror: %v",
+			strings.Join(missing, "; "), strings.Join(lines, "; "), err)
+	}
+}
+
+// ErrorLines returns a line of the form "namespace: kind" for each
+// DecodingError in err, sorted. The lines can be passed to RequireErrors.
+func ErrorLines(err error) []string {
+	var lines []string
+	for _, e := range decodingErrors(err) {
+		lines = append(lines, errorLine(e))
+	}
+	sort.Strings(lines)
+
+	return lines
+}
+
+func errorLine(e *mapstructure.DecodingError) string {
+	return e.Name + ": " + e.Kind.String()
+}
+
+// sameNamespace reports whether the names a and b are the same namespace,
+// comparing them as strings if they can't be parsed.
+func sameNamespace(a, b string) bool {
+	na, err := mapstructure.ParseNamespace(a)
+	if err != nil {
+		return a == b
+	}
+	nb, err := mapstructure.ParseNamespace(b)
+	if err != nil {
+		return a == b
+	}
+
+	return na.Equal(nb)
+}
+
+// decodingErrors returns the decoding errors in err, looking into the
+// errors that an aggregate *mapstructure.Error wraps.
+func decodingErrors(err error) []*mapstructure.DecodingError {
+	var aggregate *mapstructure.Error
+	if errors.As(err, &aggregate) {
+		var result []*mapstructure.DecodingError
+		for _, e := range aggregate.WrappedErrors() {
+			result = append(result, decodingErrors(e)...)
+		}
+
+		return result
+	}
+
+	var derr *mapstructure.DecodingError
+	if errors.As(err, &derr) {
+		return []*mapstructure.DecodingError{derr}
+	}
+
+	return nil
+}
+
+func decode(input, result interface{}, config []*mapstructure.DecoderConfig) error {
+	c := copyConfig(config)
+	c.Result = result
+
+	decoder, err := mapstructure.NewDecoder(c)
+	if err != nil {
+		return err
+	}
+
+	return decoder.Decode(input)
+}
+
+// copyConfig returns a copy of config[0] that can be changed freely, or
+// an empty config if none is given.
+func copyConfig(config []*mapstructure.DecoderConfig) *mapstructure.DecoderConfig {
+	if len(config) == 0 || config[0] == nil {
+		return &mapstructure.DecoderConfig{}
+	}
+
+	copied := *config[0]
+	return &copied
+}
@@ -0,0 +1,107 @@
+package mapstructuretest
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+type Listener struct {
+	Port int `mapstructure:"port"`
+}
+
+type Config struct {
+	Name      string     `mapstructure:"name"`
+	Listeners []Listener `mapstructure:"listeners"`
+}
+
+// fakeT records the failure of a helper instead of failing the test.
+type fakeT struct {
+	testing.TB
+	failure string
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Fatalf(format string, args ...interface{}) {
+	t.failure = fmt.Sprintf(format, args...)
+}
+
+func TestRequireDecode(t *testing.T) {
+	t.Parallel()
+
+	input := map[string]interface{}{
+		"name":      "web",
+		"listeners": []map[string]interface{}{{"port": "80"}},
+	}
+
+	RequireDecode(t, input, Config{Name: "web", Listeners: []Listener{{Port: 80}}},
+		&mapstructure.DecoderConfig{WeaklyTypedInput: true})
+
+	ft := &fakeT{}
+	RequireDecode(ft, input, Config{Name: "api"}, &mapstructure.DecoderConfig{WeaklyTypedInput: true})
+	if ft.failure == "" {
+		t.Fatal("expected failure")
+	}
+
+	ft = &fakeT{}
+	RequireDecode(ft, input, Config{})
+	if ft.failure == "" {
+		t.Fatal("expected failure")
+	}
+}
+
+func TestRequireRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	RequireRoundTrip(t, &Config{Name: "web"})
+
+	type lossy struct {
+		Name  string `mapstructure:"name"`
+		Token string `mapstructure:"-"`
+	}
+
+	ft := &fakeT{}
+	RequireRoundTrip(ft, lossy{Name: "web", Token: "secret"})
+	if ft.failure == "" {
+		t.Fatal("expected failure")
+	}
+}
+
+func TestRequireErrors(t *testing.T) {
+	t.Parallel()
+
+	input := map[string]interface{}{
+		"name":      []int{1},
+		"listeners": []map[string]interface{}{{"port": "http"}},
+	}
+
+	var result Config
+	err := mapstructure.WeakDecode(input, &result)
+
+	RequireErrors(t, err,
+		"name: unconvertible type",
+		"listeners.0.port: parse failure",
+	)
+
+	lines := ErrorLines(fmt.Errorf("loading config: %w", err))
+	if len(lines) != 2 || lines[0] != "listeners[0].port: parse failure" {
+		t.Fatalf("bad: %#v", lines)
+	}
+
+	ft := &fakeT{}
+	RequireErrors(ft, err, "name: unconvertible type")
+	if ft.failure == "" {
+		t.Fatal("expected failure")
+	}
+
+	RequireErrors(t, nil)
+
+	ft = &fakeT{}
+	RequireErrors(ft, errors.New("not a decoding error"))
+	if ft.failure == "" {
+		t.Fatal("expected failure")
+	}
+}
@@ -0,0 +1,45 @@
+package mapstructure
+
+import "reflect"
+
+// Marshaler is the interface implemented by types that choose the value
+// they are encoded into, instead of the map of their fields, when they
+// are decoded into a map. The returned value is decoded into the map, or
+// the map entry of the field, in their place. Like json.Marshaler, the
+// method is also found on a pointer to an addressable value.
+type Marshaler interface {
+	MarshalMapstructure() (interface{}, error)
+}
+
+var marshalerType = reflect.TypeOf((*Marshaler)(nil)).Elem()
+
+// marshaler returns v, or a pointer to it, as a Marshaler if it is one.
+// Nil pointers are never marshaled.
+func marshaler(v reflect.Value) (Marshaler, bool) {
+	if !v.IsValid() || v.Kind() == reflect.Ptr && v.IsNil() {
+		return nil, false
+	}
+
+	if v.Type().Implements(marshalerType) {
+		return v.Interface().(Marshaler), true
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(marshalerType) {
+		return v.Addr().Interface().(Marshaler), true
+	}
+
+	return nil, false
+}
+
+// marshal returns the value that m is encoded into.
+func (d *Decoder) marshal(name string, m Marshaler) (interface{}, error) {
+	v, err := m.MarshalMapstructure()
+	if err != nil {
+		return nil, d.decodingError(MsgMarshalFailure, &DecodingError{
+			Kind: DecodingErrorGeneric,
+			Name: name,
+			Err:  err,
+		})
+	}
+
+	return v, nil
+}
@@ -0,0 +1,150 @@
+package mapstructure
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+type marshalColor int
+
+func (c marshalColor) MarshalMapstructure() (interface{}, error) {
+	if c < 0 {
+		return nil, errors.New("invalid color")
+	}
+	return []string{"red", "green"}[c], nil
+}
+
+type marshalPoint struct {
+	X, Y int
+}
+
+func (p *marshalPoint) MarshalMapstructure() (interface{}, error) {
+	return []int{p.X, p.Y}, nil
+}
+
+type marshalLabels struct {
+	names []string
+}
+
+func (l marshalLabels) MarshalMapstructure() (interface{}, error) {
+	m := make(map[string]interface{})
+	for _, name := range l.names {
+		m["label_"+name] = true
+	}
+	return m, nil
+}
+
+type marshalShape struct {
+	// Embedding would promote the method to marshalShape.
+	Labels  marshalLabels `mapstructure:",squash"`
+	Color   marshalColor
+	Origin  marshalPoint
+	Center  *marshalPoint
+	Missing *marshalPoint
+}
+
+func TestDecode_Marshaler(t *testing.T) {
+	t.Parallel()
+
+	shape := &marshalShape{
+		Labels: marshalLabels{names: []string{"a"}},
+		Color:  1,
+		Origin: marshalPoint{1, 2},
+		Center: &marshalPoint{3, 4},
+	}
+
+	var result map[string]interface{}
+	if err := Decode(shape, &result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[string]interface{}{
+		"label_a": true,
+		"Color":   "green",
+		"Origin":  []int{1, 2},
+		"Center":  []int{3, 4},
+		"Missing": (*marshalPoint)(nil),
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	// The root value is marshaled too.
+	var rootMap map[string]interface{}
+	if err := Decode(marshalLabels{names: []string{"b"}}, &rootMap); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(rootMap, map[string]interface{}{"label_b": true}) {
+		t.Fatalf("bad: %#v", rootMap)
+	}
+
+	shape.Color = -1
+	err := Decode(shape, &result)
+	var derr *DecodingError
+	if !errors.As(err, &derr) || derr.Name != "Color" || derr.Err == nil {
+		t.Fatalf("bad: %#v", err)
+	}
+}
+
+func TestDecode_OrderedMapFromStructMarshaler(t *testing.T) {
+	t.Parallel()
+
+	shape := &marshalShape{
+		Labels: marshalLabels{names: []string{"a"}},
+		Color:  0,
+		Origin: marshalPoint{1, 2},
+	}
+
+	var result OrderedMap
+	if err := Decode(shape, &result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []string{"label_a", "Color", "Origin", "Center", "Missing"}
+	if !reflect.DeepEqual(result.Keys(), expected) {
+		t.Fatalf("bad: %#v", result.Keys())
+	}
+	if v, _ := result.Get("Color"); v != "red" {
+		t.Fatalf("bad: %#v", v)
+	}
+	if v, _ := result.Get("Origin"); !reflect.DeepEqual(v, []int{1, 2}) {
+		t.Fatalf("bad: %#v", v)
+	}
+}
+
+func TestDecode_MultiValueMapFromStructMarshaler(t *testing.T) {
+	t.Parallel()
+
+	type Query struct {
+		Color  marshalColor      `mapstructure:"color"`
+		Labels marshalLabels     `mapstructure:",squash"`
+		Point  marshalPoint      `mapstructure:"point"`
+		Extra  map[string]string `mapstructure:",remain"`
+	}
+
+	input := Query{
+		Color:  1,
+		Labels: marshalLabels{names: []string{"web"}},
+		Point:  marshalPoint{X: 1, Y: 2},
+		Extra:  map[string]string{"page": "2", "color": "ignored"},
+	}
+
+	// Point is only a Marshaler through a pointer, so the struct must be
+	// addressable.
+	var result url.Values
+	if err := Decode(&input, &result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := url.Values{
+		"color":     {"green"},
+		"label_web": {"true"},
+		"point":     {"1", "2"},
+		"page":      {"2"},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, result)
+	}
+}
@@ -0,0 +1,134 @@
+package mapstructure
+
+import (
+	"strings"
+	"unicode"
+)
+
+// splitWords splits a name written in camel case, snake case or kebab case
+// into its words, so that "MaxIdleConns", "max_idle_conns" and
+// "max-idle-conns" all become "Max", "Idle", "Conns" (in their original
+// case). Runs of upper case letters are read as acronyms: "HTTPServer" is
+// "HTTP", "Server". Digits belong to the word before them.
+func splitWords(name string) []string {
+	var words []string
+	runes := []rune(name)
+	start := 0
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r == '_' || r == '-' || r == ' ' || r == '.' {
+			if i > start {
+				words = append(words, string(runes[start:i]))
+			}
+			start = i + 1
+			continue
+		}
+
+		if i == start || !unicode.IsUpper(r) {
+			continue
+		}
+
+		prev := runes[i-1]
+		nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+		if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextIsLower) {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+
+	if start < len(runes) {
+		words = append(words, string(runes[start:]))
+	}
+
+	return words
+}
+
+func joinWords(words []string, sep string, transform func(i int, word string) string) string {
+	for i, word := range words {
+		words[i] = transform(i, word)
+	}
+
+	return strings.Join(words, sep)
+}
+
+// defaultKeyName is the name a field is encoded under by default: its
+// tag name if it has one, or its field name.
+func defaultKeyName(fieldName, tagName string) string {
+	if tagName != "" {
+		return tagName
+	}
+
+	return fieldName
+}
+
+// SnakeCase is an EncodeKeyTransform that writes keys in snake case, so
+// that the field MaxIdleConns is encoded as "max_idle_conns". Tag names
+// are converted too.
+func SnakeCase(fieldName, tagName string) string {
+	return joinWords(splitWords(defaultKeyName(fieldName, tagName)), "_", func(_ int, word string) string {
+		return strings.ToLower(word)
+	})
+}
+
+// KebabCase is an EncodeKeyTransform that writes keys in kebab case, so
+// that the field MaxIdleConns is encoded as "max-idle-conns". Tag names
+// are converted too.
+func KebabCase(fieldName, tagName string) string {
+	return joinWords(splitWords(defaultKeyName(fieldName, tagName)), "-", func(_ int, word string) string {
+		return strings.ToLower(word)
+	})
+}
+
+// CamelCase is an EncodeKeyTransform that writes keys in lower camel
+// case, so that the field MaxIdleConns is encoded as "maxIdleConns" and
+// HTTPServer as "httpServer". Tag names are converted too.
+func CamelCase(fieldName, tagName string) string {
+	return joinWords(splitWords(defaultKeyName(fieldName, tagName)), "", func(i int, word string) string {
+		word = strings.ToLower(word)
+		if i == 0 {
+			return word
+		}
+
+		r := []rune(word)
+		r[0] = unicode.ToUpper(r[0])
+		return string(r)
+	})
+}
+
+// CaseSensitive is a MatchName function that matches keys to fields only
+// if they are exactly equal.
+func CaseSensitive(mapKey, fieldName string) bool {
+	return mapKey == fieldName
+}
+
+// SnakeCaseFold is a MatchName function that matches keys to fields
+// ignoring case, either directly or in snake case, so that the field
+// MaxIdleConns matches "MaxIdleConns", "maxidleconns" and
+// "max_idle_conns".
+func SnakeCaseFold(mapKey, fieldName string) bool {
+	return strings.EqualFold(mapKey, fieldName) ||
+		strings.EqualFold(mapKey, SnakeCase(fieldName, ""))
+}
+
+// KebabCaseFold is a MatchName function that matches keys to fields
+// ignoring case, either directly or in kebab case, so that the field
+// MaxIdleConns matches "MaxIdleConns", "maxidleconns" and
+// "max-idle-conns".
+func KebabCaseFold(mapKey, fieldName string) bool {
+	return strings.EqualFold(mapKey, fieldName) ||
+		strings.EqualFold(mapKey, KebabCase(fieldName, ""))
+}
+
+// Chain returns a MatchName function that matches a key to a field if any
+// of the given functions does.
+func Chain(matchers ...func(mapKey, fieldName string) bool) func(mapKey, fieldName string) bool {
+	return func(mapKey, fieldName string) bool {
+		for _, match := range matchers {
+			if match(mapKey, fieldName) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
@@ -0,0 +1,165 @@
+package mapstructure
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitWords(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string][]string{
+		"":               nil,
+		"Name":           {"Name"},
+		"MaxIdleConns":   {"Max", "Idle", "Conns"},
+		"max_idle_conns": {"max", "idle", "conns"},
+		"max-idle-conns": {"max", "idle", "conns"},
+		"HTTPServer":     {"HTTP", "Server"},
+		"UserID":         {"User", "ID"},
+		"Port8080Alt":    {"Port8080", "Alt"},
+		"__a__b":         {"a", "b"},
+	}
+
+	for input, expected := range cases {
+		if actual := splitWords(input); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%q: expected %#v, got %#v", input, expected, actual)
+		}
+	}
+}
+
+func TestKeyTransforms(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		fieldName, tagName  string
+		snake, kebab, camel string
+	}{
+		{"MaxIdleConns", "", "max_idle_conns", "max-idle-conns", "maxIdleConns"},
+		{"HTTPServer", "", "http_server", "http-server", "httpServer"},
+		{"UserID", "user_id", "user_id", "user-id", "userId"},
+		{"X", "", "x", "x", "x"},
+	}
+
+	for _, tc := range cases {
+		if actual := SnakeCase(tc.fieldName, tc.tagName); actual != tc.snake {
+			t.Errorf("SnakeCase(%q, %q) = %q", tc.fieldName, tc.tagName, actual)
+		}
+		if actual := KebabCase(tc.fieldName, tc.tagName); actual != tc.kebab {
+			t.Errorf("KebabCase(%q, %q) = %q", tc.fieldName, tc.tagName, actual)
+		}
+		if actual := CamelCase(tc.fieldName, tc.tagName); actual != tc.camel {
+			t.Errorf("CamelCase(%q, %q) = %q", tc.fieldName, tc.tagName, actual)
+		}
+	}
+}
+
+func TestDecoder_EncodeKeyTransform(t *testing.T) {
+	t.Parallel()
+
+	type Pool struct {
+		MaxIdleConns int
+		IdleTimeout  string `mapstructure:"idleTimeoutSeconds"`
+	}
+
+	type Config struct {
+		HTTPServer string
+		Pool       Pool
+	}
+
+	input := Config{
+		HTTPServer: "web",
+		Pool:       Pool{MaxIdleConns: 3, IdleTimeout: "30"},
+	}
+
+	var result map[string]interface{}
+	config := &DecoderConfig{
+		Result:             &result,
+		EncodeKeyTransform: SnakeCase,
+	}
+
+	decoder, err := NewDecoder(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := decoder.Decode(input); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[string]interface{}{
+		"http_server": "web",
+		"pool": map[string]interface{}{
+			"max_idle_conns":       3,
+			"idle_timeout_seconds": "30",
+		},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+}
+
+func TestMatchers(t *testing.T) {
+	t.Parallel()
+
+	both := Chain(SnakeCaseFold, KebabCaseFold)
+	cases := []struct {
+		mapKey, fieldName              string
+		sensitive, snake, kebab, chain bool
+	}{
+		{"MaxIdleConns", "MaxIdleConns", true, true, true, true},
+		{"maxidleconns", "MaxIdleConns", false, true, true, true},
+		{"max_idle_conns", "MaxIdleConns", false, true, false, true},
+		{"MAX_IDLE_CONNS", "MaxIdleConns", false, true, false, true},
+		{"max-idle-conns", "MaxIdleConns", false, false, true, true},
+		{"max_idle", "MaxIdleConns", false, false, false, false},
+		{"http_server", "HTTPServer", false, true, false, true},
+	}
+
+	for _, tc := range cases {
+		if actual := CaseSensitive(tc.mapKey, tc.fieldName); actual != tc.sensitive {
+			t.Errorf("CaseSensitive(%q, %q) = %t", tc.mapKey, tc.fieldName, actual)
+		}
+		if actual := SnakeCaseFold(tc.mapKey, tc.fieldName); actual != tc.snake {
+			t.Errorf("SnakeCaseFold(%q, %q) = %t", tc.mapKey, tc.fieldName, actual)
+		}
+		if actual := KebabCaseFold(tc.mapKey, tc.fieldName); actual != tc.kebab {
+			t.Errorf("KebabCaseFold(%q, %q) = %t", tc.mapKey, tc.fieldName, actual)
+		}
+		if actual := both(tc.mapKey, tc.fieldName); actual != tc.chain {
+			t.Errorf("Chain(%q, %q) = %t", tc.mapKey, tc.fieldName, actual)
+		}
+	}
+}
+
+func TestDecoder_SnakeCaseFold(t *testing.T) {
+	t.Parallel()
+
+	type Pool struct {
+		MaxIdleConns int
+		HTTPTimeout  int
+	}
+
+	input := map[string]interface{}{
+		"max_idle_conns": 5,
+		"http_timeout":   30,
+	}
+
+	var result Pool
+	config := &DecoderConfig{
+		Result:    &result,
+		MatchName: SnakeCaseFold,
+	}
+
+	decoder, err := NewDecoder(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := decoder.Decode(input); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if result != (Pool{MaxIdleConns: 5, HTTPTimeout: 30}) {
+		t.Fatalf("bad: %#v", result)
+	}
+}
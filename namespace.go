@@ -0,0 +1,102 @@
+package mapstructure
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Namespace is a parsed path to a value, such as the name of a
+// DecodingError or a key in Metadata. It lets paths be compared without
+// string manipulation: "a.b[2]" and "a.b.2" are the same namespace.
+type Namespace struct {
+	segments []pathSegment
+}
+
+// ParseNamespace parses a path such as "a.b[2].c" into a Namespace. Keys
+// are separated by dots, and brackets hold slice indexes or, as in the
+// names the decoder gives map elements, map keys. A backslash escapes the
+// character that follows it. The empty string is the root namespace.
+func ParseNamespace(s string) (*Namespace, error) {
+	if s == "" {
+		return &Namespace{}, nil
+	}
+
+	segments, err := parseSegments(s, ".", true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Namespace{segments: segments}, nil
+}
+
+// namespaceOf returns the name of a decoded value as a Namespace. A name
+// that can't be parsed is a single key.
+func namespaceOf(name string) *Namespace {
+	ns, err := ParseNamespace(name)
+	if err != nil {
+		return &Namespace{segments: []pathSegment{{key: name}}}
+	}
+
+	return ns
+}
+
+// Len returns the number of keys and indexes in the namespace.
+func (n *Namespace) Len() int {
+	return len(n.segments)
+}
+
+// Equal reports whether n and other name the same value.
+func (n *Namespace) Equal(other *Namespace) bool {
+	return len(n.segments) == len(other.segments) && n.HasPrefix(other)
+}
+
+// HasPrefix reports whether prefix is n or one of its parents, so that
+// "a.b[2].c" has the prefixes "", "a", "a.b" and "a.b[2]", but not "a.bc".
+func (n *Namespace) HasPrefix(prefix *Namespace) bool {
+	if len(prefix.segments) > len(n.segments) {
+		return false
+	}
+
+	for i, seg := range prefix.segments {
+		if seg.String() != n.segments[i].String() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Append returns a new namespace with the given key below n.
+func (n *Namespace) Append(key string) *Namespace {
+	return n.with(pathSegment{key: key})
+}
+
+// AppendIndex returns a new namespace with the given slice index below n.
+func (n *Namespace) AppendIndex(index int) *Namespace {
+	return n.with(pathSegment{index: index, isIndex: true})
+}
+
+func (n *Namespace) with(seg pathSegment) *Namespace {
+	segments := make([]pathSegment, len(n.segments), len(n.segments)+1)
+	copy(segments, n.segments)
+	return &Namespace{segments: append(segments, seg)}
+}
+
+// String returns the namespace in the form accepted by ParseNamespace,
+// with indexes in brackets.
+func (n *Namespace) String() string {
+	var b strings.Builder
+	for i, seg := range n.segments {
+		if seg.isIndex {
+			b.WriteString("[" + strconv.Itoa(seg.index) + "]")
+			continue
+		}
+
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(escapePathKey(seg.key, "."))
+	}
+
+	return b.String()
+}
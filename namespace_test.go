@@ -0,0 +1,113 @@
+package mapstructure
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseNamespace(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		input    string
+		expected string
+		len      int
+	}{
+		{"", "", 0},
+		{"a", "a", 1},
+		{"a.b[2].c", "a.b[2].c", 4},
+		{"a.b.2.c", "a.b.2.c", 4},
+		{"Labels[app]", "Labels.app", 2},
+		{`a\.b.c`, `a\.b.c`, 2},
+		{"[0].a", "[0].a", 2},
+	}
+
+	for _, tc := range cases {
+		ns, err := ParseNamespace(tc.input)
+		if err != nil {
+			t.Errorf("%q: err: %s", tc.input, err)
+			continue
+		}
+
+		if ns.String() != tc.expected {
+			t.Errorf("%q: expected %q, got %q", tc.input, tc.expected, ns.String())
+		}
+		if ns.Len() != tc.len {
+			t.Errorf("%q: expected length %d, got %d", tc.input, tc.len, ns.Len())
+		}
+	}
+
+	for _, input := range []string{".", "a..b", "a[", "a[]"} {
+		if _, err := ParseNamespace(input); err == nil {
+			t.Errorf("%q: expected error", input)
+		}
+	}
+}
+
+func TestNamespace_compare(t *testing.T) {
+	t.Parallel()
+
+	parse := func(s string) *Namespace {
+		ns, err := ParseNamespace(s)
+		if err != nil {
+			t.Fatalf("%q: err: %s", s, err)
+		}
+		return ns
+	}
+
+	ns := parse("a.b[2].c")
+	if !ns.Equal(parse("a.b.2.c")) {
+		t.Error("expected a.b[2].c to equal a.b.2.c")
+	}
+	if ns.Equal(parse("a.b[2]")) {
+		t.Error("expected a.b[2].c not to equal a.b[2]")
+	}
+
+	for _, prefix := range []string{"", "a", "a.b", "a.b[2]", "a.b[2].c"} {
+		if !ns.HasPrefix(parse(prefix)) {
+			t.Errorf("expected %q to be a prefix", prefix)
+		}
+	}
+	for _, prefix := range []string{"b", "a.bc", "a.b[3]", "a.b[2].c.d"} {
+		if ns.HasPrefix(parse(prefix)) {
+			t.Errorf("expected %q not to be a prefix", prefix)
+		}
+	}
+
+	built := parse("").Append("a").Append("b").AppendIndex(2).Append("c")
+	if !built.Equal(ns) {
+		t.Errorf("expected %q to equal %q", built, ns)
+	}
+}
+
+func TestDecodingError_Namespace(t *testing.T) {
+	t.Parallel()
+
+	type Server struct {
+		Ports []int
+	}
+
+	input := map[string]interface{}{
+		"Ports": []interface{}{80, "http"},
+	}
+
+	var result Server
+	err := Decode(input, &result)
+
+	var derr *DecodingError
+	if !errors.As(err, &derr) {
+		t.Fatalf("expected a DecodingError, got %#v", err)
+	}
+
+	prefix, _ := ParseNamespace("Ports")
+	if !derr.Namespace().HasPrefix(prefix) {
+		t.Fatalf("expected %q to have prefix %q", derr.Name, prefix)
+	}
+
+	// A name that can't be parsed is a single key.
+	derr = &DecodingError{Name: "a..b"}
+	ns := derr.Namespace()
+	if ns == nil || ns.Len() != 1 {
+		t.Fatalf("bad: %#v", ns)
+	}
+}
@@ -0,0 +1,189 @@
+package mapstructure
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// NumberPolicy controls how numbers are represented when they are decoded
+// into an interface{}. Without a policy numbers keep the type they have in
+// the input, which depends on where the input came from: encoding/json
+// produces float64 or json.Number, most YAML decoders produce int and
+// float64, and structs encoded into maps keep their field types.
+type NumberPolicy int
+
+const (
+	// NumbersAsIs keeps numbers as they are in the input.
+	NumbersAsIs NumberPolicy = iota
+
+	// NumbersAsJSONNumber converts numbers to json.Number, which keeps
+	// their exact value when they are encoded as JSON again.
+	NumbersAsJSONNumber
+
+	// NumbersAsFloat64 converts numbers to float64, like encoding/json
+	// does by default.
+	NumbersAsFloat64
+
+	// NumbersAsInt64WhenExact converts numbers that are integers and fit
+	// in an int64 to int64, and all other numbers to float64. Unsigned
+	// integers that don't fit in an int64 are left as they are.
+	NumbersAsInt64WhenExact
+
+	// NumbersNormalized converts integers of any kind to int64 and floats
+	// to float64, so that type switches on the result only need to handle
+	// those two. Unsigned integers that don't fit in an int64 become
+	// uint64, and a json.Number becomes an int64 if it is an integer that
+	// fits and a float64 otherwise.
+	NumbersNormalized
+)
+
+// NumberType reads the number held by a value of a type that isn't one of
+// Go's numeric types, such as json.Number or the type of a decimal
+// library, so that the value can be decoded into ints, uints and floats.
+// A value can't be decoded into a kind whose function is nil. See
+// NumberTypes in DecoderConfig.
+type NumberType struct {
+	// Int64 returns the number as an int64, for decoding into an int.
+	Int64 func(v interface{}) (int64, error)
+
+	// Uint64 returns the number as a uint64, for decoding into a uint.
+	Uint64 func(v interface{}) (uint64, error)
+
+	// Float64 returns the number as a float64, for decoding into a float.
+	Float64 func(v interface{}) (float64, error)
+}
+
+// defaultNumberTypes are the number types that are known without being
+// given in NumberTypes.
+var defaultNumberTypes = map[reflect.Type]NumberType{
+	reflect.TypeOf(json.Number("")): {
+		Int64: func(v interface{}) (int64, error) {
+			return v.(json.Number).Int64()
+		},
+		Uint64: func(v interface{}) (uint64, error) {
+			return strconv.ParseUint(string(v.(json.Number)), 0, 64)
+		},
+		Float64: func(v interface{}) (float64, error) {
+			return v.(json.Number).Float64()
+		},
+	},
+}
+
+// numberType returns the NumberType of typ, or the zero NumberType if typ
+// isn't a number type.
+func (d *Decoder) numberType(typ reflect.Type) NumberType {
+	if number, ok := d.config.NumberTypes[typ]; ok {
+		return number
+	}
+
+	return defaultNumberTypes[typ]
+}
+
+// convertNumbers applies policy to v if it is a number, and to the
+// elements of v if it is a map[string]interface{},
+// map[interface{}]interface{} or []interface{}. Containers are copied
+// rather than modified.
+func convertNumbers(v interface{}, policy NumberPolicy) (interface{}, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, elem := range v {
+			converted, err := convertNumbers(elem, policy)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = converted
+		}
+		return m, nil
+
+	case map[interface{}]interface{}:
+		m := make(map[interface{}]interface{}, len(v))
+		for k, elem := range v {
+			converted, err := convertNumbers(elem, policy)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = converted
+		}
+		return m, nil
+
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, elem := range v {
+			converted, err := convertNumbers(elem, policy)
+			if err != nil {
+				return nil, err
+			}
+			s[i] = converted
+		}
+		return s, nil
+
+	case json.Number:
+		switch policy {
+		case NumbersAsFloat64:
+			return v.Float64()
+		case NumbersAsInt64WhenExact, NumbersNormalized:
+			if i, err := v.Int64(); err == nil {
+				return i, nil
+			}
+			f, err := v.Float64()
+			if err != nil {
+				return nil, err
+			}
+			return convertNumbers(f, policy)
+		default:
+			return v, nil
+		}
+	}
+
+	val := reflect.ValueOf(v)
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := val.Int()
+		switch policy {
+		case NumbersAsJSONNumber:
+			return json.Number(strconv.FormatInt(i, 10)), nil
+		case NumbersAsFloat64:
+			return float64(i), nil
+		case NumbersAsInt64WhenExact, NumbersNormalized:
+			return i, nil
+		}
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := val.Uint()
+		switch policy {
+		case NumbersAsJSONNumber:
+			return json.Number(strconv.FormatUint(u, 10)), nil
+		case NumbersAsFloat64:
+			return float64(u), nil
+		case NumbersAsInt64WhenExact:
+			if u <= math.MaxInt64 {
+				return int64(u), nil
+			}
+		case NumbersNormalized:
+			if u <= math.MaxInt64 {
+				return int64(u), nil
+			}
+			return u, nil
+		}
+
+	case reflect.Float32, reflect.Float64:
+		f := val.Float()
+		switch policy {
+		case NumbersAsJSONNumber:
+			return json.Number(strconv.FormatFloat(f, 'g', -1, val.Type().Bits())), nil
+		case NumbersAsFloat64, NumbersNormalized:
+			return f, nil
+		case NumbersAsInt64WhenExact:
+			// float64(math.MaxInt64) rounds up to 2^63, which doesn't fit
+			if f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+				return int64(f), nil
+			}
+			return f, nil
+		}
+	}
+
+	return v, nil
+}
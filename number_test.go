@@ -0,0 +1,191 @@
+package mapstructure
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestDecoder_PreserveNumbers(t *testing.T) {
+	t.Parallel()
+
+	input := map[string]interface{}{
+		"int":    int8(3),
+		"uint":   uint64(math.MaxUint64),
+		"whole":  2.0,
+		"frac":   2.5,
+		"number": json.Number("7"),
+		"nested": []interface{}{
+			map[string]interface{}{"n": uint16(4)},
+		},
+	}
+
+	cases := []struct {
+		policy   NumberPolicy
+		expected map[string]interface{}
+	}{
+		{
+			NumbersAsIs,
+			input,
+		},
+		{
+			NumbersAsJSONNumber,
+			map[string]interface{}{
+				"int":    json.Number("3"),
+				"uint":   json.Number("18446744073709551615"),
+				"whole":  json.Number("2"),
+				"frac":   json.Number("2.5"),
+				"number": json.Number("7"),
+				"nested": []interface{}{
+					map[string]interface{}{"n": json.Number("4")},
+				},
+			},
+		},
+		{
+			NumbersAsFloat64,
+			map[string]interface{}{
+				"int":    3.0,
+				"uint":   float64(math.MaxUint64),
+				"whole":  2.0,
+				"frac":   2.5,
+				"number": 7.0,
+				"nested": []interface{}{
+					map[string]interface{}{"n": 4.0},
+				},
+			},
+		},
+		{
+			NumbersAsInt64WhenExact,
+			map[string]interface{}{
+				"int":    int64(3),
+				"uint":   uint64(math.MaxUint64),
+				"whole":  int64(2),
+				"frac":   2.5,
+				"number": int64(7),
+				"nested": []interface{}{
+					map[string]interface{}{"n": int64(4)},
+				},
+			},
+		},
+		{
+			NumbersNormalized,
+			map[string]interface{}{
+				"int":    int64(3),
+				"uint":   uint64(math.MaxUint64),
+				"whole":  2.0,
+				"frac":   2.5,
+				"number": int64(7),
+				"nested": []interface{}{
+					map[string]interface{}{"n": int64(4)},
+				},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		var result map[string]interface{}
+		config := &DecoderConfig{
+			Result:          &result,
+			PreserveNumbers: tc.policy,
+		}
+
+		decoder, err := NewDecoder(config)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		if err := decoder.Decode(input); err != nil {
+			t.Fatalf("policy %d: err: %s", tc.policy, err)
+		}
+
+		if !reflect.DeepEqual(result, tc.expected) {
+			t.Errorf("policy %d: bad: %#v", tc.policy, result)
+		}
+	}
+}
+
+func TestDecoder_PreserveNumbers_invalid(t *testing.T) {
+	t.Parallel()
+
+	var result interface{}
+	config := &DecoderConfig{
+		Result:          &result,
+		PreserveNumbers: NumbersAsFloat64,
+	}
+
+	decoder, err := NewDecoder(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := decoder.Decode(json.Number("x")); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+// cents is a fixed-point number type, like those of decimal libraries.
+type cents struct {
+	units int64
+}
+
+func TestDecoder_NumberTypes(t *testing.T) {
+	t.Parallel()
+
+	type Result struct {
+		Int     int
+		Uint    uint
+		Float   float64
+		Pointer int
+		JSON    int
+	}
+
+	input := map[string]interface{}{
+		"int":     cents{1250},
+		"uint":    cents{300},
+		"float":   cents{1250},
+		"pointer": &cents{700},
+		"json":    json.Number("42"),
+	}
+
+	var result Result
+	decoder, err := NewDecoder(&DecoderConfig{
+		Result: &result,
+		NumberTypes: map[reflect.Type]NumberType{
+			reflect.TypeOf(cents{}): {
+				Int64: func(v interface{}) (int64, error) {
+					return v.(cents).units / 100, nil
+				},
+				Float64: func(v interface{}) (float64, error) {
+					return float64(v.(cents).units) / 100, nil
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	err = decoder.Decode(input)
+	expected := Result{Int: 12, Float: 12.5, Pointer: 7, JSON: 42}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	// There is no Uint64 function to decode into a uint.
+	var derr *DecodingError
+	if !errors.As(err, &derr) || derr.Name != "Uint" || derr.Kind != DecodingErrorUnconvertibleType {
+		t.Fatalf("bad: %#v", err)
+	}
+}
+
+func TestDecoder_NumberTypesError(t *testing.T) {
+	t.Parallel()
+
+	var result int
+	err := Decode(json.Number("1.5"), &result)
+	if err == nil || err.Error() != "error decoding json.Number into : strconv.ParseInt: parsing \"1.5\": invalid syntax" {
+		t.Fatalf("bad: %v", err)
+	}
+}
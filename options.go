@@ -0,0 +1,53 @@
+package mapstructure
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Options can be embedded in a struct to override parts of the
+// DecoderConfig for that struct type alone. The options are given in the
+// tag of the embedded field:
+//
+//	type Request struct {
+//		mapstructure.Options `mapstructure:",ignoreuntagged"`
+//
+//		ID   string `mapstructure:"id"`
+//		Seen bool   // not encoded
+//	}
+//
+// The supported options are "ignoreuntagged" and "includeuntagged", which
+// turn IgnoreUntaggedFields on and off for the fields of the struct. The
+// Options field itself is never decoded or encoded.
+type Options struct{}
+
+var optionsType = reflect.TypeOf(Options{})
+
+// structOptions returns the options in the tag of the Options field of
+// the struct type typ, if it has one.
+func (d *Decoder) structOptions(typ reflect.Type) []string {
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.Type == optionsType {
+			return strings.Split(f.Tag.Get(d.config.TagName), ",")[1:]
+		}
+	}
+
+	return nil
+}
+
+// ignoreUntagged reports whether the untagged fields of the struct type
+// typ are ignored, taking its Options into account.
+func (d *Decoder) ignoreUntagged(typ reflect.Type) bool {
+	ignore := d.config.IgnoreUntaggedFields
+	for _, opt := range d.structOptions(typ) {
+		switch opt {
+		case "ignoreuntagged":
+			ignore = true
+		case "includeuntagged":
+			ignore = false
+		}
+	}
+
+	return ignore
+}
@@ -0,0 +1,106 @@
+package mapstructure
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOptions_ignoreUntagged(t *testing.T) {
+	t.Parallel()
+
+	type Strict struct {
+		Options `mapstructure:",ignoreuntagged"`
+
+		ID   string `mapstructure:"id"`
+		Seen bool
+	}
+
+	type Loose struct {
+		Options `mapstructure:",includeuntagged"`
+
+		Name string
+	}
+
+	type Envelope struct {
+		Strict Strict `mapstructure:"strict"`
+		Loose  Loose  `mapstructure:"loose"`
+		Other  string
+	}
+
+	input := Envelope{
+		Strict: Strict{ID: "1", Seen: true},
+		Loose:  Loose{Name: "n"},
+		Other:  "o",
+	}
+
+	cases := []struct {
+		ignore   bool
+		expected map[string]interface{}
+	}{
+		{
+			false,
+			map[string]interface{}{
+				"strict": map[string]interface{}{"id": "1"},
+				"loose":  map[string]interface{}{"Name": "n"},
+				"Other":  "o",
+			},
+		},
+		{
+			true,
+			map[string]interface{}{
+				"strict": map[string]interface{}{"id": "1"},
+				"loose":  map[string]interface{}{"Name": "n"},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		var result map[string]interface{}
+		config := &DecoderConfig{
+			Result:               &result,
+			IgnoreUntaggedFields: tc.ignore,
+		}
+
+		decoder, err := NewDecoder(config)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		if err := decoder.Decode(input); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		if !reflect.DeepEqual(result, tc.expected) {
+			t.Errorf("IgnoreUntaggedFields %t: bad: %#v", tc.ignore, result)
+		}
+	}
+}
+
+func TestOptions_notDecoded(t *testing.T) {
+	t.Parallel()
+
+	type Strict struct {
+		Options `mapstructure:",ignoreuntagged"`
+
+		ID string `mapstructure:"id"`
+	}
+
+	var result Strict
+	config := &DecoderConfig{
+		Result:     &result,
+		ErrorUnset: true,
+	}
+
+	decoder, err := NewDecoder(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := decoder.Decode(map[string]interface{}{"id": "1"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if result.ID != "1" {
+		t.Fatalf("bad: %#v", result)
+	}
+}
@@ -0,0 +1,408 @@
+package mapstructure
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// KeyValue is a single entry of an OrderedMap. A []KeyValue, like other
+// slices of key/value pairs, is decoded like a map with its keys in order,
+// and it is an error for a key to appear in it twice.
+type KeyValue struct {
+	Key   string
+	Value interface{}
+}
+
+// OrderedMap is a map with string keys that remembers the order its keys
+// were set in. It can be used as the Result of a decode to keep the order
+// of the input: structs are encoded in the order their fields are
+// declared, and Sources, such as Nodes of a parsed document, in the order
+// of their keys. Nested structs and maps become nested *OrderedMaps, and
+// Go maps, which have no order, are encoded with their keys sorted.
+//
+// An *OrderedMap is a Source, so it can also be decoded like a map. The
+// zero value is an empty map ready to use.
+type OrderedMap struct {
+	pairs []KeyValue
+	index map[string]int
+}
+
+// Len returns the number of keys in the map.
+func (m *OrderedMap) Len() int {
+	return len(m.pairs)
+}
+
+// Keys returns the keys of the map in order.
+func (m *OrderedMap) Keys() []string {
+	keys := make([]string, len(m.pairs))
+	for i, p := range m.pairs {
+		keys[i] = p.Key
+	}
+
+	return keys
+}
+
+// Get returns the value for key and whether it exists.
+func (m *OrderedMap) Get(key string) (interface{}, bool) {
+	i, ok := m.index[key]
+	if !ok {
+		return nil, false
+	}
+
+	return m.pairs[i].Value, true
+}
+
+// Set sets the value for key. A new key is added at the end of the map,
+// an existing key keeps its position.
+func (m *OrderedMap) Set(key string, value interface{}) {
+	if i, ok := m.index[key]; ok {
+		m.pairs[i].Value = value
+		return
+	}
+
+	if m.index == nil {
+		m.index = make(map[string]int)
+	}
+	m.index[key] = len(m.pairs)
+	m.pairs = append(m.pairs, KeyValue{Key: key, Value: value})
+}
+
+// Delete removes key from the map.
+func (m *OrderedMap) Delete(key string) {
+	i, ok := m.index[key]
+	if !ok {
+		return
+	}
+
+	m.pairs = append(m.pairs[:i], m.pairs[i+1:]...)
+	delete(m.index, key)
+	for j := i; j < len(m.pairs); j++ {
+		m.index[m.pairs[j].Key] = j
+	}
+}
+
+// Pairs returns the entries of the map in order. The slice must not be
+// modified.
+func (m *OrderedMap) Pairs() []KeyValue {
+	return m.pairs
+}
+
+// MarshalJSON encodes the map as a JSON object with its keys in order.
+func (m *OrderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, p := range m.pairs {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		key, err := json.Marshal(p.Key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+
+		value, err := json.Marshal(p.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// reorder puts the keys of m in the order given by order, which is called
+// with the current keys and reorders them in place.
+func (m *OrderedMap) reorder(order func(keys []string)) {
+	if order == nil {
+		return
+	}
+
+	keys := m.Keys()
+	order(keys)
+
+	pairs := make([]KeyValue, 0, len(m.pairs))
+	for _, key := range keys {
+		if i, ok := m.index[key]; ok {
+			pairs = append(pairs, m.pairs[i])
+		}
+	}
+
+	m.pairs = pairs
+	for i, p := range m.pairs {
+		m.index[p.Key] = i
+	}
+}
+
+var orderedMapType = reflect.TypeOf(OrderedMap{})
+
+// decodeOrderedMap decodes a struct or a map-like input into an
+// OrderedMap, adding its keys to the ones already in val.
+func (d *Decoder) decodeOrderedMap(name string, data interface{}, val reflect.Value) error {
+	m := val.Addr().Interface().(*OrderedMap)
+	if d.config.ZeroFields {
+		*m = OrderedMap{}
+	}
+
+	if mar, ok := marshaler(reflect.ValueOf(data)); ok {
+		marshaled, err := d.marshal(name, mar)
+		if err != nil {
+			return err
+		}
+		data = marshaled
+	}
+
+	data, err := d.pairsInput(name, data)
+	if err != nil {
+		return err
+	}
+
+	switch data.(type) {
+	case Source, Node, OrderedMap:
+	default:
+		if dataVal := reflect.Indirect(reflect.ValueOf(data)); dataVal.Kind() == reflect.Struct {
+			if err := d.decodeOrderedMapFromStruct(name, dataVal, m); err != nil {
+				return err
+			}
+
+			m.reorder(d.config.KeyOrder)
+			return nil
+		}
+	}
+
+	v, err := d.orderedValue(name, data)
+	if err != nil {
+		return err
+	}
+
+	src, ok := v.(*OrderedMap)
+	if !ok {
+		return d.decodingError(MsgExpectedMap, &DecodingError{
+			Kind:  DecodingErrorUnconvertibleType,
+			Name:  name,
+			Got:   reflect.ValueOf(data).Kind().String(),
+			Value: data,
+		})
+	}
+
+	for _, p := range src.pairs {
+		m.Set(p.Key, p.Value)
+	}
+	m.reorder(d.config.KeyOrder)
+
+	return nil
+}
+
+// orderedValue converts the maps, Sources and Nodes in v into
+// *OrderedMaps, the elements of []interface{}s included. Other values are
+// returned as they are.
+func (d *Decoder) orderedValue(name string, v interface{}) (interface{}, error) {
+	if node, ok := v.(Node); ok {
+		var err error
+		if v, err = node.Value(); err != nil {
+			return nil, withPosition(d.decodingError(MsgNodeFailure, &DecodingError{
+				Kind: DecodingErrorGeneric,
+				Name: name,
+				Err:  err,
+			}), node.Position())
+		}
+	}
+
+	var keys []string
+	var get func(string) interface{}
+	switch src := v.(type) {
+	case OrderedMap:
+		return d.orderedValue(name, &src)
+
+	case Source:
+		keys = src.Keys()
+		get = func(key string) interface{} {
+			v, _ := src.Get(key)
+			return v
+		}
+
+	case []interface{}:
+		s := make([]interface{}, len(src))
+		for i, elem := range src {
+			converted, err := d.orderedValue(name, elem)
+			if err != nil {
+				return nil, err
+			}
+			s[i] = converted
+		}
+		return s, nil
+
+	default:
+		val := reflect.Indirect(reflect.ValueOf(mapInput(v)))
+		if val.Kind() != reflect.Map {
+			return v, nil
+		}
+
+		byKey := make(map[string]reflect.Value, val.Len())
+		for _, k := range val.MapKeys() {
+			key := k
+			if key.Kind() == reflect.Interface {
+				key = key.Elem()
+			}
+			if key.Kind() != reflect.String {
+				// Only maps with string keys can be ordered
+				return v, nil
+			}
+
+			keys = append(keys, key.String())
+			byKey[key.String()] = val.MapIndex(k)
+		}
+		sort.Strings(keys)
+		get = func(key string) interface{} {
+			return byKey[key].Interface()
+		}
+	}
+
+	m := &OrderedMap{}
+	for _, key := range keys {
+		fieldName := key
+		if name != "" {
+			fieldName = name + "." + key
+		}
+
+		converted, err := d.orderedValue(fieldName, get(key))
+		if err != nil {
+			return nil, withSourceKey(err, key)
+		}
+		m.Set(key, converted)
+	}
+	m.reorder(d.config.KeyOrder)
+
+	return m, nil
+}
+
+// decodeOrderedMapFromStruct encodes the fields of a struct into m in the
+// order they are declared. Nested structs are encoded into nested
+// *OrderedMaps.
+func (d *Decoder) decodeOrderedMapFromStruct(name string, dataVal reflect.Value, m *OrderedMap) error {
+	remains, err := d.walkStructFields(name, dataVal, func(f encodedField) error {
+		if f.squash {
+			mar, _ := marshaler(f.value)
+			return d.squashMarshaled(name, mar, m)
+		}
+
+		v := f.value
+		if mar, ok := marshaler(v); ok {
+			marshaled, err := d.marshal(f.name, mar)
+			if err != nil {
+				return err
+			}
+
+			converted, err := d.orderedValue(f.name, marshaled)
+			if err != nil {
+				return withSourceKey(err, f.field.Name)
+			}
+			m.Set(f.key, converted)
+			return nil
+		}
+
+		if str, ok := d.stringerValue(v); ok {
+			m.Set(f.key, str)
+			return nil
+		}
+
+		if strs, ok := d.stringsValue(v); ok {
+			m.Set(f.key, strs.Interface())
+			return nil
+		}
+
+		if d.encodeAsString(v, f.tag) {
+			str, _ := formatScalar(reflect.Indirect(v))
+			m.Set(f.key, str)
+			return nil
+		}
+
+		v = dereferencePtrToStructIfNeeded(v, d.config.TagName)
+		if v.Kind() == reflect.Struct && v.Type() != orderedMapType {
+			nested := &OrderedMap{}
+			if err := d.decodeOrderedMapFromStruct(f.name, v, nested); err != nil {
+				return withSourceKey(err, f.field.Name)
+			}
+			nested.reorder(d.config.KeyOrder)
+			m.Set(f.key, nested)
+			return nil
+		}
+
+		converted, err := d.orderedValue(f.name, v.Interface())
+		if err != nil {
+			return withSourceKey(err, f.field.Name)
+		}
+		m.Set(f.key, converted)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// The entries of the "remain" and squashed maps follow the other
+	// fields, sorted by key, and never replace them.
+	for _, spliced := range remains {
+		remain := spliced.m
+		keys := make([]string, 0, remain.Len())
+		for _, k := range remain.MapKeys() {
+			if key, ok := k.Interface().(string); ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			prefixed := spliced.prefix + key
+			if _, ok := m.Get(prefixed); ok {
+				continue
+			}
+
+			fieldName := prefixed
+			if name != "" {
+				fieldName = name + "." + prefixed
+			}
+			converted, err := d.orderedValue(fieldName, remain.MapIndex(reflect.ValueOf(key).Convert(remain.Type().Key())).Interface())
+			if err != nil {
+				return withSourceKey(err, prefixed)
+			}
+			m.Set(prefixed, converted)
+		}
+	}
+
+	return nil
+}
+
+// squashMarshaled adds the keys of the map that the squashed Marshaler mar
+// is encoded into to m.
+func (d *Decoder) squashMarshaled(name string, mar Marshaler, m *OrderedMap) error {
+	marshaled, err := d.marshal(name, mar)
+	if err != nil {
+		return err
+	}
+
+	converted, err := d.orderedValue(name, marshaled)
+	if err != nil {
+		return err
+	}
+
+	nested, ok := converted.(*OrderedMap)
+	if !ok {
+		return d.decodingError(MsgSquashNonStruct, &DecodingError{
+			Kind: DecodingErrorInvalidSquash,
+			Name: name,
+			Got:  fmt.Sprintf("%T", marshaled),
+		})
+	}
+
+	for _, p := range nested.pairs {
+		m.Set(p.Key, p.Value)
+	}
+
+	return nil
+}
@@ -0,0 +1,254 @@
+package mapstructure
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestOrderedMap(t *testing.T) {
+	t.Parallel()
+
+	var m OrderedMap
+	m.Set("b", 1)
+	m.Set("a", 2)
+	m.Set("c", 3)
+	m.Set("b", 4)
+	m.Delete("a")
+	m.Delete("missing")
+
+	if !reflect.DeepEqual(m.Keys(), []string{"b", "c"}) {
+		t.Fatalf("bad keys: %#v", m.Keys())
+	}
+	if v, ok := m.Get("b"); !ok || v != 4 {
+		t.Fatalf("bad value: %#v", v)
+	}
+	if _, ok := m.Get("a"); ok {
+		t.Fatal("expected a to be deleted")
+	}
+
+	m.Set("a", 5)
+	out, err := json.Marshal(&m)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(out) != `{"b":4,"c":3,"a":5}` {
+		t.Fatalf("bad: %s", out)
+	}
+}
+
+func TestDecode_OrderedMapFromStruct(t *testing.T) {
+	t.Parallel()
+
+	type Embedded struct {
+		Zone string
+	}
+
+	type Listener struct {
+		Port     int
+		Protocol string
+	}
+
+	type Server struct {
+		Name     string
+		Embedded `mapstructure:",squash"`
+		Listener *Listener
+		Labels   map[string]string
+		Skipped  string `mapstructure:"-"`
+		Address  string `mapstructure:"addr"`
+	}
+
+	input := Server{
+		Name:     "web",
+		Embedded: Embedded{Zone: "eu"},
+		Listener: &Listener{Port: 80, Protocol: "http"},
+		Labels:   map[string]string{"z": "1", "a": "2"},
+		Address:  "10.0.0.1",
+	}
+
+	var result OrderedMap
+	if err := Decode(input, &result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	out, err := json.Marshal(&result)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := `{"Name":"web","Zone":"eu","Listener":{"Port":80,"Protocol":"http"},"Labels":{"a":"2","z":"1"},"addr":"10.0.0.1"}`
+	if string(out) != expected {
+		t.Fatalf("bad: %s", out)
+	}
+
+	// An OrderedMap can be decoded like any other map
+	var roundTrip Server
+	if err := Decode(&result, &roundTrip); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(roundTrip, input) {
+		t.Fatalf("bad: %#v", roundTrip)
+	}
+}
+
+func TestDecode_OrderedMapFromStructRemain(t *testing.T) {
+	t.Parallel()
+
+	type Config struct {
+		Name  string
+		Other map[string]interface{} `mapstructure:",remain"`
+		Port  int
+	}
+
+	input := Config{
+		Name:  "web",
+		Other: map[string]interface{}{"z": 1, "a": 2, "Port": 0},
+		Port:  80,
+	}
+
+	var result OrderedMap
+	if err := Decode(input, &result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	out, err := json.Marshal(&result)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := `{"Name":"web","Port":80,"a":2,"z":1}`
+	if string(out) != expected {
+		t.Fatalf("bad: %s", out)
+	}
+}
+
+func TestDecode_OrderedMapFromStructSquashMap(t *testing.T) {
+	t.Parallel()
+
+	type Pod struct {
+		Name   string
+		Labels map[string]string `mapstructure:",squash,prefix=label_"`
+		Port   int
+	}
+
+	input := Pod{
+		Name:   "web",
+		Labels: map[string]string{"tier": "frontend", "app": "web"},
+		Port:   80,
+	}
+
+	var result OrderedMap
+	if err := Decode(input, &result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	out, err := json.Marshal(&result)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := `{"Name":"web","Port":80,"label_app":"web","label_tier":"frontend"}`
+	if string(out) != expected {
+		t.Fatalf("bad: %s", out)
+	}
+}
+
+func TestDecode_OrderedMapFromStructSquashInterface(t *testing.T) {
+	t.Parallel()
+
+	type Options struct {
+		Name          string
+		PluginOptions `mapstructure:",squash"`
+	}
+
+	input := Options{
+		Name:          "api",
+		PluginOptions: &HTTPPluginOptions{Address: ":8080", Timeout: 5},
+	}
+
+	var result OrderedMap
+	if err := Decode(input, &result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	out, err := json.Marshal(&result)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := `{"Name":"api","Address":":8080","Timeout":5}`
+	if string(out) != expected {
+		t.Fatalf("bad: %s", out)
+	}
+}
+
+func TestDecode_OrderedMapFromSource(t *testing.T) {
+	t.Parallel()
+
+	// The source keeps the order of its keys, which a map would lose
+	input := &testMapping{
+		keys: []string{"z", "a", "m"},
+		values: []*testNode{
+			{line: 1, value: 1},
+			{line: 2, value: &testMapping{
+				keys:   []string{"y", "b"},
+				values: []*testNode{{line: 2, value: true}, {line: 3, value: false}},
+			}},
+			{line: 4, value: []interface{}{&testNode{line: 4, value: "x"}}},
+		},
+	}
+
+	result := &OrderedMap{}
+	if err := Decode(input, &result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	out, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if string(out) != `{"z":1,"a":{"y":true,"b":false},"m":["x"]}` {
+		t.Fatalf("bad: %s", out)
+	}
+}
+
+func TestDecoder_KeyOrder(t *testing.T) {
+	t.Parallel()
+
+	type Inner struct {
+		Z int
+		A int
+	}
+
+	type Outer struct {
+		Name  string
+		Inner Inner
+		Age   int
+	}
+
+	var result OrderedMap
+	config := &DecoderConfig{
+		Result:   &result,
+		KeyOrder: sort.Strings,
+	}
+
+	decoder, err := NewDecoder(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := decoder.Decode(Outer{Name: "n", Inner: Inner{Z: 1, A: 2}, Age: 3}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	out, err := json.Marshal(&result)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if string(out) != `{"Age":3,"Inner":{"A":2,"Z":1},"Name":"n"}` {
+		t.Fatalf("bad: %s", out)
+	}
+}
@@ -0,0 +1,587 @@
+package mapstructure
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Flatten returns a map with the nested maps and slices of input flattened
+// into keys joined with sep, so that
+//
+//	{"server": {"ports": [80, 443]}}
+//
+// becomes {"server.ports[0]": 80, "server.ports[1]": 443}. Keys that
+// contain sep, brackets or a backslash are escaped with a backslash, the
+// same way DecoderConfig.FlatKeySeparator expects them.
+func Flatten(input map[string]interface{}, sep string) map[string]interface{} {
+	result := make(map[string]interface{}, len(input))
+	for k, v := range input {
+		// Without a struct encoder flattening can't fail.
+		_ = flattenValue(result, escapePathKey(k, sep), v, sep, nil)
+	}
+
+	return result
+}
+
+// Unflatten is the reverse of Flatten: it expands the keys of input into
+// nested maps and slices, the same way the decoder does when
+// DecoderConfig.FlatKeySeparator is set. An error is returned if a key
+// is malformed or if two keys conflict, such as "a" and "a.b".
+func Unflatten(input map[string]interface{}, sep string) (map[string]interface{}, error) {
+	result, err := expandFlatKeys(input, sep)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.(map[string]interface{}), nil
+}
+
+// Extract returns the value at path in input, converted to T with the
+// same rules and hooks as Decode. The path uses the syntax described for
+// PathTagName in DecoderConfig, so that
+//
+//	port, err := Extract[int](config, "servers[0].http.port")
+//
+// replaces a chain of type assertions. If a config is given, it is used
+// for the conversion; its Result is ignored. An error of kind
+// DecodingErrorPathNotFound is returned if there is no value at path.
+func Extract[T any](input interface{}, path string, config ...*DecoderConfig) (T, error) {
+	var result T
+
+	c := copyConfig(config...)
+	c.Result = &result
+
+	d, err := NewDecoder(c)
+	if err != nil {
+		return result, err
+	}
+
+	err = d.DecodeAt(input, path)
+	return result, err
+}
+
+// Set sets the value at path in m, creating the maps and slices along the
+// way. The path uses the syntax described for PathTagName in
+// DecoderConfig, except that wildcards aren't allowed. Slices are grown as
+// needed to hold an index, up to an index of 65535. Any value that is
+// already at path is replaced, but an error is returned if a value along
+// the path is neither a map[string]interface{} nor a []interface{} of the
+// expected kind.
+func Set(m map[string]interface{}, path string, value interface{}) error {
+	segments, err := parsePath(path, ".")
+	if err != nil {
+		return err
+	}
+
+	if segments[0].isIndex {
+		return fmt.Errorf("%q must start with a key", path)
+	}
+
+	// The path is checked in full before anything is inserted, so that m
+	// is left unchanged if it is invalid.
+	for _, seg := range segments {
+		if seg.isWildcard {
+			return fmt.Errorf("%q can't contain wildcards", path)
+		}
+		if seg.isIndex && seg.index > maxPathIndex {
+			return fmt.Errorf("%q has index %d, larger than the maximum of %d", path, seg.index, maxPathIndex)
+		}
+	}
+
+	child := m[segments[0].key]
+	if err := insertPath(&child, segments[1:], value, false); err != nil {
+		return fmt.Errorf("%q %s", path, err)
+	}
+	m[segments[0].key] = child
+
+	return nil
+}
+
+// pathSegment is a single step of a path: either a map key or, when
+// written in brackets like "[0]", a slice index. An unescaped "*" key is
+// a wildcard that matches every element of a map or slice.
+type pathSegment struct {
+	key        string
+	index      int
+	isIndex    bool
+	isWildcard bool
+}
+
+func (s pathSegment) String() string {
+	if s.isIndex {
+		return strconv.Itoa(s.index)
+	}
+
+	return s.key
+}
+
+// parsePath splits a path such as "servers[0].http.port" into its
+// segments. Keys are separated by sep and indexes are written in brackets.
+// A backslash escapes the character that follows it, so that keys can
+// contain the separator or brackets, as in "labels.app\.kubernetes\.io".
+func parsePath(path, sep string) ([]pathSegment, error) {
+	return parseSegments(path, sep, false)
+}
+
+// parseSegments is parsePath, except that if bracketKeys is true, text in
+// brackets that isn't an index is read as a key, the way the decoder
+// names map elements in errors, like "Labels[app]".
+func parseSegments(path, sep string, bracketKeys bool) ([]pathSegment, error) {
+	var segments []pathSegment
+	var key strings.Builder
+	// bracketed is set when the last segment was written in brackets, and
+	// so doesn't need a separator or the end of the path to finish it.
+	hasKey, escaped, bracketed := false, false, false
+
+	flush := func() error {
+		if !hasKey {
+			return fmt.Errorf("empty key in path %q", path)
+		}
+		segments = append(segments, pathSegment{
+			key:        key.String(),
+			isWildcard: key.String() == "*" && !escaped,
+		})
+		key.Reset()
+		hasKey, escaped, bracketed = false, false, false
+		return nil
+	}
+
+	for i := 0; i < len(path); i++ {
+		switch {
+		case path[i] == '\\' && i+1 < len(path):
+			i++
+			key.WriteByte(path[i])
+			hasKey, escaped = true, true
+
+		case sep != "" && strings.HasPrefix(path[i:], sep):
+			if hasKey || !bracketed {
+				if err := flush(); err != nil {
+					return nil, err
+				}
+			}
+			bracketed = false
+			i += len(sep) - 1
+
+		case path[i] == '[':
+			if hasKey {
+				if err := flush(); err != nil {
+					return nil, err
+				}
+			}
+
+			end := strings.IndexByte(path[i:], ']')
+			if end == -1 {
+				return nil, fmt.Errorf("unterminated index in path %q", path)
+			}
+
+			content := path[i+1 : i+end]
+			index, err := strconv.Atoi(content)
+			switch {
+			case err == nil && index >= 0:
+				segments = append(segments, pathSegment{index: index, isIndex: true})
+			case bracketKeys && content != "":
+				segments = append(segments, pathSegment{key: content})
+			default:
+				return nil, fmt.Errorf("invalid index %q in path %q", content, path)
+			}
+			bracketed = true
+			i += end
+
+		default:
+			key.WriteByte(path[i])
+			hasKey = true
+		}
+	}
+
+	if hasKey || !bracketed {
+		if err := flush(); err != nil {
+			return nil, err
+		}
+	}
+
+	return segments, nil
+}
+
+// expandFlatKeys expands the keys of all the string-keyed maps in input
+// into nested maps and slices, so that {"server.ports[1]": 80} becomes
+// {"server": {"ports": [nil, 80]}}. Maps with keys that aren't strings are
+// left as they are.
+func expandFlatKeys(input interface{}, sep string) (interface{}, error) {
+	val := reflect.ValueOf(input)
+	switch val.Kind() {
+	case reflect.Map:
+		if val.Type().Key().Kind() != reflect.String && val.Type().Key().Kind() != reflect.Interface {
+			return input, nil
+		}
+
+		result := make(map[string]interface{}, val.Len())
+		for _, k := range val.MapKeys() {
+			key, ok := k.Interface().(string)
+			if !ok {
+				return input, nil
+			}
+
+			segments, err := parsePath(key, sep)
+			if err != nil {
+				return nil, err
+			}
+
+			v, err := expandFlatKeys(val.MapIndex(k).Interface(), sep)
+			if err != nil {
+				return nil, err
+			}
+
+			var root interface{} = result
+			if err := insertPath(&root, segments, v, true); err != nil {
+				return nil, fmt.Errorf("%q %s", key, err)
+			}
+		}
+
+		return result, nil
+
+	case reflect.Slice, reflect.Array:
+		if val.Type().Elem().Kind() != reflect.Interface && val.Type().Elem().Kind() != reflect.Map {
+			return input, nil
+		}
+
+		result := make([]interface{}, val.Len())
+		for i := range result {
+			v, err := expandFlatKeys(val.Index(i).Interface(), sep)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = v
+		}
+
+		return result, nil
+
+	default:
+		return input, nil
+	}
+}
+
+// maxPathIndex is the largest index that insertPath grows a slice to hold,
+// so that a key such as "a[999999999]" can't allocate a huge slice.
+const maxPathIndex = 1<<16 - 1
+
+// insertPath sets the value at segments below *root, creating the maps
+// and slices along the way. If merge is true, maps that are inserted where
+// a map already exists are merged and any other existing value is an
+// error. Otherwise the existing value is replaced.
+func insertPath(root *interface{}, segments []pathSegment, value interface{}, merge bool) error {
+	if len(segments) == 0 && !merge {
+		*root = value
+		return nil
+	}
+
+	if len(segments) == 0 {
+		if existing, ok := (*root).(map[string]interface{}); ok {
+			m, ok := value.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("conflicts with nested keys")
+			}
+
+			for k, v := range m {
+				child := existing[k]
+				if err := insertPath(&child, nil, v, true); err != nil {
+					return err
+				}
+				existing[k] = child
+			}
+			return nil
+		}
+
+		if *root != nil {
+			return fmt.Errorf("is set more than once")
+		}
+
+		*root = value
+		return nil
+	}
+
+	seg := segments[0]
+	if seg.isIndex {
+		if *root == nil {
+			*root = []interface{}{}
+		}
+
+		s, ok := (*root).([]interface{})
+		if !ok {
+			return fmt.Errorf("conflicts with a value that isn't a slice")
+		}
+
+		if seg.index > maxPathIndex {
+			return fmt.Errorf("has index %d, larger than the maximum of %d", seg.index, maxPathIndex)
+		}
+
+		for len(s) <= seg.index {
+			s = append(s, nil)
+		}
+
+		if err := insertPath(&s[seg.index], segments[1:], value, merge); err != nil {
+			return err
+		}
+
+		*root = s
+		return nil
+	}
+
+	if *root == nil {
+		*root = map[string]interface{}{}
+	}
+
+	m, ok := (*root).(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("conflicts with a value that isn't a map")
+	}
+
+	child := m[seg.key]
+	if err := insertPath(&child, segments[1:], value, merge); err != nil {
+		return err
+	}
+	m[seg.key] = child
+
+	return nil
+}
+
+// escapePathKey escapes a key so that parsePath reads it back as a single
+// key segment.
+func escapePathKey(key, sep string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		switch {
+		case key[i] == '\\' || key[i] == '[' || key[i] == ']':
+			b.WriteByte('\\')
+			b.WriteByte(key[i])
+
+		case sep != "" && strings.HasPrefix(key[i:], sep):
+			for j := 0; j < len(sep); j++ {
+				b.WriteByte('\\')
+				b.WriteByte(sep[j])
+			}
+			i += len(sep) - 1
+
+		default:
+			b.WriteByte(key[i])
+		}
+	}
+
+	return b.String()
+}
+
+// flattenValue writes the leaves of v into out, keyed by their path below
+// prefix. Maps with string keys and slices and arrays are walked, every
+// other value is a leaf. Empty maps and slices are kept as leaves so that
+// they aren't lost. If encode is not nil, it is called to turn structs
+// into maps before they are walked.
+func flattenValue(out map[string]interface{}, prefix string, v interface{}, sep string, encode func(interface{}) (interface{}, error)) error {
+	// The values inside a secret value stay marked as secret.
+	if secret, ok := v.(secretValue); ok {
+		inner := make(map[string]interface{})
+		if err := flattenValue(inner, prefix, secret.value, sep, encode); err != nil {
+			return err
+		}
+		for key, value := range inner {
+			out[key] = secretValue{value}
+		}
+		return nil
+	}
+
+	val := reflect.ValueOf(v)
+	if val.Kind() == reflect.Ptr && !val.IsNil() && val.Elem().Kind() == reflect.Struct {
+		val = val.Elem()
+	}
+
+	switch val.Kind() {
+	case reflect.Struct:
+		if encode == nil {
+			break
+		}
+
+		m, err := encode(val.Interface())
+		if err != nil {
+			return err
+		}
+		return flattenValue(out, prefix, m, sep, nil)
+
+	case reflect.Map:
+		if val.Type().Key().Kind() != reflect.String || val.Len() == 0 {
+			break
+		}
+
+		for _, k := range val.MapKeys() {
+			key := escapePathKey(k.String(), sep)
+			if prefix != "" {
+				key = prefix + sep + key
+			}
+
+			if err := flattenValue(out, key, val.MapIndex(k).Interface(), sep, encode); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Slice, reflect.Array:
+		if val.Kind() == reflect.Slice && val.Type().Elem().Kind() == reflect.Uint8 || val.Len() == 0 {
+			break
+		}
+
+		for i := 0; i < val.Len(); i++ {
+			key := prefix + "[" + strconv.Itoa(i) + "]"
+			if err := flattenValue(out, key, val.Index(i).Interface(), sep, encode); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	out[prefix] = v
+	return nil
+}
+
+// lookupPath returns the value at segments below data. Map keys are
+// matched exactly first and then with MatchName, and slice and array
+// elements are addressed by index segments or by numeric keys. A wildcard
+// segment collects the values below every element of a map or slice that
+// have the rest of the path into a []interface{}, with map elements in
+// the order of their sorted keys.
+func (d *Decoder) lookupPath(data interface{}, segments []pathSegment) (interface{}, bool) {
+	if len(segments) == 0 {
+		return data, true
+	}
+
+	if node, ok := data.(Node); ok {
+		v, err := node.Value()
+		if err != nil {
+			return nil, false
+		}
+		data = v
+	}
+
+	seg := segments[0]
+	val := reflect.Indirect(reflect.ValueOf(mapInput(data)))
+	if seg.isWildcard {
+		var elems []reflect.Value
+		switch val.Kind() {
+		case reflect.Map:
+			keys := val.MapKeys()
+			sort.Slice(keys, func(i, j int) bool {
+				return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+			})
+			for _, k := range keys {
+				elems = append(elems, val.MapIndex(k))
+			}
+
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < val.Len(); i++ {
+				elems = append(elems, val.Index(i))
+			}
+
+		default:
+			return nil, false
+		}
+
+		result := make([]interface{}, 0, len(elems))
+		for _, elem := range elems {
+			if v, ok := d.lookupPath(elem.Interface(), segments[1:]); ok {
+				result = append(result, v)
+			}
+		}
+
+		return result, true
+	}
+
+	switch val.Kind() {
+	case reflect.Map:
+		if seg.isIndex {
+			return nil, false
+		}
+
+		v, ok := d.mapIndex(val, seg.key)
+		if !ok {
+			return nil, false
+		}
+		return d.lookupPath(v, segments[1:])
+
+	case reflect.Slice, reflect.Array:
+		index := seg.index
+		if !seg.isIndex {
+			i, err := strconv.Atoi(seg.key)
+			if err != nil {
+				return nil, false
+			}
+			index = i
+		}
+
+		if index < 0 || index >= val.Len() {
+			return nil, false
+		}
+		return d.lookupPath(val.Index(index).Interface(), segments[1:])
+
+	default:
+		return nil, false
+	}
+}
+
+// mapIndex returns the value under key in the map val, falling back to
+// MatchName if there is no exact match.
+func (d *Decoder) mapIndex(val reflect.Value, key string) (interface{}, bool) {
+	k, ok := d.mapKey(val, key)
+	if !ok {
+		return nil, false
+	}
+
+	return val.MapIndex(k).Interface(), true
+}
+
+// mapKey returns the key of the map val that mapIndex looks key up under.
+func (d *Decoder) mapKey(val reflect.Value, key string) (reflect.Value, bool) {
+	keyType := val.Type().Key()
+	if keyType.Kind() != reflect.String && keyType.Kind() != reflect.Interface {
+		return reflect.Value{}, false
+	}
+
+	exact := reflect.ValueOf(key)
+	if keyType.Kind() == reflect.String {
+		exact = exact.Convert(keyType)
+	}
+	if val.MapIndex(exact).IsValid() {
+		return exact, true
+	}
+
+	for _, k := range val.MapKeys() {
+		mK := k
+		if mK.Kind() == reflect.Interface {
+			mK = mK.Elem()
+		}
+
+		if mK.Kind() == reflect.String && d.config.MatchName(mK.String(), key) {
+			return k, true
+		}
+	}
+
+	return reflect.Value{}, false
+}
+
+// fieldPath returns the path that the field f is decoded from, if it has
+// one: the value of its PathTagName tag or, if its tag has the "path"
+// option, the name in its tag.
+func (d *Decoder) fieldPath(f reflect.StructField) (string, bool) {
+	if d.config.PathTagName != "" {
+		if path := f.Tag.Get(d.config.PathTagName); path != "" {
+			return strings.SplitN(path, ",", 2)[0], true
+		}
+	}
+
+	tag := d.fieldTag(f)
+	if hasTagOption(tag, "path") {
+		if path := strings.SplitN(tag, ",", 2)[0]; path != "" {
+			return path, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,255 @@
+package mapstructure
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePath(t *testing.T) {
+	t.Parallel()
+
+	key := func(k string) pathSegment { return pathSegment{key: k} }
+	index := func(i int) pathSegment { return pathSegment{index: i, isIndex: true} }
+
+	cases := []struct {
+		path     string
+		sep      string
+		expected []pathSegment
+	}{
+		{"a", ".", []pathSegment{key("a")}},
+		{"a.b.c", ".", []pathSegment{key("a"), key("b"), key("c")}},
+		{"a[0]", ".", []pathSegment{key("a"), index(0)}},
+		{"a[0][12].b", ".", []pathSegment{key("a"), index(0), index(12), key("b")}},
+		{"[1].a", ".", []pathSegment{index(1), key("a")}},
+		{`a\.b.c`, ".", []pathSegment{key("a.b"), key("c")}},
+		{`a\[0]`, ".", []pathSegment{key("a[0]")}},
+		{`a\\`, ".", []pathSegment{key(`a\`)}},
+		{"a__b", "__", []pathSegment{key("a"), key("b")}},
+		{"a.b", "", []pathSegment{key("a.b")}},
+		{"a.*.b", ".", []pathSegment{key("a"), {key: "*", isWildcard: true}, key("b")}},
+		{`a.\*`, ".", []pathSegment{key("a"), key("*")}},
+		{"a.*b", ".", []pathSegment{key("a"), key("*b")}},
+	}
+
+	for _, tc := range cases {
+		actual, err := parsePath(tc.path, tc.sep)
+		if err != nil {
+			t.Errorf("%q: err: %s", tc.path, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("%q: expected %#v, got %#v", tc.path, tc.expected, actual)
+		}
+	}
+
+	for _, path := range []string{"", ".", "a.", ".a", "a..b", "a[", "a[x]", "a[-1]", "a[0]..b", "a[0]."} {
+		if _, err := parsePath(path, "."); err == nil {
+			t.Errorf("%q: expected error", path)
+		}
+	}
+}
+
+func TestExpandFlatKeys(t *testing.T) {
+	t.Parallel()
+
+	input := map[string]interface{}{
+		"a.b":       1,
+		"a.c":       2,
+		"a.d":       map[string]interface{}{"e.f": 3},
+		"a.d.g":     4,
+		"list[1]":   "y",
+		"list[0]":   "x",
+		"objs[0].n": 5,
+		`x\.y`:      6,
+		"plain":     []interface{}{map[string]interface{}{"p.q": 7}},
+	}
+
+	actual, err := expandFlatKeys(input, ".")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[string]interface{}{
+		"a": map[string]interface{}{
+			"b": 1,
+			"c": 2,
+			"d": map[string]interface{}{
+				"e": map[string]interface{}{"f": 3},
+				"g": 4,
+			},
+		},
+		"list": []interface{}{"x", "y"},
+		"objs": []interface{}{
+			map[string]interface{}{"n": 5},
+		},
+		"x.y": 6,
+		"plain": []interface{}{
+			map[string]interface{}{
+				"p": map[string]interface{}{"q": 7},
+			},
+		},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected: %#v\ngot: %#v", expected, actual)
+	}
+
+	conflicts := []map[string]interface{}{
+		{"a": 1, "a.b": 2},
+		{"a": []interface{}{}, "a.b": 2},
+		{"a.b": 1, "a[0]": 2},
+	}
+	for _, input := range conflicts {
+		if _, err := expandFlatKeys(input, "."); err == nil {
+			t.Errorf("%#v: expected error", input)
+		}
+	}
+
+	_, err = expandFlatKeys(map[string]interface{}{"a[999999999]": 1}, ".")
+	if err == nil || err.Error() != `"a[999999999]" has index 999999999, larger than the maximum of 65535` {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestEscapePathKey(t *testing.T) {
+	t.Parallel()
+
+	for _, key := range []string{"a", "a.b", "a[0]", `a\b`, "a..b", "]"} {
+		escaped := escapePathKey(key, ".")
+		segments, err := parsePath(escaped, ".")
+		if err != nil {
+			t.Errorf("%q: err: %s", key, err)
+			continue
+		}
+
+		expected := []pathSegment{{key: key}}
+		if !reflect.DeepEqual(segments, expected) {
+			t.Errorf("%q: escaped as %q, parsed as %#v", key, escaped, segments)
+		}
+	}
+}
+
+func TestFlattenUnflatten(t *testing.T) {
+	t.Parallel()
+
+	nested := map[string]interface{}{
+		"server": map[string]interface{}{
+			"ports": []interface{}{80, 443},
+			"tls":   map[string]interface{}{"enabled": true},
+		},
+		"a.b":   "escaped",
+		"empty": map[string]interface{}{},
+	}
+
+	flat := Flatten(nested, ".")
+	expected := map[string]interface{}{
+		"server.ports[0]":    80,
+		"server.ports[1]":    443,
+		"server.tls.enabled": true,
+		`a\.b`:               "escaped",
+		"empty":              map[string]interface{}{},
+	}
+	if !reflect.DeepEqual(flat, expected) {
+		t.Fatalf("bad: %#v", flat)
+	}
+
+	actual, err := Unflatten(flat, ".")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !reflect.DeepEqual(actual, nested) {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	if _, err := Unflatten(map[string]interface{}{"a": 1, "a.b": 2}, "."); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestExtract(t *testing.T) {
+	t.Parallel()
+
+	input := map[string]interface{}{
+		"servers": []interface{}{
+			map[string]interface{}{
+				"http": map[string]interface{}{"port": "8080"},
+				"tags": []interface{}{"a", "b"},
+			},
+		},
+	}
+
+	tags, err := Extract[[]string](input, "servers[0].tags")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(tags, []string{"a", "b"}) {
+		t.Fatalf("bad: %#v", tags)
+	}
+
+	if _, err := Extract[int](input, "servers[0].http.port"); err == nil {
+		t.Fatal("expected error")
+	}
+
+	port, err := Extract[int](input, "servers.0.http.port", &DecoderConfig{WeaklyTypedInput: true})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if port != 8080 {
+		t.Fatalf("bad: %d", port)
+	}
+
+	_, err = Extract[string](input, "servers[1].http.port")
+	derr, ok := err.(*DecodingError)
+	if !ok || derr.Kind != DecodingErrorPathNotFound {
+		t.Fatalf("expected a path not found error, got %#v", err)
+	}
+	if err.Error() != "'servers[1].http.port' not found" {
+		t.Fatalf("bad: %s", err)
+	}
+}
+
+func TestSet(t *testing.T) {
+	t.Parallel()
+
+	m := map[string]interface{}{
+		"server": map[string]interface{}{"name": "web"},
+	}
+
+	paths := []struct {
+		path  string
+		value interface{}
+	}{
+		{"server.http.port", 80},
+		{"server.hosts[1]", "b"},
+		{"server.hosts[0]", "a"},
+		{"server.name", "api"},
+		{`labels.app\.io`, "demo"},
+	}
+	for _, p := range paths {
+		if err := Set(m, p.path, p.value); err != nil {
+			t.Fatalf("%s: err: %s", p.path, err)
+		}
+	}
+
+	expected := map[string]interface{}{
+		"server": map[string]interface{}{
+			"name":  "api",
+			"http":  map[string]interface{}{"port": 80},
+			"hosts": []interface{}{"a", "b"},
+		},
+		"labels": map[string]interface{}{"app.io": "demo"},
+	}
+	if !reflect.DeepEqual(m, expected) {
+		t.Fatalf("bad: %#v", m)
+	}
+
+	for _, path := range []string{"server.name.first", "server.hosts.x", "[0]", "a.*", "a..b", "ids[999999999]"} {
+		if err := Set(m, path, 1); err == nil {
+			t.Errorf("%q: expected error", path)
+		}
+	}
+	if !reflect.DeepEqual(m, expected) {
+		t.Fatalf("invalid paths changed the map: %#v", m)
+	}
+}
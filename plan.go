@@ -0,0 +1,192 @@
+package mapstructure
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// FieldMapping describes how a field of a struct is decoded from a map, as
+// returned by Decoder.Plan.
+type FieldMapping struct {
+	// Field is the name of the field, prefixed with the names of the
+	// squashed embedded structs it is promoted from, such as "Base.ID".
+	Field string
+
+	// Index is the index sequence of the field for
+	// reflect.Value.FieldByIndex.
+	Index []int
+
+	// Type is the type of the field.
+	Type reflect.Type
+
+	// Keys are the keys the field is decoded from. Keys that MatchName
+	// matches with them, by default those that only differ in case, are
+	// accepted too. If MatchField is set, it decides which keys are
+	// accepted instead.
+	Keys []string
+
+	// Path is the path of the value the field is decoded from if it has a
+	// PathTagName tag or the "path" tag option, in which case Keys is
+	// empty.
+	Path string
+
+	// Options are the options of the tag of the field, such as
+	// "omitempty" or "mergekey=id".
+	Options []string
+
+	// Remain is true for the field that the keys that no other field is
+	// decoded from are collected in, and for the squashed map fields,
+	// which collect those with their Prefix first. Its Keys are empty.
+	Remain bool
+
+	// Prefix is the prefix of the keys a squashed map field collects,
+	// which is stripped from them, as given by its "prefix=" tag option.
+	Prefix string
+
+	// Hooks is true if the value of the field is passed to the
+	// DecodeHook or the decoded field to the OutputHook.
+	Hooks bool
+}
+
+// Plan returns how the fields of the struct type typ, or of the struct
+// typ points to, are decoded from a map with the configuration of d, in
+// the order they are decoded. It can be used to document the keys a
+// configuration accepts, or to find out why a key isn't decoded.
+//
+// Fields of embedded structs that are squashed are listed in place of the
+// embedded struct. Embedded interfaces are listed as any other field,
+// since the struct they hold, which would be squashed, is only known when
+// decoding. Unexported fields aren't listed, since they're never decoded.
+func (d *Decoder) Plan(typ reflect.Type) ([]FieldMapping, error) {
+	for typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%v is not a struct", typ)
+	}
+
+	// The structs are visited in the same order as decodeStructFromMap
+	// visits them.
+	type plannedStruct struct {
+		typ   reflect.Type
+		index []int
+		name  string
+	}
+	structs := []plannedStruct{{typ: typ}}
+
+	hooks := d.config.DecodeHook != nil || d.config.OutputHook != nil
+
+	var mappings []FieldMapping
+	var errs []error
+	for len(structs) > 0 {
+		s := structs[0]
+		structs = structs[1:]
+
+		for i := 0; i < s.typ.NumField(); i++ {
+			f := s.typ.Field(i)
+			if f.Type == optionsType {
+				continue
+			}
+
+			index := append(append([]int(nil), s.index...), i)
+			fieldName := f.Name
+			if s.name != "" {
+				fieldName = s.name + "." + fieldName
+			}
+
+			fieldType := f.Type
+			if fieldType.Kind() == reflect.Ptr && fieldType.Elem().Kind() == reflect.Struct {
+				fieldType = fieldType.Elem()
+			}
+
+			tag := d.fieldTag(f)
+			tagParts := strings.Split(tag, ",")
+			squash := d.config.Squash && fieldType.Kind() == reflect.Struct && f.Anonymous
+			if hasTagOption(tag, "squash") {
+				squash = true
+			}
+
+			mapType := f.Type
+			if mapType.Kind() == reflect.Ptr {
+				mapType = mapType.Elem()
+			}
+			if squash && mapType.Kind() == reflect.Map {
+				squash = false
+			}
+
+			if squash {
+				if fieldType.Kind() != reflect.Struct {
+					errs = appendErrors(errs, d.decodingError(MsgSquashUnsupported, &DecodingError{
+						Kind: DecodingErrorInvalidSquash,
+						Name: f.Name,
+						Got:  fieldType.Kind().String(),
+					}))
+				} else {
+					structs = append(structs, plannedStruct{fieldType, index, fieldName})
+				}
+				continue
+			}
+
+			if f.PkgPath != "" {
+				continue
+			}
+
+			mapping := FieldMapping{
+				Field:   fieldName,
+				Index:   index,
+				Type:    f.Type,
+				Options: tagParts[1:],
+				Hooks:   hooks,
+			}
+			if len(mapping.Options) == 0 {
+				mapping.Options = nil
+			}
+
+			if hasTagOption(tag, "squash") {
+				mapping.Remain = true
+				mapping.Prefix, _ = tagOptionValue(tag, "prefix")
+				mappings = append(mappings, mapping)
+				continue
+			}
+
+			if hasTagOption(tag, "remain") {
+				mapping.Remain = true
+				mappings = append(mappings, mapping)
+				continue
+			}
+
+			if path, ok := d.fieldPath(f); ok {
+				if _, err := parsePath(path, "."); err != nil {
+					errs = appendErrors(errs, d.decodingError(MsgInvalidPath, &DecodingError{
+						Kind: DecodingErrorGeneric,
+						Name: fieldName,
+						Err:  err,
+					}))
+					continue
+				}
+
+				mapping.Path = path
+				mappings = append(mappings, mapping)
+				continue
+			}
+
+			key := f.Name
+			if tagParts[0] != "" {
+				key = tagParts[0]
+			}
+			if d.config.XMLConventions {
+				key = d.xmlKey(f, key)
+			}
+			mapping.Keys = []string{key}
+
+			mappings = append(mappings, mapping)
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, d.finishError(newError(errs))
+	}
+
+	return mappings, nil
+}
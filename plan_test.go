@@ -0,0 +1,61 @@
+package mapstructure
+
+import (
+	"reflect"
+	"testing"
+)
+
+type PlanBase struct {
+	ID string `mapstructure:"id"`
+}
+
+type planService struct {
+	PlanBase `mapstructure:",squash"`
+	Name     string                 `mapstructure:"name,omitempty"`
+	Port     int                    `path:"listen.port"`
+	Labels   map[string]string      `mapstructure:"labels,mergekey=name"`
+	Extra    map[string]interface{} `mapstructure:",remain"`
+	Tags     map[string]string      `mapstructure:",squash,prefix=tag_"`
+	internal string
+}
+
+func TestDecoder_Plan(t *testing.T) {
+	t.Parallel()
+
+	decoder, err := NewDecoder(&DecoderConfig{
+		Result:      &planService{},
+		PathTagName: "path",
+		DecodeHook:  StringToTimeDurationHookFunc(),
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	mappings, err := decoder.Plan(reflect.TypeOf(&planService{}))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []FieldMapping{
+		{Field: "Name", Index: []int{1}, Type: reflect.TypeOf(""), Keys: []string{"name"}, Options: []string{"omitempty"}, Hooks: true},
+		{Field: "Port", Index: []int{2}, Type: reflect.TypeOf(0), Path: "listen.port", Hooks: true},
+		{Field: "Labels", Index: []int{3}, Type: reflect.TypeOf(map[string]string{}), Keys: []string{"labels"}, Options: []string{"mergekey=name"}, Hooks: true},
+		{Field: "Extra", Index: []int{4}, Type: reflect.TypeOf(map[string]interface{}{}), Options: []string{"remain"}, Remain: true, Hooks: true},
+		{Field: "Tags", Index: []int{5}, Type: reflect.TypeOf(map[string]string{}), Options: []string{"squash", "prefix=tag_"}, Remain: true, Prefix: "tag_", Hooks: true},
+		{Field: "PlanBase.ID", Index: []int{0, 0}, Type: reflect.TypeOf(""), Keys: []string{"id"}, Hooks: true},
+	}
+	if !reflect.DeepEqual(mappings, expected) {
+		t.Fatalf("bad:\n%#v\n\nexpected:\n%#v", mappings, expected)
+	}
+
+	if _, err := decoder.Plan(reflect.TypeOf("")); err == nil {
+		t.Fatal("expected an error for a non-struct type")
+	}
+
+	type badSquash struct {
+		Name string `mapstructure:",squash"`
+	}
+	if _, err := decoder.Plan(reflect.TypeOf(badSquash{})); err == nil {
+		t.Fatal("expected an error for squashing a string")
+	}
+}
@@ -0,0 +1,10 @@
+module github.com/mitchellh/mapstructure/protostruct
+
+go 1.20
+
+require (
+	github.com/mitchellh/mapstructure v1.5.0
+	google.golang.org/protobuf v1.33.0
+)
+
+replace github.com/mitchellh/mapstructure => ../
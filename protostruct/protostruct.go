@@ -0,0 +1,103 @@
+// Package protostruct decodes the well-known protobuf types
+// google.protobuf.Struct, Value and ListValue with mapstructure, so that
+// Struct payloads can be decoded into Go structs without a round trip
+// through JSON:
+//
+//	var req struct {
+//		Name    string
+//		Retries int
+//	}
+//	err := protostruct.Decode(msg.GetParams(), &req)
+//
+// It is a separate module so that mapstructure itself doesn't depend on
+// the protobuf runtime.
+package protostruct
+
+import (
+	"reflect"
+
+	"github.com/mitchellh/mapstructure"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+var (
+	structType    = reflect.TypeOf((*structpb.Struct)(nil))
+	valueType     = reflect.TypeOf((*structpb.Value)(nil))
+	listValueType = reflect.TypeOf((*structpb.ListValue)(nil))
+)
+
+// DecodeHook returns a DecodeHookFunc that unwraps *structpb.Struct,
+// *structpb.Value and *structpb.ListValue inputs. Structs become maps and
+// lists become slices whose elements are unwrapped as they're decoded,
+// and the Kind oneof of a Value is replaced by the value it holds. Inputs
+// decoded into an interface{} are unwrapped completely. Inputs that are
+// decoded into their own type, such as a *structpb.Value field, are left
+// as they are.
+func DecodeHook() mapstructure.DecodeHookFuncType {
+	return func(from, to reflect.Type, data interface{}) (interface{}, error) {
+		if from != structType && from != valueType && from != listValueType {
+			return data, nil
+		}
+		if to == from || to == from.Elem() {
+			return data, nil
+		}
+
+		if to.Kind() == reflect.Interface {
+			switch v := data.(type) {
+			case *structpb.Struct:
+				return v.AsMap(), nil
+			case *structpb.Value:
+				return v.AsInterface(), nil
+			case *structpb.ListValue:
+				return v.AsSlice(), nil
+			}
+		}
+
+		switch v := data.(type) {
+		case *structpb.Struct:
+			return v.GetFields(), nil
+		case *structpb.ListValue:
+			return v.GetValues(), nil
+		case *structpb.Value:
+			return value(v), nil
+		}
+
+		return data, nil
+	}
+}
+
+// value returns what the Kind of v holds, without unwrapping the elements
+// of structs and lists.
+func value(v *structpb.Value) interface{} {
+	switch k := v.GetKind().(type) {
+	case *structpb.Value_NumberValue:
+		return k.NumberValue
+	case *structpb.Value_StringValue:
+		return k.StringValue
+	case *structpb.Value_BoolValue:
+		return k.BoolValue
+	case *structpb.Value_StructValue:
+		return k.StructValue.GetFields()
+	case *structpb.Value_ListValue:
+		return k.ListValue.GetValues()
+	default:
+		return nil
+	}
+}
+
+// Decode decodes input, which may be or contain structpb values, into
+// output. Numbers are float64s in a Value, so WeaklyTypedInput isn't
+// needed to decode them into integer fields.
+func Decode(input interface{}, output interface{}) error {
+	config := &mapstructure.DecoderConfig{
+		DecodeHook: DecodeHook(),
+		Result:     output,
+	}
+
+	decoder, err := mapstructure.NewDecoder(config)
+	if err != nil {
+		return err
+	}
+
+	return decoder.Decode(input)
+}
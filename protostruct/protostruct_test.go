@@ -0,0 +1,86 @@
+package protostruct
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+type endpoint struct {
+	Host string
+	Port int
+}
+
+type params struct {
+	Name      string
+	Retries   int
+	Enabled   bool
+	Endpoints []endpoint
+	Labels    map[string]string
+	Extra     interface{}
+	Raw       *structpb.Value
+}
+
+func TestDecode(t *testing.T) {
+	input, err := structpb.NewStruct(map[string]interface{}{
+		"name":    "worker",
+		"retries": 3,
+		"enabled": true,
+		"endpoints": []interface{}{
+			map[string]interface{}{"host": "a", "port": 80},
+			map[string]interface{}{"host": "b", "port": 443},
+		},
+		"labels": map[string]interface{}{"env": "prod"},
+		"extra":  map[string]interface{}{"list": []interface{}{1, "x", nil}},
+		"raw":    "kept",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var result params
+	if err := Decode(input, &result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := params{
+		Name:    "worker",
+		Retries: 3,
+		Enabled: true,
+		Endpoints: []endpoint{
+			{Host: "a", Port: 80},
+			{Host: "b", Port: 443},
+		},
+		Labels: map[string]string{"env": "prod"},
+		Extra: map[string]interface{}{
+			"list": []interface{}{float64(1), "x", nil},
+		},
+		Raw: structpb.NewStringValue("kept"),
+	}
+
+	if result.Raw.GetStringValue() != "kept" {
+		t.Fatalf("bad raw: %#v", result.Raw)
+	}
+	result.Raw, expected.Raw = nil, nil
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+}
+
+func TestDecode_value(t *testing.T) {
+	input, err := structpb.NewValue([]interface{}{1, 2.5})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var result []float64
+	if err := Decode(input, &result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !reflect.DeepEqual(result, []float64{1, 2.5}) {
+		t.Fatalf("bad: %#v", result)
+	}
+}
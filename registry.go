@@ -0,0 +1,232 @@
+package mapstructure
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// TypeRegistry chooses the concrete type that a map in the input is
+// decoded into when the result is an interface, from the value of a
+// discriminator key of the map, so that plugin-style configurations such
+// as
+//
+//	listeners:
+//	  - kind: http
+//	    port: 80
+//	  - kind: unix
+//	    path: /run/app.sock
+//
+// can be decoded into a []Listener. Set it as the TypeRegistry of the
+// DecoderConfig. Only interfaces with methods are decoded through the
+// registry, with the registered types that implement them; interface{}
+// values are decoded as usual.
+//
+// The discriminator key is matched with MatchName. It is only decoded into
+// the types that have a field for it; for the others it isn't an unused
+// key.
+//
+// A TypeRegistry must not be changed while it is used for decoding.
+type TypeRegistry struct {
+	key   string
+	types map[string][]registeredType
+}
+
+// registeredType is a type registered under a name with the function that
+// creates new values of it.
+type registeredType struct {
+	typ     reflect.Type
+	factory func() interface{}
+}
+
+// NewTypeRegistry returns an empty TypeRegistry that reads the name of
+// the type of a map from its key.
+func NewTypeRegistry(key string) *TypeRegistry {
+	return &TypeRegistry{key: key, types: make(map[string][]registeredType)}
+}
+
+// Key returns the discriminator key of the registry.
+func (r *TypeRegistry) Key() string {
+	return r.key
+}
+
+// Register registers the type of prototype under name. The maps whose key
+// is name are decoded into a new value of that type; if prototype is a
+// pointer, such as &HTTPListener{}, into a new value that it points to.
+// The same name can be registered for types that implement different
+// interfaces; registering it again for the same type replaces it. It
+// returns r so that calls can be chained.
+func (r *TypeRegistry) Register(name string, prototype interface{}) *TypeRegistry {
+	typ := reflect.TypeOf(prototype)
+	return r.register(name, typ, func() interface{} {
+		if typ.Kind() == reflect.Ptr {
+			return reflect.New(typ.Elem()).Interface()
+		}
+		return reflect.New(typ).Elem().Interface()
+	})
+}
+
+// RegisterFunc registers the type of the values that factory returns
+// under name, like Register, but the values are created by factory, so
+// that they can start with defaults. factory is called once to learn the
+// type.
+func (r *TypeRegistry) RegisterFunc(name string, factory func() interface{}) *TypeRegistry {
+	return r.register(name, reflect.TypeOf(factory()), factory)
+}
+
+func (r *TypeRegistry) register(name string, typ reflect.Type, factory func() interface{}) *TypeRegistry {
+	if typ == nil {
+		panic("mapstructure: cannot register a nil type for " + name)
+	}
+
+	registered := registeredType{typ: typ, factory: factory}
+	for i, t := range r.types[name] {
+		if t.typ == typ {
+			r.types[name][i] = registered
+			return r
+		}
+	}
+
+	r.types[name] = append(r.types[name], registered)
+	return r
+}
+
+// lookup returns the type registered under name that implements iface.
+func (r *TypeRegistry) lookup(name string, iface reflect.Type) (registeredType, bool) {
+	for _, t := range r.types[name] {
+		if t.typ.Implements(iface) {
+			return t, true
+		}
+	}
+
+	return registeredType{}, false
+}
+
+// names returns the sorted names of the types that implement iface.
+func (r *TypeRegistry) names(iface reflect.Type) []string {
+	var names []string
+	for name := range r.types {
+		if _, ok := r.lookup(name, iface); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// decodeRegistered decodes the map data into a new value of the type
+// that the TypeRegistry has registered under the value of its
+// discriminator key, and stores it in the interface val. It returns false
+// if the registry doesn't apply to data and val, which are then decoded
+// as usual.
+func (d *Decoder) decodeRegistered(name string, data interface{}, val reflect.Value) (bool, error) {
+	registry := d.config.TypeRegistry
+	dataVal := reflect.Indirect(reflect.ValueOf(data))
+	if registry == nil || val.Kind() != reflect.Interface || val.NumMethod() == 0 ||
+		dataVal.Kind() != reflect.Map || dataVal.Type().Key().Kind() != reflect.String {
+		return false, nil
+	}
+
+	names := registry.names(val.Type())
+	if len(names) == 0 {
+		return false, nil
+	}
+
+	// The discriminator key is matched like the keys of struct fields.
+	keyVal, ok := d.mapKey(dataVal, registry.key)
+	if !ok {
+		return true, d.decodingError(MsgMissingTypeKey, &DecodingError{
+			Kind:     DecodingErrorUnknownType,
+			Name:     name,
+			Expected: registry.key,
+		})
+	}
+
+	typeName := fmt.Sprint(dataVal.MapIndex(keyVal).Interface())
+	registered, ok := registry.lookup(typeName, val.Type())
+	if !ok {
+		return true, d.decodingError(MsgUnknownType, &DecodingError{
+			Kind:     DecodingErrorUnknownType,
+			Name:     name,
+			Expected: strings.Join(names, ", "),
+			Value:    typeName,
+		})
+	}
+
+	// The discriminator key is left out, so that the types needn't have a
+	// field for it, unless the type has one.
+	fields := dataVal
+	if !d.hasFieldFor(registered.typ, keyVal.String()) {
+		fields = reflect.MakeMapWithSize(dataVal.Type(), dataVal.Len())
+		iter := dataVal.MapRange()
+		for iter.Next() {
+			if iter.Key().String() != keyVal.String() {
+				fields.SetMapIndex(iter.Key(), iter.Value())
+			}
+		}
+	}
+
+	// A value of the same type that the interface already holds is decoded
+	// into, as for any interface.
+	if !val.IsNil() && val.Elem().Type() == registered.typ {
+		return true, d.decodeBasic(name, fields.Interface(), val)
+	}
+
+	value := reflect.ValueOf(registered.factory())
+	var target reflect.Value
+	if value.Kind() == reflect.Ptr && !value.IsNil() {
+		target = value.Elem()
+	} else {
+		target = reflect.New(value.Type()).Elem()
+		target.Set(value)
+		value = target
+	}
+
+	if err := d.decode(name, fields.Interface(), target); err != nil {
+		return true, err
+	}
+
+	val.Set(value)
+	return true, nil
+}
+
+// hasFieldFor reports whether the map key is decoded into a field of the
+// struct typ, or of the struct it points to, including the fields of the
+// structs squashed into it.
+func (d *Decoder) hasFieldFor(typ reflect.Type, key string) bool {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return false
+	}
+
+	for _, f := range d.structFields(typ) {
+		if f.PkgPath != "" || f.remain || f.hasPath {
+			continue
+		}
+
+		fieldType := f.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if f.squash || d.config.Squash && f.Anonymous && fieldType.Kind() == reflect.Struct {
+			if d.hasFieldFor(fieldType, key) {
+				return true
+			}
+			continue
+		}
+
+		if d.config.MatchField != nil {
+			if d.config.MatchField(key, f.StructField) {
+				return true
+			}
+		} else if key == f.name || d.config.MatchName(key, f.name) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,168 @@
+package mapstructure
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type listener interface {
+	Addr() string
+}
+
+type httpListener struct {
+	Port    int
+	Handler listener
+}
+
+func (l *httpListener) Addr() string { return "http" }
+
+type unixListener struct {
+	Path string
+	Mode int
+}
+
+func (l unixListener) Addr() string { return l.Path }
+
+func TestDecoder_TypeRegistry(t *testing.T) {
+	t.Parallel()
+
+	registry := NewTypeRegistry("kind").
+		Register("http", &httpListener{}).
+		Register("unix", unixListener{}).
+		RegisterFunc("socket", func() interface{} {
+			return unixListener{Mode: 0o600}
+		})
+
+	type Config struct {
+		Listeners []listener
+		Default   listener
+		Extra     interface{}
+	}
+
+	input := map[string]interface{}{
+		"listeners": []interface{}{
+			map[string]interface{}{
+				"kind": "http",
+				"port": 80,
+				"handler": map[string]interface{}{
+					"kind": "unix",
+					"path": "/run/handler.sock",
+				},
+			},
+			map[string]interface{}{"kind": "socket", "path": "/run/app.sock"},
+		},
+		"default": map[string]interface{}{"kind": "unix", "path": "/run/default.sock"},
+		"extra":   map[string]interface{}{"kind": "http"},
+	}
+
+	var result Config
+	decoder, err := NewDecoder(&DecoderConfig{
+		TypeRegistry: registry,
+		ErrorUnused:  true,
+		Result:       &result,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := decoder.Decode(input); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := Config{
+		Listeners: []listener{
+			&httpListener{Port: 80, Handler: unixListener{Path: "/run/handler.sock"}},
+			unixListener{Path: "/run/app.sock", Mode: 0o600},
+		},
+		Default: unixListener{Path: "/run/default.sock"},
+		Extra:   map[string]interface{}{"kind": "http"},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, result)
+	}
+
+	// A value of the registered type that the interface holds is decoded
+	// into.
+	existing := &httpListener{Port: 80}
+	result.Default = existing
+	err = decoder.Decode(map[string]interface{}{
+		"default": map[string]interface{}{"kind": "http", "port": 8080},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if result.Default != existing || existing.Port != 8080 {
+		t.Fatalf("bad: %#v", result.Default)
+	}
+
+	cases := []struct {
+		input    map[string]interface{}
+		expected string
+	}{
+		{
+			map[string]interface{}{"kind": "tcp"},
+			"'Default' has unknown type 'tcp', expected one of: http, socket, unix",
+		},
+		{
+			map[string]interface{}{"port": 80},
+			"'Default' needs a 'kind' key to choose its type",
+		},
+	}
+	for _, tc := range cases {
+		err := decoder.Decode(map[string]interface{}{"default": tc.input})
+		var decodingErr *DecodingError
+		if !errors.As(err, &decodingErr) || decodingErr.Kind != DecodingErrorUnknownType {
+			t.Fatalf("expected an unknown type error, got %#v", err)
+		}
+		if decodingErr.Error() != tc.expected {
+			t.Fatalf("expected %q, got %q", tc.expected, decodingErr.Error())
+		}
+	}
+}
+
+type tcpListener struct {
+	Kind string
+	Port int
+}
+
+func (l tcpListener) Addr() string { return l.Kind }
+
+func TestDecoder_TypeRegistryKey(t *testing.T) {
+	t.Parallel()
+
+	registry := NewTypeRegistry("kind").
+		Register("tcp", tcpListener{}).
+		Register("unix", unixListener{})
+
+	var result struct {
+		Listeners []listener
+	}
+	decoder, err := NewDecoder(&DecoderConfig{
+		TypeRegistry: registry,
+		ErrorUnused:  true,
+		Result:       &result,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The key is matched with MatchName, and only left out for the types
+	// that have no field for it.
+	err = decoder.Decode(map[string]interface{}{
+		"listeners": []interface{}{
+			map[string]interface{}{"Kind": "tcp", "port": 80},
+			map[string]interface{}{"KIND": "unix", "path": "/run/app.sock"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []listener{
+		tcpListener{Kind: "tcp", Port: 80},
+		unixListener{Path: "/run/app.sock"},
+	}
+	if !reflect.DeepEqual(result.Listeners, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, result.Listeners)
+	}
+}
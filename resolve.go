@@ -0,0 +1,106 @@
+package mapstructure
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+)
+
+// Resolver returns the value that ref refers to. ref is the part of a
+// reference after the scheme, such as "DB_PASSWORD" for
+// "env://DB_PASSWORD".
+type Resolver func(ref string) (interface{}, error)
+
+// Resolvers maps the schemes of references to the Resolver for them. See
+// ResolveReferencesHookFunc.
+type Resolvers map[string]Resolver
+
+// DefaultResolvers returns Resolvers with EnvResolver for "env" and
+// FileResolver for "file". ExecResolver isn't included, since it runs
+// commands named by the input; register it for "exec" explicitly if the
+// input is trusted.
+func DefaultResolvers() Resolvers {
+	return Resolvers{
+		"env":  EnvResolver,
+		"file": FileResolver,
+	}
+}
+
+// ResolveReferencesHookFunc returns a DecodeHookFunc that replaces string
+// inputs of the form "scheme://ref", such as "env://DB_PASSWORD" or
+// "file:///run/secrets/db", with the value that the Resolver registered
+// for the scheme returns for ref. This happens before the value is
+// converted, so secrets can be kept out of the input entirely. Strings
+// with other schemes or none are left as they are.
+func ResolveReferencesHookFunc(resolvers Resolvers) DecodeHookFuncType {
+	return func(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+		if f.Kind() != reflect.String {
+			return data, nil
+		}
+
+		str := reflect.ValueOf(data).String()
+		scheme, ref, ok := strings.Cut(str, "://")
+		if !ok {
+			return data, nil
+		}
+
+		resolve, ok := resolvers[scheme]
+		if !ok {
+			return data, nil
+		}
+
+		resolved, err := resolve(ref)
+		if err != nil {
+			return nil, fmt.Errorf("resolving %q: %w", str, err)
+		}
+
+		return resolved, nil
+	}
+}
+
+// EnvResolver is a Resolver that returns the value of the environment
+// variable ref. It is an error if the variable isn't set.
+func EnvResolver(ref string) (interface{}, error) {
+	value, ok := os.LookupEnv(ref)
+	if !ok {
+		return nil, fmt.Errorf("environment variable %s is not set", ref)
+	}
+
+	return value, nil
+}
+
+// FileResolver is a Resolver that returns the contents of the file at the
+// path ref as a string, without a trailing newline.
+func FileResolver(ref string) (interface{}, error) {
+	contents, err := os.ReadFile(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	return trimNewline(string(contents)), nil
+}
+
+// ExecResolver is a Resolver that runs the command ref, split into the
+// program and its arguments at spaces, and returns its output as a string
+// without a trailing newline.
+func ExecResolver(ref string) (interface{}, error) {
+	args := strings.Fields(ref)
+	if len(args) == 0 {
+		return nil, errors.New("no command given")
+	}
+
+	out, err := exec.Command(args[0], args[1:]...).Output()
+	if err != nil {
+		return nil, err
+	}
+
+	return trimNewline(string(out)), nil
+}
+
+func trimNewline(s string) string {
+	s = strings.TrimSuffix(s, "\n")
+	return strings.TrimSuffix(s, "\r")
+}
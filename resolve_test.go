@@ -0,0 +1,87 @@
+package mapstructure
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolveReferencesHookFunc(t *testing.T) {
+	t.Setenv("MAPSTRUCTURE_TEST_PORT", "5432")
+
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "password")
+	if err := os.WriteFile(secret, []byte("hunter2\n"), 0o600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	type Database struct {
+		Host     string
+		Port     int
+		Password string
+		User     string
+		Replicas []string
+	}
+
+	resolvers := DefaultResolvers()
+	resolvers["const"] = func(ref string) (interface{}, error) {
+		return strings.Split(ref, ","), nil
+	}
+
+	input := map[string]interface{}{
+		"host":     "db://not-a-registered-scheme",
+		"port":     "env://MAPSTRUCTURE_TEST_PORT",
+		"password": "file://" + secret,
+		"user":     "admin",
+		"replicas": "const://a,b",
+	}
+
+	var result Database
+	decoder, err := NewDecoder(&DecoderConfig{
+		DecodeHook:       ResolveReferencesHookFunc(resolvers),
+		WeaklyTypedInput: true,
+		Result:           &result,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := decoder.Decode(input); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := Database{
+		Host:     "db://not-a-registered-scheme",
+		Port:     5432,
+		Password: "hunter2",
+		User:     "admin",
+		Replicas: []string{"a", "b"},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	err = decoder.Decode(map[string]interface{}{
+		"password": "env://MAPSTRUCTURE_TEST_MISSING",
+	})
+	if err == nil || !strings.Contains(err.Error(), "MAPSTRUCTURE_TEST_MISSING is not set") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestExecResolver(t *testing.T) {
+	t.Parallel()
+
+	if _, err := ExecResolver(""); err == nil {
+		t.Fatal("expected error")
+	}
+
+	out, err := ExecResolver("go env GOOS")
+	if err != nil {
+		t.Skipf("can't run go: %s", err)
+	}
+	if out == "" || strings.HasSuffix(out.(string), "\n") {
+		t.Fatalf("bad: %q", out)
+	}
+}
@@ -0,0 +1,357 @@
+package mapstructure
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Source is implemented by inputs that give map-like access to their
+// values without being a Go map, such as a view on a Consul or etcd key
+// prefix. Values returned by Get may implement Source themselves, in which
+// case they are decoded the same way. This lets the decoder read large or
+// remote trees one level at a time instead of requiring the whole tree to
+// be materialized as a map[string]interface{} up front.
+type Source interface {
+	// Keys returns the keys available at this level.
+	Keys() []string
+
+	// Get returns the value for the given key and whether it exists.
+	Get(key string) (interface{}, bool)
+}
+
+// Node is implemented by the nodes of a parsed document, such as a YAML
+// syntax tree, so that the document can be decoded directly instead of
+// through an intermediate map. Unlike an intermediate map, nodes keep the
+// position of every value, which is added to the errors for the values
+// that fail to decode, and mappings keep their keys in document order.
+type Node interface {
+	// Position returns the position of the node in the source document.
+	Position() Position
+
+	// Value returns the value of the node: a Source whose values are
+	// Nodes for mappings, a []interface{} of Nodes for sequences, and a
+	// scalar such as a string, bool, int or float64, or nil, otherwise.
+	Value() (interface{}, error)
+}
+
+// nodeInterface returns the value of node with all the nodes below it
+// replaced by their values, for decoding into an interface{}.
+func nodeInterface(v interface{}) (interface{}, error) {
+	if node, ok := v.(Node); ok {
+		var err error
+		if v, err = node.Value(); err != nil {
+			return nil, err
+		}
+	}
+
+	switch v := v.(type) {
+	case Source:
+		keys := v.Keys()
+		m := make(map[string]interface{}, len(keys))
+		for _, key := range keys {
+			elem, ok := v.Get(key)
+			if !ok {
+				continue
+			}
+
+			value, err := nodeInterface(elem)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = value
+		}
+		return m, nil
+
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, elem := range v {
+			value, err := nodeInterface(elem)
+			if err != nil {
+				return nil, err
+			}
+			s[i] = value
+		}
+		return s, nil
+
+	default:
+		return v, nil
+	}
+}
+
+// decodeNode decodes the value of node into val, like decodeInput, and
+// adds the position of node to the errors that don't have a more specific
+// one.
+func (d *Decoder) decodeNode(name string, node Node, val reflect.Value, runHook bool) error {
+	var v interface{}
+	var err error
+	if val.Kind() == reflect.Interface {
+		v, err = nodeInterface(node)
+	} else {
+		v, err = node.Value()
+	}
+	if err != nil {
+		return withPosition(d.decodingError(MsgNodeFailure, &DecodingError{
+			Kind: DecodingErrorGeneric,
+			Name: name,
+			Err:  err,
+		}), node.Position())
+	}
+
+	return withPosition(d.decodeInput(name, v, val, runHook), node.Position())
+}
+
+// sourceMap reads one level of src into a map. Nested sources are left
+// as they are and are read when they're decoded.
+func sourceMap(src Source) map[string]interface{} {
+	keys := src.Keys()
+	m := make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		if v, ok := src.Get(key); ok {
+			m[key] = v
+		}
+	}
+
+	return m
+}
+
+// mapInput converts map-like inputs that aren't Go maps, such as Sources
+// and sync.Maps, into a map the decoder can iterate over. Any other input
+// is returned as is.
+func mapInput(data interface{}) interface{} {
+	switch v := data.(type) {
+	case Source:
+		return sourceMap(v)
+	case *sync.Map:
+		m := make(map[interface{}]interface{})
+		v.Range(func(key, value interface{}) bool {
+			m[key] = value
+			return true
+		})
+		return m
+	default:
+		return data
+	}
+}
+
+// pairsInput converts a slice of key/value pairs into an *OrderedMap with
+// the keys in the order of the slice, so that it can be decoded like a
+// map. The pairs are either [2]T arrays, such as the elements of a
+// [][2]interface{}, or structs with only a Key and a Value field, such as
+// KeyValue. Keys that aren't strings are converted with fmt.Sprint, and
+// a key that appears twice is an error. Any other input is returned as
+// is.
+func (d *Decoder) pairsInput(name string, data interface{}) (interface{}, error) {
+	dataVal := reflect.Indirect(reflect.ValueOf(data))
+	if dataVal.Kind() != reflect.Slice || !isPairType(dataVal.Type().Elem()) {
+		return data, nil
+	}
+
+	m := &OrderedMap{}
+	for i := 0; i < dataVal.Len(); i++ {
+		var k, v reflect.Value
+		if pair := dataVal.Index(i); pair.Kind() == reflect.Array {
+			k, v = pair.Index(0), pair.Index(1)
+		} else {
+			k, v = pair.FieldByName("Key"), pair.FieldByName("Value")
+		}
+
+		key := fmt.Sprint(k.Interface())
+		if _, ok := m.Get(key); ok {
+			return nil, d.decodingError(MsgDuplicateKey, &DecodingError{
+				Kind:  DecodingErrorDuplicateKey,
+				Name:  name,
+				Value: key,
+			})
+		}
+		m.Set(key, v.Interface())
+	}
+
+	return m, nil
+}
+
+// isPairType reports whether typ is a key/value pair for pairsInput.
+func isPairType(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.Array:
+		return typ.Len() == 2
+	case reflect.Struct:
+		if typ.NumField() != 2 {
+			return false
+		}
+		key, hasKey := typ.FieldByName("Key")
+		value, hasValue := typ.FieldByName("Value")
+		return hasKey && hasValue && key.PkgPath == "" && value.PkgPath == ""
+	default:
+		return false
+	}
+}
+
+// stringifyKeys returns a copy of input with the keys of every
+// interface-keyed map in it, such as the maps produced by yaml.v2,
+// converted to strings with fmt.Sprint. Maps and slices that can hold
+// such maps are copied as map[string]interface{} and []interface{}.
+// Any other value is returned as is.
+func stringifyKeys(input interface{}) interface{} {
+	val := reflect.ValueOf(input)
+	switch val.Kind() {
+	case reflect.Map:
+		keyKind := val.Type().Key().Kind()
+		if keyKind != reflect.Interface &&
+			(keyKind != reflect.String || val.Type().Elem().Kind() != reflect.Interface) {
+			return input
+		}
+
+		m := make(map[string]interface{}, val.Len())
+		for _, k := range val.MapKeys() {
+			m[fmt.Sprint(k.Interface())] = stringifyKeys(val.MapIndex(k).Interface())
+		}
+		return m
+
+	case reflect.Slice:
+		if val.Type().Elem().Kind() != reflect.Interface {
+			return input
+		}
+
+		s := make([]interface{}, val.Len())
+		for i := range s {
+			s[i] = stringifyKeys(val.Index(i).Interface())
+		}
+		return s
+
+	default:
+		return input
+	}
+}
+
+// stringifyMapKeys returns a copy of the map m with its keys converted to
+// strings with fmt.Sprint.
+func stringifyMapKeys(m reflect.Value) reflect.Value {
+	result := make(map[string]interface{}, m.Len())
+	iter := m.MapRange()
+	for iter.Next() {
+		result[fmt.Sprint(iter.Key().Interface())] = iter.Value().Interface()
+	}
+
+	return reflect.ValueOf(result)
+}
+
+var multiValueType = reflect.TypeOf([]string(nil))
+
+// isMultiValueMap reports whether typ is a map of string keys to multiple
+// string values, such as url.Values.
+func isMultiValueMap(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Map &&
+		typ.Key().Kind() == reflect.String &&
+		typ.Elem() == multiValueType
+}
+
+// isHeaderMap reports whether typ is http.Header or textproto.MIMEHeader,
+// whose keys are stored in canonical form.
+func isHeaderMap(typ reflect.Type) bool {
+	return typ.Name() == "MIMEHeader" && typ.PkgPath() == "net/textproto" ||
+		typ.Name() == "Header" && typ.PkgPath() == "net/http"
+}
+
+// multiValueInput prepares a value of a multi-value map for decoding into
+// target. A single value is unwrapped when it is decoded into anything
+// other than a slice, array or interface, so that "?port=80" can be decoded
+// into an int field. Values decoded into slices and arrays are split on
+// the MultiValueSeparator, if set. Any other value is returned as is.
+func (d *Decoder) multiValueInput(v interface{}, target reflect.Type) interface{} {
+	values, ok := v.([]string)
+	if !ok {
+		return v
+	}
+
+	for target.Kind() == reflect.Ptr {
+		target = target.Elem()
+	}
+
+	switch target.Kind() {
+	case reflect.Interface:
+		return v
+	case reflect.Slice, reflect.Array:
+		if d.config.MultiValueSeparator == "" {
+			return v
+		}
+
+		var split []string
+		for _, value := range values {
+			for _, part := range strings.Split(value, d.config.MultiValueSeparator) {
+				split = append(split, strings.TrimSpace(part))
+			}
+		}
+		return split
+	default:
+		if len(values) != 1 {
+			return v
+		}
+		return values[0]
+	}
+}
+
+// formatMultiValue formats v as the values of a multi-value map. Nil
+// pointers and interfaces have no values.
+func formatMultiValue(v reflect.Value) ([]string, bool) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, true
+		}
+		v = v.Elem()
+	}
+
+	if s, ok := formatScalar(v); ok {
+		return []string{s}, true
+	}
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		values := make([]string, v.Len())
+		for i := range values {
+			s, ok := formatScalar(reflect.Indirect(v.Index(i)))
+			if !ok {
+				return nil, false
+			}
+			values[i] = s
+		}
+		return values, true
+	default:
+		return nil, false
+	}
+}
+
+// formatScalar formats a single value as a string. Byte slices are
+// formatted as strings.
+func formatScalar(v reflect.Value) (string, bool) {
+	if !v.IsValid() {
+		return "", false
+	}
+
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		text, err := m.MarshalText()
+		return string(text), err == nil
+	}
+
+	switch getKind(v) {
+	case reflect.String:
+		return v.String(), true
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), true
+	case reflect.Int:
+		return strconv.FormatInt(v.Int(), 10), true
+	case reflect.Uint:
+		return strconv.FormatUint(v.Uint(), 10), true
+	case reflect.Float32:
+		return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits()), true
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return string(v.Bytes()), true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,631 @@
+package mapstructure
+
+import (
+	"errors"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testKVSource exposes a flat key/value store with "/" separated keys as
+// a tree of Sources.
+type testKVSource struct {
+	prefix string
+	kv     map[string]string
+	gets   *int
+}
+
+func (s testKVSource) Keys() []string {
+	seen := make(map[string]struct{})
+	var keys []string
+	for k := range s.kv {
+		if !strings.HasPrefix(k, s.prefix) {
+			continue
+		}
+
+		key := strings.SplitN(strings.TrimPrefix(k, s.prefix), "/", 2)[0]
+		if _, ok := seen[key]; !ok {
+			seen[key] = struct{}{}
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func (s testKVSource) Get(key string) (interface{}, bool) {
+	*s.gets++
+
+	if v, ok := s.kv[s.prefix+key]; ok {
+		return v, true
+	}
+
+	sub := testKVSource{prefix: s.prefix + key + "/", kv: s.kv, gets: s.gets}
+	if len(sub.Keys()) == 0 {
+		return nil, false
+	}
+
+	return sub, true
+}
+
+func TestDecode_Source(t *testing.T) {
+	t.Parallel()
+
+	type DB struct {
+		Host string
+		Port string
+	}
+
+	type Config struct {
+		Name  string
+		DB    DB
+		Extra map[string]interface{}
+	}
+
+	gets := 0
+	input := testKVSource{
+		kv: map[string]string{
+			"name":         "app",
+			"db/host":      "localhost",
+			"db/port":      "5432",
+			"extra/region": "eu",
+		},
+		gets: &gets,
+	}
+
+	var result Config
+	if err := Decode(input, &result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := Config{
+		Name: "app",
+		DB:   DB{Host: "localhost", Port: "5432"},
+		Extra: map[string]interface{}{
+			"region": "eu",
+		},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	if gets != 6 {
+		t.Fatalf("expected 6 gets, got %d", gets)
+	}
+}
+
+func TestDecode_SourceIntoInterface(t *testing.T) {
+	t.Parallel()
+
+	gets := 0
+	input := testKVSource{kv: map[string]string{"a": "b"}, gets: &gets}
+
+	var result interface{}
+	if err := Decode(input, &result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if _, ok := result.(Source); !ok {
+		t.Fatalf("expected the source to be kept as is: %#v", result)
+	}
+
+	if gets != 0 {
+		t.Fatalf("expected no gets, got %d", gets)
+	}
+}
+
+func TestDecode_SyncMap(t *testing.T) {
+	t.Parallel()
+
+	var input sync.Map
+	input.Store("vstring", "foo")
+	input.Store("vint", 42)
+
+	var result Basic
+	if err := Decode(&input, &result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if result.Vstring != "foo" || result.Vint != 42 {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	var m map[string]int
+	if err := Decode(&input, &m); err == nil {
+		t.Fatal("expected error decoding a string into an int")
+	}
+
+	input.Delete("vstring")
+	if err := Decode(&input, &m); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !reflect.DeepEqual(m, map[string]int{"vint": 42}) {
+		t.Fatalf("bad: %#v", m)
+	}
+}
+
+func TestDecode_SyncMapNonStringKeys(t *testing.T) {
+	t.Parallel()
+
+	var input sync.Map
+	input.Store("vstring", "foo")
+	input.Store(2, "x")
+
+	var result Basic
+	config := &DecoderConfig{
+		ErrorUnused: true,
+		Result:      &result,
+	}
+
+	decoder, err := NewDecoder(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	err = decoder.Decode(&input)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	if !strings.Contains(err.Error(), "'' has invalid keys: 2") {
+		t.Fatalf("bad: %s", err)
+	}
+
+	var md Metadata
+	result = Basic{}
+	config = &DecoderConfig{
+		Metadata: &md,
+		Result:   &result,
+	}
+
+	decoder, err = NewDecoder(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := decoder.Decode(&input); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if result.Vstring != "foo" {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	if !reflect.DeepEqual(md.Unused, []string{"2"}) {
+		t.Fatalf("bad: %#v", md.Unused)
+	}
+}
+
+func TestDecode_Pairs(t *testing.T) {
+	t.Parallel()
+
+	type Server struct {
+		Host string
+		Port int
+	}
+
+	var server Server
+	pairs := []KeyValue{{Key: "host", Value: "localhost"}, {Key: "port", Value: 8080}}
+	if err := Decode(pairs, &server); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if server != (Server{Host: "localhost", Port: 8080}) {
+		t.Fatalf("bad: %#v", server)
+	}
+
+	var labels map[string]string
+	arrays := [][2]interface{}{{"app", "web"}, {"env", "prod"}}
+	if err := Decode(arrays, &labels); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(labels, map[string]string{"app": "web", "env": "prod"}) {
+		t.Fatalf("bad: %#v", labels)
+	}
+
+	var ordered OrderedMap
+	entries := []struct{ Key, Value interface{} }{{"z", 1}, {"a", 2}, {3, "three"}}
+	if err := Decode(entries, &ordered); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(ordered.Keys(), []string{"z", "a", "3"}) {
+		t.Fatalf("bad keys: %#v", ordered.Keys())
+	}
+
+	duplicates := []KeyValue{{Key: "host", Value: "a"}, {Key: "host", Value: "b"}}
+	err := Decode(duplicates, &server)
+	var derr *DecodingError
+	if !errors.As(err, &derr) || derr.Kind != DecodingErrorDuplicateKey {
+		t.Fatalf("bad err: %#v", err)
+	}
+}
+
+func TestDecode_MultiValueMap(t *testing.T) {
+	t.Parallel()
+
+	type Query struct {
+		Q      string
+		Page   int
+		Exact  *bool
+		Tags   []string
+		IDs    []int `mapstructure:"id"`
+		Filter interface{}
+	}
+
+	input := url.Values{
+		"q":      {"gopher"},
+		"page":   {"2"},
+		"exact":  {"true"},
+		"tags":   {"a", "b"},
+		"id":     {"1", "2", "3"},
+		"filter": {"x"},
+	}
+
+	var result Query
+	if err := WeakDecode(input, &result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	exact := true
+	expected := Query{
+		Q:      "gopher",
+		Page:   2,
+		Exact:  &exact,
+		Tags:   []string{"a", "b"},
+		IDs:    []int{1, 2, 3},
+		Filter: []string{"x"},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	var m map[string]string
+	if err := Decode(url.Values{"a": {"b"}}, &m); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(m, map[string]string{"a": "b"}) {
+		t.Fatalf("bad: %#v", m)
+	}
+
+	// Multiple values can't be decoded into a single value
+	if err := Decode(url.Values{"q": {"a", "b"}}, &result); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestDecode_StructToMultiValueMap(t *testing.T) {
+	t.Parallel()
+
+	type Paging struct {
+		Page  int `mapstructure:"page"`
+		Limit int `mapstructure:"limit,omitempty"`
+	}
+
+	type Query struct {
+		Paging `mapstructure:",squash"`
+		Q      string    `mapstructure:"q"`
+		Exact  *bool     `mapstructure:"exact"`
+		Tags   []string  `mapstructure:"tag,omitempty"`
+		IDs    []uint    `mapstructure:"id"`
+		Since  time.Time `mapstructure:"since"`
+		Ratio  float32   `mapstructure:"ratio"`
+		Hidden string    `mapstructure:"-"`
+	}
+
+	input := Query{
+		Paging: Paging{Page: 2},
+		Q:      "gopher",
+		IDs:    []uint{1, 2},
+		Since:  time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Ratio:  0.5,
+		Hidden: "secret",
+	}
+
+	var result url.Values
+	if err := Decode(input, &result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := url.Values{
+		"page":  {"2"},
+		"q":     {"gopher"},
+		"id":    {"1", "2"},
+		"since": {"2020-01-02T03:04:05Z"},
+		"ratio": {"0.5"},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	if result.Encode() != "id=1&id=2&page=2&q=gopher&ratio=0.5&since=2020-01-02T03%3A04%3A05Z" {
+		t.Fatalf("bad: %s", result.Encode())
+	}
+
+	type Nested struct {
+		Paging Paging
+	}
+
+	if err := Decode(Nested{}, &result); err == nil {
+		t.Fatal("expected error encoding a nested struct")
+	}
+}
+
+func TestDecode_Header(t *testing.T) {
+	t.Parallel()
+
+	type Options struct {
+		ContentType string   `mapstructure:"content-type"`
+		RequestID   string   `mapstructure:"x-request-id"`
+		Accept      []string `mapstructure:"accept"`
+		MaxForwards int      `mapstructure:"max-forwards"`
+	}
+
+	input := http.Header{}
+	input.Set("Content-Type", "application/json")
+	input.Set("X-Request-ID", "abc")
+	input.Add("Accept", "text/html, text/plain")
+	input.Add("Accept", "application/json")
+	input.Set("Max-Forwards", "10")
+
+	var result Options
+	config := &DecoderConfig{
+		Result:              &result,
+		WeaklyTypedInput:    true,
+		MultiValueSeparator: ",",
+		MatchName: func(mapKey, fieldName string) bool {
+			return mapKey == fieldName
+		},
+	}
+
+	decoder, err := NewDecoder(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := decoder.Decode(input); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := Options{
+		ContentType: "application/json",
+		RequestID:   "abc",
+		Accept:      []string{"text/html", "text/plain", "application/json"},
+		MaxForwards: 10,
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	var mime Options
+	if err := WeakDecode(textproto.MIMEHeader(input), &mime); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if mime.RequestID != "abc" || len(mime.Accept) != 2 {
+		t.Fatalf("bad: %#v", mime)
+	}
+}
+
+func TestDecoder_StringifyKeys(t *testing.T) {
+	t.Parallel()
+
+	type Server struct {
+		Name  string
+		Ports map[string]string
+		Tags  []map[string]interface{}
+	}
+
+	type Config struct {
+		Servers []Server
+		Extra   map[string]interface{}
+	}
+
+	// This is what yaml.v2 produces
+	input := map[interface{}]interface{}{
+		"servers": []interface{}{
+			map[interface{}]interface{}{
+				"name":  "web",
+				"ports": map[interface{}]interface{}{80: "http", 443: "https"},
+				"tags": []interface{}{
+					map[interface{}]interface{}{true: "enabled"},
+				},
+			},
+		},
+		"extra": map[interface{}]interface{}{
+			"nested": map[interface{}]interface{}{1: "one"},
+		},
+	}
+
+	var result Config
+	config := &DecoderConfig{
+		Result:        &result,
+		StringifyKeys: true,
+	}
+
+	decoder, err := NewDecoder(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := decoder.Decode(input); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := Config{
+		Servers: []Server{{
+			Name:  "web",
+			Ports: map[string]string{"80": "http", "443": "https"},
+			Tags:  []map[string]interface{}{{"true": "enabled"}},
+		}},
+		Extra: map[string]interface{}{
+			"nested": map[string]interface{}{"1": "one"},
+		},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+}
+
+// testNode is a Node of a tiny document tree. Mappings are testMappings.
+type testNode struct {
+	line  int
+	value interface{}
+}
+
+func (n *testNode) Position() Position {
+	return Position{Filename: "test.yaml", Line: n.line, Column: 1}
+}
+
+func (n *testNode) Value() (interface{}, error) {
+	return n.value, nil
+}
+
+type testMapping struct {
+	keys   []string
+	values []*testNode
+}
+
+func (m *testMapping) Keys() []string {
+	return m.keys
+}
+
+func (m *testMapping) Get(key string) (interface{}, bool) {
+	for i, k := range m.keys {
+		if k == key {
+			return m.values[i], true
+		}
+	}
+
+	return nil, false
+}
+
+func TestDecoder_StringifyKeysStruct(t *testing.T) {
+	t.Parallel()
+
+	type Columns struct {
+		ID   string `mapstructure:"0"`
+		Name string `mapstructure:"1"`
+	}
+
+	type Flags struct {
+		Enabled string `mapstructure:"true"`
+	}
+
+	var columns Columns
+	input := map[int]interface{}{0: "42", 1: "web"}
+	if err := Decode(input, &columns); err == nil {
+		t.Fatal("expected error")
+	}
+
+	config := &DecoderConfig{
+		Result:        &columns,
+		StringifyKeys: true,
+	}
+
+	decoder, err := NewDecoder(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := decoder.Decode(input); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if columns != (Columns{ID: "42", Name: "web"}) {
+		t.Fatalf("bad: %#v", columns)
+	}
+
+	var flags Flags
+	config.Result = &flags
+	decoder, err = NewDecoder(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := decoder.Decode(map[bool]string{true: "yes"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if flags.Enabled != "yes" {
+		t.Fatalf("bad: %#v", flags)
+	}
+}
+
+func TestDecoder_Node(t *testing.T) {
+	t.Parallel()
+
+	type Server struct {
+		Name string
+		Port int
+	}
+
+	type Config struct {
+		Servers []Server
+		Extra   interface{}
+	}
+
+	// servers:
+	//   - name: web
+	//     port: 80
+	//   - name: api
+	//     port: http
+	// extra:
+	//   tags: [a]
+	doc := &testNode{line: 1, value: &testMapping{
+		keys: []string{"servers", "extra"},
+		values: []*testNode{
+			{line: 2, value: []interface{}{
+				&testNode{line: 2, value: &testMapping{
+					keys: []string{"name", "port"},
+					values: []*testNode{
+						{line: 2, value: "web"},
+						{line: 3, value: 80},
+					},
+				}},
+				&testNode{line: 4, value: &testMapping{
+					keys: []string{"name", "port"},
+					values: []*testNode{
+						{line: 4, value: "api"},
+						{line: 5, value: "http"},
+					},
+				}},
+			}},
+			{line: 7, value: &testMapping{
+				keys: []string{"tags"},
+				values: []*testNode{
+					{line: 7, value: []interface{}{&testNode{line: 7, value: "a"}}},
+				},
+			}},
+		},
+	}}
+
+	var result Config
+	err := Decode(doc, &result)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	var derr *DecodingError
+	if !errors.As(err, &derr) {
+		t.Fatalf("expected a DecodingError, got %#v", err)
+	}
+	if derr.Position.String() != "test.yaml:5:1" {
+		t.Fatalf("bad position: %s", derr.Position)
+	}
+	if !strings.HasPrefix(err.Error(), "1 error(s) decoding:\n\n* test.yaml:5:1: ") {
+		t.Fatalf("bad error: %s", err)
+	}
+
+	expected := Config{
+		Servers: []Server{{Name: "web", Port: 80}, {Name: "api"}},
+		Extra:   map[string]interface{}{"tags": []interface{}{"a"}},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+}
@@ -0,0 +1,117 @@
+package mapstructure
+
+import "fmt"
+
+// DecodeStream decodes a stream of documents, such as the messages read
+// from a queue, into values of type T. It is used like a bufio.Scanner:
+//
+//	stream, err := mapstructure.NewDecodeStream[Event](mapstructure.ChanIterator(messages))
+//	if err != nil {
+//		return err
+//	}
+//	for stream.Next() {
+//		if err := stream.Err(); err != nil {
+//			log.Print(err)
+//			continue
+//		}
+//		handle(stream.Value())
+//	}
+//
+// All documents are decoded by the same Decoder, so the configuration is
+// only checked once, and the plans for T are built when the stream is
+// created rather than when the first document is decoded. A document that
+// fails to decode doesn't end the stream.
+type DecodeStream[T any] struct {
+	next    func() (map[string]interface{}, bool)
+	decoder *Decoder
+
+	index int
+	value T
+	err   error
+}
+
+// StreamError is the error of a document of a DecodeStream that failed to
+// decode.
+type StreamError struct {
+	// Index is the index of the document in the stream, starting at 0.
+	Index int
+
+	// Err is the decoding error.
+	Err error
+}
+
+func (e *StreamError) Error() string {
+	return fmt.Sprintf("document %d: %s", e.Index, e.Err)
+}
+
+func (e *StreamError) Unwrap() error {
+	return e.Err
+}
+
+// NewDecodeStream returns a DecodeStream that decodes the documents that
+// next returns until it returns false. The config, if given, is used for
+// every document; its Result is ignored, and its Metadata, if set, holds
+// the metadata of the current document.
+func NewDecodeStream[T any](next func() (map[string]interface{}, bool), config ...*DecoderConfig) (*DecodeStream[T], error) {
+	c := copyConfig(config...)
+
+	s := &DecodeStream[T]{next: next, index: -1}
+	c.Result = &s.value
+
+	decoder, err := NewDecoder(c)
+	if err != nil {
+		return nil, err
+	}
+	decoder.Prepare()
+	s.decoder = decoder
+
+	return s, nil
+}
+
+// ChanIterator returns a function for NewDecodeStream that receives the
+// documents from ch until it is closed.
+func ChanIterator(ch <-chan map[string]interface{}) func() (map[string]interface{}, bool) {
+	return func() (map[string]interface{}, bool) {
+		input, ok := <-ch
+		return input, ok
+	}
+}
+
+// Next decodes the next document. It returns false once there are no
+// more documents.
+func (s *DecodeStream[T]) Next() bool {
+	var zero T
+	s.value, s.err = zero, nil
+
+	input, ok := s.next()
+	if !ok {
+		return false
+	}
+
+	s.index++
+	if md := s.decoder.config.Metadata; md != nil {
+		*md = Metadata{Keys: []string{}, Unused: []string{}, Unset: []string{}}
+	}
+	if err := s.decoder.Decode(input); err != nil {
+		s.err = &StreamError{Index: s.index, Err: err}
+	}
+
+	return true
+}
+
+// Value returns the value decoded from the current document. If the
+// document failed to decode, the value may be partially decoded.
+func (s *DecodeStream[T]) Value() T {
+	return s.value
+}
+
+// Err returns a *StreamError if the current document failed to decode,
+// or nil if it didn't.
+func (s *DecodeStream[T]) Err() error {
+	return s.err
+}
+
+// Index returns the index of the current document, starting at 0.
+func (s *DecodeStream[T]) Index() int {
+	return s.index
+}
@@ -0,0 +1,104 @@
+package mapstructure
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type streamEvent struct {
+	ID   int      `mapstructure:"id"`
+	Tags []string `mapstructure:"tags"`
+}
+
+func TestDecodeStream(t *testing.T) {
+	t.Parallel()
+
+	ch := make(chan map[string]interface{}, 3)
+	ch <- map[string]interface{}{"id": 1, "tags": []string{"a"}}
+	ch <- map[string]interface{}{"id": "two"}
+	ch <- map[string]interface{}{"id": 3}
+	close(ch)
+
+	stream, err := NewDecodeStream[streamEvent](ChanIterator(ch))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var values []streamEvent
+	var errs []error
+	for stream.Next() {
+		if err := stream.Err(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		values = append(values, stream.Value())
+	}
+
+	// The tags of the first document mustn't leak into the third.
+	expected := []streamEvent{{ID: 1, Tags: []string{"a"}}, {ID: 3}}
+	if !reflect.DeepEqual(values, expected) {
+		t.Fatalf("bad: %#v", values)
+	}
+
+	if len(errs) != 1 {
+		t.Fatalf("bad: %#v", errs)
+	}
+	var serr *StreamError
+	if !errors.As(errs[0], &serr) || serr.Index != 1 {
+		t.Fatalf("bad: %#v", errs[0])
+	}
+	var derr *DecodingError
+	if !errors.As(errs[0], &derr) || derr.Name != "id" {
+		t.Fatalf("bad: %#v", errs[0])
+	}
+
+	if stream.Next() {
+		t.Fatal("expected end of stream")
+	}
+}
+
+func TestDecodeStream_config(t *testing.T) {
+	t.Parallel()
+
+	docs := []map[string]interface{}{{"id": "1"}, {"id": "2"}}
+	next := func() (map[string]interface{}, bool) {
+		if len(docs) == 0 {
+			return nil, false
+		}
+		doc := docs[0]
+		docs = docs[1:]
+		return doc, true
+	}
+
+	var md Metadata
+	stream, err := NewDecodeStream[streamEvent](next, &DecoderConfig{
+		WeaklyTypedInput: true,
+		Metadata:         &md,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	sum := 0
+	for stream.Next() {
+		if err := stream.Err(); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		sum += stream.Value().ID
+
+		// The metadata is that of the current document.
+		if !reflect.DeepEqual(md.Keys, []string{"id"}) {
+			t.Fatalf("bad: %#v", md.Keys)
+		}
+	}
+
+	if sum != 3 {
+		t.Fatalf("bad: %d", sum)
+	}
+
+	_, err = NewDecodeStream[struct{ ID int }](next, &DecoderConfig{RequireTags: true})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
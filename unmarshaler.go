@@ -0,0 +1,93 @@
+package mapstructure
+
+import (
+	"encoding"
+	"reflect"
+)
+
+// Unmarshaler is implemented by types that decode themselves, like
+// json.Unmarshaler. When the result, or a value inside it, is of a type
+// that implements Unmarshaler on its value or on a pointer to it,
+// DecodeMapstructure is called with the input instead of decoding it as
+// usual. The input is passed after the DecodeHook has run, and nil inputs
+// are handled as for any other type without calling DecodeMapstructure.
+//
+// An error returned by DecodeMapstructure is wrapped in a DecodingError
+// of kind DecodingErrorGeneric.
+type Unmarshaler interface {
+	DecodeMapstructure(input interface{}) error
+}
+
+var unmarshalerType = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+
+// unmarshaler returns the Unmarshaler of val, if its type or a pointer to
+// it implements Unmarshaler. Pointers and interfaces are left to be
+// allocated and decoded through, so that the method is never called on
+// a nil value.
+func unmarshaler(val reflect.Value) (Unmarshaler, bool) {
+	switch val.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return nil, false
+	}
+
+	if val.CanAddr() && val.Addr().Type().Implements(unmarshalerType) {
+		return val.Addr().Interface().(Unmarshaler), true
+	}
+	if val.Type().Implements(unmarshalerType) {
+		return val.Interface().(Unmarshaler), true
+	}
+
+	return nil, false
+}
+
+// decodeUnmarshaler decodes data into u by calling DecodeMapstructure.
+func (d *Decoder) decodeUnmarshaler(name string, data interface{}, u Unmarshaler) error {
+	if err := u.DecodeMapstructure(data); err != nil {
+		return d.decodingError(MsgHookFailure, &DecodingError{
+			Kind:  DecodingErrorGeneric,
+			Name:  name,
+			Value: data,
+			Err:   err,
+		})
+	}
+
+	return nil
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// textUnmarshaler returns the encoding.TextUnmarshaler of val if
+// UseTextUnmarshaler is set, data is a string and a pointer to val
+// implements it.
+func (d *Decoder) textUnmarshaler(data interface{}, val reflect.Value) (encoding.TextUnmarshaler, bool) {
+	if !d.config.UseTextUnmarshaler || reflect.ValueOf(data).Kind() != reflect.String {
+		return nil, false
+	}
+
+	switch val.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return nil, false
+	}
+
+	if !val.CanAddr() || !val.Addr().Type().Implements(textUnmarshalerType) {
+		return nil, false
+	}
+
+	return val.Addr().Interface().(encoding.TextUnmarshaler), true
+}
+
+// decodeTextUnmarshaler decodes the string data into val by calling
+// UnmarshalText on u.
+func (d *Decoder) decodeTextUnmarshaler(name string, data interface{}, val reflect.Value, u encoding.TextUnmarshaler) error {
+	if err := u.UnmarshalText([]byte(reflect.ValueOf(data).String())); err != nil {
+		return d.decodingError(MsgParseFailure, &DecodingError{
+			Kind:     DecodingErrorParseFailure,
+			Name:     name,
+			Expected: val.Type().String(),
+			Value:    data,
+			Err:      err,
+		})
+	}
+
+	return nil
+}
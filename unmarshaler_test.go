@@ -0,0 +1,160 @@
+package mapstructure
+
+import (
+	"errors"
+	"math/big"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type csvList []string
+
+func (l *csvList) DecodeMapstructure(input interface{}) error {
+	s, ok := input.(string)
+	if !ok {
+		return errors.New("expected a string")
+	}
+
+	*l = strings.Split(s, ",")
+	return nil
+}
+
+type level int
+
+func (l *level) DecodeMapstructure(input interface{}) error {
+	switch input {
+	case "low":
+		*l = 1
+	case "high":
+		*l = 2
+	default:
+		return errors.New("unknown level")
+	}
+
+	return nil
+}
+
+type upperMap map[string]string
+
+func (m upperMap) DecodeMapstructure(input interface{}) error {
+	for k, v := range input.(map[string]interface{}) {
+		m[strings.ToUpper(k)] = v.(string)
+	}
+
+	return nil
+}
+
+func TestDecode_Unmarshaler(t *testing.T) {
+	t.Parallel()
+
+	type Config struct {
+		Level  level
+		Tags   csvList
+		Extra  *csvList
+		Groups []csvList
+		Labels upperMap
+	}
+
+	input := map[string]interface{}{
+		"level":  "high",
+		"tags":   "a,b",
+		"extra":  "c",
+		"groups": []interface{}{"d,e", "f"},
+		"labels": map[string]interface{}{"env": "prod"},
+	}
+
+	result := Config{Labels: upperMap{}}
+	if err := Decode(input, &result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := Config{
+		Level:  2,
+		Tags:   csvList{"a", "b"},
+		Extra:  &csvList{"c"},
+		Groups: []csvList{{"d", "e"}, {"f"}},
+		Labels: upperMap{"ENV": "prod"},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, result)
+	}
+
+	// The result itself can be an Unmarshaler.
+	var tags csvList
+	if err := Decode("x,y", &tags); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(tags, csvList{"x", "y"}) {
+		t.Fatalf("bad: %#v", tags)
+	}
+
+	// Errors are wrapped in a DecodingError.
+	err := Decode(map[string]interface{}{"level": "medium"}, &result)
+	var decodingErr *DecodingError
+	if !errors.As(err, &decodingErr) {
+		t.Fatalf("expected a DecodingError, got %#v", err)
+	}
+	if decodingErr.Name != "Level" || decodingErr.Kind != DecodingErrorGeneric {
+		t.Fatalf("bad: %#v", decodingErr)
+	}
+	if got := decodingErr.Error(); got != "error decoding 'Level': unknown level" {
+		t.Fatalf("bad: %s", got)
+	}
+}
+
+func TestDecoder_UseTextUnmarshaler(t *testing.T) {
+	t.Parallel()
+
+	type Config struct {
+		Addr    net.IP
+		Limit   *big.Int
+		Peers   []net.IP
+		Routes  map[string]net.IP
+		Level   level
+		Comment string
+	}
+
+	input := map[string]interface{}{
+		"addr":    "10.0.0.1",
+		"limit":   "123456789012345678901234567890",
+		"peers":   []interface{}{"10.0.0.2"},
+		"routes":  map[string]interface{}{"default": "10.0.0.254"},
+		"level":   "low",
+		"comment": "plain",
+	}
+
+	var result Config
+	decoder, err := NewDecoder(&DecoderConfig{UseTextUnmarshaler: true, Result: &result})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := decoder.Decode(input); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	limit, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	expected := Config{
+		Addr:    net.ParseIP("10.0.0.1"),
+		Limit:   limit,
+		Peers:   []net.IP{net.ParseIP("10.0.0.2")},
+		Routes:  map[string]net.IP{"default": net.ParseIP("10.0.0.254")},
+		Level:   1,
+		Comment: "plain",
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, result)
+	}
+
+	err = decoder.Decode(map[string]interface{}{"addr": "nope"})
+	var decodingErr *DecodingError
+	if !errors.As(err, &decodingErr) || decodingErr.Kind != DecodingErrorParseFailure || decodingErr.Name != "Addr" {
+		t.Fatalf("expected a parse failure of Addr, got %#v", err)
+	}
+
+	// Without the option, strings aren't decoded into net.IP.
+	if err := Decode(map[string]interface{}{"addr": "10.0.0.1"}, &result); err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -0,0 +1,102 @@
+package mapstructure
+
+import (
+	"reflect"
+	"strings"
+)
+
+// VerifyStruct checks that every exported field of the struct type of v,
+// and of the struct types it contains, has a tag, so that decoding never
+// falls back to matching keys with field names that silently change when
+// a field is renamed. v can be a struct or a pointer to one. The tag name
+// and JSONTags are taken from config, if given.
+//
+// Contained struct types are only checked if they are declared in the same
+// package as v, since the fields of other packages' types usually can't be
+// tagged. Embedded structs that are squashed don't need a tag themselves.
+// The returned error lists all the untagged fields.
+func VerifyStruct(v interface{}, config ...*DecoderConfig) error {
+	c := copyConfig(config...)
+	if c.TagName == "" {
+		c.TagName = "mapstructure"
+	}
+
+	d := &Decoder{config: c}
+	typ := reflect.TypeOf(v)
+	for typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	var errs []error
+	if typ != nil && typ.Kind() == reflect.Struct {
+		errs = d.verifyTags("", typ, typ.PkgPath(), map[reflect.Type]bool{})
+	}
+
+	if len(errs) > 0 {
+		return d.finishError(newError(errs))
+	}
+
+	return nil
+}
+
+// verifyTags returns an error for every untagged field of typ and the
+// struct types it contains that are declared in pkgPath.
+func (d *Decoder) verifyTags(name string, typ reflect.Type, pkgPath string, seen map[reflect.Type]bool) []error {
+	switch typ.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return d.verifyTags(name, typ.Elem(), pkgPath, seen)
+	case reflect.Map:
+		return d.verifyTags(name, typ.Elem(), pkgPath, seen)
+	case reflect.Struct:
+	default:
+		return nil
+	}
+
+	if typ.PkgPath() != pkgPath || seen[typ] {
+		return nil
+	}
+	seen[typ] = true
+
+	var errs []error
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.Type == optionsType {
+			continue
+		}
+
+		tag := d.fieldTag(f)
+		squash := d.config.Squash && f.Anonymous
+		for _, opt := range strings.Split(tag, ",")[1:] {
+			squash = squash || opt == "squash"
+		}
+
+		if squash {
+			errs = append(errs, d.verifyTags(name, f.Type, pkgPath, seen)...)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+
+		fieldName := f.Name
+		if tagValue := strings.SplitN(tag, ",", 2)[0]; tagValue != "" {
+			fieldName = tagValue
+		}
+		if name != "" {
+			fieldName = name + "." + fieldName
+		}
+
+		if tag == "" {
+			errs = append(errs, d.decodingError(MsgUntaggedField, &DecodingError{
+				Kind:     DecodingErrorUntaggedField,
+				Name:     fieldName,
+				Expected: d.config.TagName,
+			}))
+			continue
+		}
+
+		errs = append(errs, d.verifyTags(fieldName, f.Type, pkgPath, seen)...)
+	}
+
+	return errs
+}
@@ -0,0 +1,80 @@
+package mapstructure
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+type verifyBase struct {
+	Region string `mapstructure:"region"`
+	Zone   string
+}
+
+type verifyListener struct {
+	Port int `mapstructure:"port"`
+	TLS  bool
+}
+
+type verifyConfig struct {
+	verifyBase `mapstructure:",squash"`
+
+	Name      string            `mapstructure:"name"`
+	Listeners []verifyListener  `mapstructure:"listeners"`
+	Extra     map[string]string `mapstructure:"extra"`
+	Endpoint  *url.URL          `mapstructure:"endpoint"`
+	Timeout   int
+	internal  int
+}
+
+func TestVerifyStruct(t *testing.T) {
+	t.Parallel()
+
+	err := VerifyStruct(&verifyConfig{})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	var names []string
+	var derr *DecodingError
+	for _, err := range err.(*Error).WrappedErrors() {
+		if !errors.As(err, &derr) || derr.Kind != DecodingErrorUntaggedField {
+			t.Fatalf("bad error: %#v", err)
+		}
+		names = append(names, derr.Name)
+	}
+
+	// url.URL is from another package and isn't checked
+	expected := []string{"Zone", "listeners.TLS", "Timeout"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("bad: %#v", names)
+	}
+
+	if err := VerifyStruct(verifyListener{}, &DecoderConfig{TagName: "json"}); err == nil {
+		t.Fatal("expected error")
+	}
+
+	type tagged struct {
+		Port int `mapstructure:"port"`
+	}
+	if err := VerifyStruct(tagged{}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestDecoder_RequireTags(t *testing.T) {
+	t.Parallel()
+
+	var result verifyListener
+	_, err := NewDecoder(&DecoderConfig{
+		Result:      &result,
+		RequireTags: true,
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if err.Error() != "1 error(s) decoding:\n\n* 'TLS' has no 'mapstructure' tag" {
+		t.Fatalf("bad: %s", err)
+	}
+}
@@ -0,0 +1,39 @@
+package mapstructure
+
+import (
+	"reflect"
+)
+
+// The keys that XMLConventions maps the "attr" and "chardata" fields to.
+const (
+	xmlAttrPrefix = "@"
+	xmlTextKey    = "#text"
+)
+
+// xmlKey returns the key of the field f, named name, under
+// XMLConventions: "@name" for attributes, "#text" for character data and
+// name for any other field.
+func (d *Decoder) xmlKey(f reflect.StructField, name string) string {
+	tag := d.fieldTag(f)
+	switch {
+	case hasTagOption(tag, "attr"):
+		return xmlAttrPrefix + name
+	case hasTagOption(tag, "chardata"):
+		return xmlTextKey
+	default:
+		return name
+	}
+}
+
+// hasCharData reports whether the struct type typ has a field with the
+// "chardata" option, so that an element with only character data, which
+// is a scalar rather than a map, can be decoded into it.
+func (d *Decoder) hasCharData(typ reflect.Type) bool {
+	for i := 0; i < typ.NumField(); i++ {
+		if hasTagOption(d.fieldTag(typ.Field(i)), "chardata") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,69 @@
+package mapstructure
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecoder_XMLConventions(t *testing.T) {
+	t.Parallel()
+
+	type Price struct {
+		Currency string  `mapstructure:"currency,attr"`
+		Amount   float64 `mapstructure:",chardata"`
+	}
+
+	type Item struct {
+		ID    string `mapstructure:"id,attr"`
+		Name  string `mapstructure:"name"`
+		Price Price  `mapstructure:"price"`
+		Tags  []Price
+	}
+
+	// <item id="42"><name>Lamp</name><price currency="EUR">9.5</price>
+	// <tags>1</tags></item>
+	input := map[string]interface{}{
+		"@id":  "42",
+		"name": "Lamp",
+		"price": map[string]interface{}{
+			"@currency": "EUR",
+			"#text":     9.5,
+		},
+		"tags": []interface{}{1.0},
+	}
+
+	var result Item
+	config := &DecoderConfig{
+		XMLConventions: true,
+		Result:         &result,
+	}
+
+	decoder, err := NewDecoder(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := decoder.Decode(input); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := Item{
+		ID:    "42",
+		Name:  "Lamp",
+		Price: Price{Currency: "EUR", Amount: 9.5},
+		Tags:  []Price{{Amount: 1}},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	// Without the option the attributes aren't matched.
+	delete(input, "tags")
+	var plain Item
+	if err := Decode(input, &plain); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if plain.ID != "" || plain.Price.Currency != "" {
+		t.Fatalf("bad: %#v", plain)
+	}
+}
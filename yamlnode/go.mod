@@ -0,0 +1,10 @@
+module github.com/mitchellh/mapstructure/yamlnode
+
+go 1.20
+
+require (
+	github.com/mitchellh/mapstructure v1.5.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+replace github.com/mitchellh/mapstructure => ../
@@ -0,0 +1,158 @@
+// Package yamlnode adapts gopkg.in/yaml.v3 nodes to mapstructure.Node, so
+// that YAML documents can be decoded with mapstructure without losing the
+// line and column of their values:
+//
+//	var doc yaml.Node
+//	if err := yaml.Unmarshal(data, &doc); err != nil {
+//		return err
+//	}
+//
+//	err := mapstructure.Decode(yamlnode.New(&doc, "config.yaml"), &config)
+//
+// It is a separate module so that mapstructure itself doesn't depend on
+// yaml.v3.
+package yamlnode
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/mapstructure"
+	"gopkg.in/yaml.v3"
+)
+
+// New returns a mapstructure.Node for n. The filename, if not empty, is
+// included in the positions of the nodes.
+func New(n *yaml.Node, filename string) mapstructure.Node {
+	return &node{n: n, filename: filename}
+}
+
+type node struct {
+	n        *yaml.Node
+	filename string
+}
+
+func (n *node) wrap(child *yaml.Node) *node {
+	return &node{n: child, filename: n.filename}
+}
+
+func (n *node) Position() mapstructure.Position {
+	return mapstructure.Position{
+		Filename: n.filename,
+		Line:     n.n.Line,
+		Column:   n.n.Column,
+	}
+}
+
+func (n *node) Value() (interface{}, error) {
+	switch n.n.Kind {
+	case yaml.DocumentNode:
+		if len(n.n.Content) == 0 {
+			return nil, nil
+		}
+		return n.wrap(n.n.Content[0]).Value()
+
+	case yaml.AliasNode:
+		return n.wrap(n.n.Alias).Value()
+
+	case yaml.SequenceNode:
+		s := make([]interface{}, len(n.n.Content))
+		for i, child := range n.n.Content {
+			s[i] = n.wrap(child)
+		}
+		return s, nil
+
+	case yaml.MappingNode:
+		m := &mapping{}
+		if err := m.add(n); err != nil {
+			return nil, err
+		}
+		return m, nil
+
+	case yaml.ScalarNode:
+		var v interface{}
+		if err := n.n.Decode(&v); err != nil {
+			return nil, err
+		}
+		return v, nil
+
+	default:
+		return nil, fmt.Errorf("unknown YAML node kind %d", n.n.Kind)
+	}
+}
+
+// mapping is a mapping node as a mapstructure.Source, with its keys in
+// document order.
+type mapping struct {
+	keys   []string
+	values map[string]*node
+}
+
+// add adds the pairs of the mapping node n, including those merged in
+// with "<<" keys. Pairs that are already set take precedence over merged
+// ones, and later pairs take precedence over earlier ones.
+func (m *mapping) add(n *node) error {
+	if m.values == nil {
+		m.values = make(map[string]*node, len(n.n.Content)/2)
+	}
+
+	var merges []*yaml.Node
+	for i := 0; i+1 < len(n.n.Content); i += 2 {
+		key, value := n.n.Content[i], n.n.Content[i+1]
+		if key.Kind == yaml.ScalarNode && key.Tag == "!!merge" {
+			merges = append(merges, value)
+			continue
+		}
+
+		if key.Kind != yaml.ScalarNode {
+			return fmt.Errorf("line %d: mapping keys must be scalars", key.Line)
+		}
+
+		if _, ok := m.values[key.Value]; !ok {
+			m.keys = append(m.keys, key.Value)
+		}
+		m.values[key.Value] = n.wrap(value)
+	}
+
+	for _, merge := range merges {
+		if merge.Kind == yaml.AliasNode {
+			merge = merge.Alias
+		}
+
+		sources := []*yaml.Node{merge}
+		if merge.Kind == yaml.SequenceNode {
+			sources = merge.Content
+		}
+
+		for _, source := range sources {
+			if source.Kind == yaml.AliasNode {
+				source = source.Alias
+			}
+			if source.Kind != yaml.MappingNode {
+				return fmt.Errorf("line %d: only mappings can be merged", source.Line)
+			}
+
+			merged := &mapping{}
+			if err := merged.add(n.wrap(source)); err != nil {
+				return err
+			}
+
+			for _, key := range merged.keys {
+				if _, ok := m.values[key]; !ok {
+					m.keys = append(m.keys, key)
+					m.values[key] = merged.values[key]
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (m *mapping) Keys() []string {
+	return m.keys
+}
+
+func (m *mapping) Get(key string) (interface{}, bool) {
+	v, ok := m.values[key]
+	return v, ok
+}
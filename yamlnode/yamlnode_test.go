@@ -0,0 +1,87 @@
+package yamlnode
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/mitchellh/mapstructure"
+	"gopkg.in/yaml.v3"
+)
+
+const testDocument = `
+defaults: &defaults
+  timeout: 30
+servers:
+  - name: web
+    port: 80
+    <<: *defaults
+  - name: api
+    port: http
+    timeout: 10
+tags: [a, b]
+`
+
+type server struct {
+	Name    string
+	Port    int
+	Timeout int
+}
+
+type config struct {
+	Servers []server
+	Tags    []string
+	Extra   map[string]interface{} `mapstructure:",remain"`
+}
+
+func TestNew(t *testing.T) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(testDocument), &doc); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var result config
+	err := mapstructure.Decode(New(&doc, "config.yaml"), &result)
+
+	var derr *mapstructure.DecodingError
+	if !errors.As(err, &derr) {
+		t.Fatalf("expected a DecodingError, got %#v", err)
+	}
+	if derr.Name != "Servers[1].Port" {
+		t.Fatalf("bad name: %s", derr.Name)
+	}
+	if derr.Position.String() != "config.yaml:9:11" {
+		t.Fatalf("bad position: %s", derr.Position)
+	}
+
+	expected := config{
+		Servers: []server{
+			{Name: "web", Port: 80, Timeout: 30},
+			{Name: "api", Timeout: 10},
+		},
+		Tags: []string{"a", "b"},
+		Extra: map[string]interface{}{
+			"defaults": map[string]interface{}{"timeout": 30},
+		},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+}
+
+func TestNew_keyOrder(t *testing.T) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte("c: 1\na: 2\nb: 3\n"), &doc); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	v, err := New(&doc, "").Value()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	keys := v.(mapstructure.Source).Keys()
+	if !reflect.DeepEqual(keys, []string{"c", "a", "b"}) {
+		t.Fatalf("bad: %#v", keys)
+	}
+}